@@ -6,15 +6,25 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/NodeFactoryIo/vedran/internal/whitelist"
 
+	"github.com/NodeFactoryIo/vedran/internal/breaker"
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
 	"github.com/NodeFactoryIo/vedran/internal/ip"
+	"github.com/NodeFactoryIo/vedran/internal/iplimit"
 	"github.com/NodeFactoryIo/vedran/internal/loadbalancer"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
+	"github.com/NodeFactoryIo/vedran/internal/policy"
+	"github.com/NodeFactoryIo/vedran/internal/probe"
+	"github.com/NodeFactoryIo/vedran/internal/quorum"
+	nodeselection "github.com/NodeFactoryIo/vedran/internal/selection"
 	"github.com/NodeFactoryIo/vedran/internal/tunnel"
+	"github.com/NodeFactoryIo/vedran/internal/ws"
 	"github.com/NodeFactoryIo/vedran/pkg/http-tunnel/server"
 	"github.com/NodeFactoryIo/vedran/pkg/logger"
 	"github.com/NodeFactoryIo/vedran/pkg/util"
@@ -25,18 +35,59 @@ import (
 
 var (
 	// load balancer related flags
-	authSecret     string
-	name           string
-	certFile       string
-	keyFile        string
-	capacity       int64
-	whitelistArray []string
-	whitelistFile  string
-	fee            float32
-	selection      string
-	serverPort     int32
-	publicIP       string
-	rootDir        string
+	authSecret      string
+	prevAuthSecret  string
+	authGracePeriod time.Duration
+	authTokenTTL    time.Duration
+	adminToken      string
+	name            string
+	certFile        string
+	keyFile         string
+	capacity        int64
+	genesisHash     string
+	chainName       string
+	probeInterval   time.Duration
+	probeDivergence int64
+	penaltyPolicies []string
+	penaltyWindow   time.Duration
+	penaltyDecay    time.Duration
+	parsedPolicies  map[string]penalty.Policy
+	breakerWindow   time.Duration
+	breakerRate     float64
+	breakerBudget   int
+	breakerOpenTime time.Duration
+	breakerTrickle  float64
+	breakerTrips    int
+	whitelistArray  []string
+	whitelistFile   string
+	fee             float32
+	selection       string
+	cacheSize       int
+	allowedMethods  []string
+	deniedMethods   []string
+	batchChunkSize  int
+	hedgePercentile float64
+	broadcastSize   int
+	quorumSize      int
+	quorumMethods   []string
+	quorumSample    float64
+	anonymousAccess bool
+	anonymousRate   float64
+	anonymousQuota  int64
+	ipRateLimit     float64
+	ipRateBurst     int
+	ipWSConnections int
+	trustForwarded  bool
+	wsPoolSize      int
+	wsFailover      bool
+	wsMaxMessage    int64
+	wsIdleTimeout   time.Duration
+	wsPingInterval  time.Duration
+	wsOrigins       []string
+	wsCompression   bool
+	serverPort      int32
+	publicIP        string
+	rootDir         string
 	// payout related flags
 	payoutFeeAddress           string
 	payoutPrivateKey           string
@@ -52,6 +103,9 @@ var (
 	tunnelPortRange  string
 )
 
+// genesisHashRegex matches 32 bytes hex encoded hash with 0x prefix
+var genesisHashRegex = regexp.MustCompile("^0x[0-9a-fA-F]{64}$")
+
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Starts vedran load balancer",
@@ -82,14 +136,120 @@ var startCmd = &cobra.Command{
 		return nil
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
-		// valid values are round-robin and random
-		if selection != "round-robin" && selection != "random" {
+		// valid values are defined by available selection strategies
+		if !nodeselection.IsValidStrategy(selection) {
 			return errors.New("invalid selection option selected")
 		}
+		// cache size in megabytes, 0 disables caching
+		if cacheSize < 0 {
+			return errors.New("invalid cache size value")
+		}
+		// 0 disables splitting of batch requests
+		if batchChunkSize < 0 {
+			return errors.New("invalid batch chunk size value")
+		}
+		// valid value is between 0-1, where 0 disables hedging
+		if hedgePercentile < 0 || hedgePercentile >= 1 {
+			return errors.New("invalid hedge percentile value")
+		}
+		// 0 and 1 disable broadcasting of extrinsics
+		if broadcastSize < 0 {
+			return errors.New("invalid broadcast extrinsics value")
+		}
+		// quorum requires at least two nodes
+		if quorumSize < 2 {
+			return errors.New("invalid quorum size value")
+		}
+		// valid value is between 0-1, where 0 disables sampling
+		if quorumSample < 0 || quorumSample > 1 {
+			return errors.New("invalid quorum sample value")
+		}
+		// all positive values are valid, and 0 representing unlimited requests
+		if anonymousRate < 0 {
+			return errors.New("invalid anonymous rate limit value")
+		}
+		if anonymousQuota < 0 {
+			return errors.New("invalid anonymous quota value")
+		}
+		if ipRateLimit < 0 {
+			return errors.New("invalid ip rate limit value")
+		}
+		if ipRateBurst < 0 {
+			return errors.New("invalid ip rate burst value")
+		}
+		if ipWSConnections < 0 {
+			return errors.New("invalid ws connections per ip value")
+		}
+		// 0 means tokens never expire
+		if authTokenTTL < 0 {
+			return errors.New("invalid auth token ttl value")
+		}
+		if authGracePeriod < 0 {
+			return errors.New("invalid auth secret grace period value")
+		}
+		// 0 disables multiplexing of ws connections
+		if wsPoolSize < 0 {
+			return errors.New("invalid ws pool size value")
+		}
+		// 0 disables limit of ws message size, idle timeout and pings
+		if wsMaxMessage < 0 {
+			return errors.New("invalid ws max message size value")
+		}
+		if wsIdleTimeout < 0 {
+			return errors.New("invalid ws idle timeout value")
+		}
+		if wsPingInterval < 0 {
+			return errors.New("invalid ws ping interval value")
+		}
 		// all positive integers are valid, and -1 representing unlimited capacity
-		if capacity < -1 {
+		if capacity < -1 || capacity == 0 {
 			return errors.New("invalid capacity value")
 		}
+		if genesisHash != "" && !genesisHashRegex.MatchString(genesisHash) {
+			return errors.New("invalid chain genesis hash")
+		}
+		// 0 disables probing of nodes
+		if probeInterval < 0 {
+			return errors.New("invalid probe interval value")
+		}
+		if probeDivergence < 0 {
+			return errors.New("invalid probe divergence value")
+		}
+		policies, err := penalty.ParsePolicies(penaltyPolicies)
+		if err != nil {
+			return err
+		}
+		parsedPolicies = policies
+		if penaltyWindow <= 0 {
+			return errors.New("invalid penalty failure window value")
+		}
+		// 0 disables decay of penalty level
+		if penaltyDecay < 0 {
+			return errors.New("invalid penalty decay value")
+		}
+		// 0 disables circuit breakers and node is penalized on failed requests
+		if breakerWindow < 0 {
+			return errors.New("invalid breaker window value")
+		}
+		// valid value is between 0-1, excluding 0
+		if breakerRate <= 0 || breakerRate > 1 {
+			return errors.New("invalid breaker error rate value")
+		}
+		if breakerBudget < 0 {
+			return errors.New("invalid breaker retry budget value")
+		}
+		if breakerOpenTime <= 0 {
+			return errors.New("invalid breaker open duration value")
+		}
+		if breakerTrickle <= 0 || breakerTrickle > 1 {
+			return errors.New("invalid breaker trickle value")
+		}
+		if breakerTrips < 1 {
+			return errors.New("invalid breaker trips value")
+		}
+		if chainName != "" && genesisHash == "" {
+			return errors.New("chain genesis hash is required if chain name is set")
+		}
 		// valid value is between 0-1
 		if fee < 0 || fee > 1 {
 			return errors.New("invalid fee value")
@@ -117,9 +277,11 @@ var startCmd = &cobra.Command{
 
 		minPort, _ := strconv.Atoi(prt[0])
 		maxPort, _ := strconv.Atoi(prt[1])
+		// every node uses two ports, one for http and one for ws tunnel
+		maxCapacity := int64(maxPort-minPort) / 2
 		if capacity == -1 {
-			capacity = int64(maxPort - minPort)
-		} else if int64(maxPort-minPort) < capacity {
+			capacity = maxCapacity
+		} else if maxCapacity < capacity {
 			return errors.New("port range too small for target capacity")
 		}
 
@@ -150,6 +312,30 @@ func init() {
 		"",
 		"[REQUIRED] Authentication secret used for generating tokens")
 
+	startCmd.Flags().StringVar(
+		&prevAuthSecret,
+		"previous-auth-secret",
+		"",
+		"[OPTIONAL] Previous authentication secret, tokens signed with it are accepted during grace period so nodes can refresh them")
+
+	startCmd.Flags().DurationVar(
+		&authGracePeriod,
+		"auth-secret-grace-period",
+		24*time.Hour,
+		"[OPTIONAL] Duration after start during which tokens signed with previous authentication secret, and tokens issued without expiry if auth token ttl is set, are accepted")
+
+	startCmd.Flags().DurationVar(
+		&authTokenTTL,
+		"auth-token-ttl",
+		0,
+		"[OPTIONAL] Duration for which node tokens are valid. 0 means tokens never expire")
+
+	startCmd.Flags().StringVar(
+		&adminToken,
+		"admin-token",
+		"",
+		"[OPTIONAL] Token used by operators to access admin api, admin api is disabled if omitted")
+
 	startCmd.Flags().StringVar(
 		&name,
 		"name",
@@ -162,6 +348,86 @@ func init() {
 		-1,
 		"[OPTIONAL] Maximum number of nodes allowed to connect, where -1 represents no upper limit")
 
+	startCmd.Flags().StringVar(
+		&genesisHash,
+		"chain-genesis-hash",
+		"",
+		"[OPTIONAL] Genesis hash of chain nodes have to serve, chain of nodes is not verified if omitted")
+
+	startCmd.Flags().StringVar(
+		&chainName,
+		"chain-name",
+		"",
+		"[OPTIONAL] Name of chain nodes have to serve (as returned by system_chain), requires --chain-genesis-hash")
+
+	startCmd.Flags().DurationVar(
+		&probeInterval,
+		"probe-interval",
+		probe.DefaultProbeInterval,
+		"[OPTIONAL] Interval on which rpc of nodes is probed through their tunnels. 0 disables probing")
+
+	startCmd.Flags().Int64Var(
+		&probeDivergence,
+		"probe-divergence",
+		probe.DefaultDivergenceTolerance,
+		"[OPTIONAL] Number of blocks heights reported by node can differ from probed heights before node is penalized")
+
+	startCmd.Flags().StringSliceVar(
+		&penaltyPolicies,
+		"penalty-policy",
+		nil,
+		"[OPTIONAL] Comma separated list of penalty policies in format reason=base-cooldown:growth:max-cooldown:threshold"+
+			" (e.g. failed-request=1m:2:17h:3), default policy is used for reasons that are not listed")
+
+	startCmd.Flags().DurationVar(
+		&penaltyWindow,
+		"penalty-failure-window",
+		penalty.DefaultFailureWindow,
+		"[OPTIONAL] Time in which failures of node are counted toward threshold of penalty policy")
+
+	startCmd.Flags().DurationVar(
+		&penaltyDecay,
+		"penalty-decay",
+		penalty.DefaultDecayInterval,
+		"[OPTIONAL] Time without penalty after which penalty level of node is lowered. 0 disables decay")
+
+	startCmd.Flags().DurationVar(
+		&breakerWindow,
+		"breaker-window",
+		breaker.DefaultWindow,
+		"[OPTIONAL] Sliding window in which error rate of node is calculated for its circuit breaker."+
+			" 0 disables circuit breakers and node is penalized on failed requests")
+
+	startCmd.Flags().Float64Var(
+		&breakerRate,
+		"breaker-error-rate",
+		breaker.DefaultErrorRate,
+		"[OPTIONAL] Fraction (0-1] of failed requests in breaker window that opens circuit breaker of node")
+
+	startCmd.Flags().IntVar(
+		&breakerBudget,
+		"breaker-retry-budget",
+		breaker.DefaultRetryBudget,
+		"[OPTIONAL] Number of failed requests of node in breaker window that are retried on other nodes before error rate is checked")
+
+	startCmd.Flags().DurationVar(
+		&breakerOpenTime,
+		"breaker-open-duration",
+		breaker.DefaultOpenDuration,
+		"[OPTIONAL] Time node is kept out of selection after its circuit breaker opens")
+
+	startCmd.Flags().Float64Var(
+		&breakerTrickle,
+		"breaker-trickle",
+		breaker.DefaultTrickle,
+		"[OPTIONAL] Fraction (0-1] of requests node with half open circuit breaker is selected for")
+
+	startCmd.Flags().IntVar(
+		&breakerTrips,
+		"breaker-trips",
+		breaker.DefaultTrips,
+		"[OPTIONAL] Number of times circuit breaker of node opens in an hour before node is penalized")
+
 	startCmd.Flags().StringSliceVar(
 		&whitelistArray,
 		"whitelist",
@@ -186,7 +452,147 @@ func init() {
 		&selection,
 		"selection",
 		"round-robin",
-		"[OPTIONAL] Type of selection used for choosing nodes (round-robin, random)")
+		fmt.Sprintf(
+			"[OPTIONAL] Type of selection used for choosing nodes (%s)",
+			strings.Join(nodeselection.Strategies(), ", ")))
+
+	startCmd.Flags().IntVar(
+		&cacheSize,
+		"cache-size",
+		64,
+		"[OPTIONAL] Size in megabytes of in-memory cache for immutable rpc results, where 0 disables caching")
+
+	startCmd.Flags().StringSliceVar(
+		&allowedMethods,
+		"rpc-allow",
+		nil,
+		"[OPTIONAL] Comma separated list of rpc methods or glob patterns (e.g. state_*), if provided only these methods will be forwarded to nodes")
+
+	startCmd.Flags().StringSliceVar(
+		&deniedMethods,
+		"rpc-deny",
+		policy.DefaultDeniedMethods,
+		"[OPTIONAL] Comma separated list of rpc methods or glob patterns (e.g. author_*) that will never be forwarded to nodes")
+
+	startCmd.Flags().IntVar(
+		&batchChunkSize,
+		"batch-chunk-size",
+		100,
+		"[OPTIONAL] Maximum number of batch entries sent to single node, larger batches are split between nodes. 0 disables splitting")
+
+	startCmd.Flags().Float64Var(
+		&hedgePercentile,
+		"hedge-percentile",
+		0,
+		"[OPTIONAL] Value between 0-1 representing percentile of recent request latencies after which read only request is also sent to second node. 0 disables hedging")
+
+	startCmd.Flags().IntVar(
+		&broadcastSize,
+		"broadcast-extrinsics",
+		0,
+		"[OPTIONAL] Number of nodes to which extrinsic submission is sent at once, first extrinsic hash is returned. 0 disables broadcasting")
+
+	startCmd.Flags().IntVar(
+		&quorumSize,
+		"quorum-size",
+		quorum.DefaultQuorumSize,
+		"[OPTIONAL] Number of nodes that serve request in quorum mode, answer of majority of nodes is returned")
+
+	startCmd.Flags().StringSliceVar(
+		&quorumMethods,
+		"quorum-methods",
+		nil,
+		"[OPTIONAL] Comma separated list of rpc methods or glob patterns (e.g. state_getStorage) that are always served in quorum mode")
+
+	startCmd.Flags().Float64Var(
+		&quorumSample,
+		"quorum-sample",
+		0,
+		"[OPTIONAL] Value between 0-1 representing fraction of read only requests that are randomly served in quorum mode")
+
+	startCmd.Flags().BoolVar(
+		&anonymousAccess,
+		"anonymous-access",
+		true,
+		"[OPTIONAL] Allow requests without api key")
+
+	startCmd.Flags().Float64Var(
+		&anonymousRate,
+		"anonymous-rate-limit",
+		0,
+		"[OPTIONAL] Maximum number of requests per second for all requests without api key. 0 means unlimited")
+
+	startCmd.Flags().Int64Var(
+		&anonymousQuota,
+		"anonymous-quota",
+		0,
+		"[OPTIONAL] Maximum number of requests per day for all requests without api key. 0 means unlimited")
+
+	startCmd.Flags().Float64Var(
+		&ipRateLimit,
+		"ip-rate-limit",
+		0,
+		"[OPTIONAL] Maximum number of RPC requests and WS messages per second from single client IP. 0 means unlimited")
+
+	startCmd.Flags().IntVar(
+		&ipRateBurst,
+		"ip-rate-burst",
+		iplimit.DefaultBurst,
+		"[OPTIONAL] Number of requests client IP can send at once before being rate limited")
+
+	startCmd.Flags().IntVar(
+		&ipWSConnections,
+		"ws-connections-per-ip",
+		0,
+		"[OPTIONAL] Maximum number of concurrent WS connections from single client IP. 0 means unlimited")
+
+	startCmd.Flags().BoolVar(
+		&trustForwarded,
+		"trust-forwarded-for",
+		false,
+		"[OPTIONAL] Read client IP from X-Forwarded-For header, should be used only behind reverse proxy")
+
+	startCmd.Flags().IntVar(
+		&wsPoolSize,
+		"ws-pool-size",
+		0,
+		"[OPTIONAL] Number of WS connections to each node shared between clients, subscriptions to new heads are shared between clients. 0 disables sharing")
+
+	startCmd.Flags().BoolVar(
+		&wsFailover,
+		"ws-failover",
+		true,
+		"[OPTIONAL] Move WS clients to other node and restore their subscriptions if connection to node is lost")
+
+	startCmd.Flags().Int64Var(
+		&wsMaxMessage,
+		"ws-max-message-size",
+		ws.DefaultMaxMessageSize,
+		"[OPTIONAL] Maximum size in bytes of single WS message from client or node, larger messages close connection. 0 means unlimited")
+
+	startCmd.Flags().DurationVar(
+		&wsIdleTimeout,
+		"ws-idle-timeout",
+		ws.DefaultIdleTimeout,
+		"[OPTIONAL] WS connection without messages from or to client for this duration is closed. 0 disables idle timeout")
+
+	startCmd.Flags().DurationVar(
+		&wsPingInterval,
+		"ws-ping-interval",
+		ws.DefaultPingInterval,
+		"[OPTIONAL] Interval of pings sent to WS clients, client that doesn't answer within two intervals is disconnected. 0 disables pings")
+
+	startCmd.Flags().StringSliceVar(
+		&wsOrigins,
+		"ws-allowed-origins",
+		nil,
+		"[OPTIONAL] Comma separated list of origins or glob patterns (e.g. https://*.example.com) from which browsers can open WS connections, if omitted all origins are allowed")
+
+	startCmd.Flags().BoolVar(
+		&wsCompression,
+		"ws-compression",
+		false,
+		"[OPTIONAL] Enable permessage-deflate compression of WS messages for clients that support it")
 
 	startCmd.Flags().StringVar(
 		&certFile,
@@ -312,16 +718,60 @@ func startCommand(_ *cobra.Command, _ []string) {
 		}
 	}
 
+	breakerOptions := breaker.Options{
+		Window:       breakerWindow,
+		ErrorRate:    breakerRate,
+		RetryBudget:  breakerBudget,
+		OpenDuration: breakerOpenTime,
+		Trickle:      breakerTrickle,
+		Trips:        breakerTrips,
+	}
+
 	tunnel.StartHttpTunnelServer(tunnelServerPort, pPool)
 	loadbalancer.StartLoadBalancerServer(
 		configuration.Configuration{
 			AuthSecret:          authSecret,
+			PreviousAuthSecret:  prevAuthSecret,
+			AuthGracePeriod:     authGracePeriod,
+			AuthTokenTTL:        authTokenTTL,
+			AdminToken:          adminToken,
 			Name:                name,
 			CertFile:            certFile,
 			KeyFile:             keyFile,
 			Capacity:            capacity,
+			ChainGenesisHash:    genesisHash,
+			ChainName:           chainName,
+			ProbeInterval:       probeInterval,
+			ProbeDivergence:     probeDivergence,
+			PenaltyPolicies:     parsedPolicies,
+			PenaltyWindow:       penaltyWindow,
+			PenaltyDecay:        penaltyDecay,
+			Breaker:             breakerOptions,
 			Fee:                 fee,
 			Selection:           selection,
+			CacheSize:           cacheSize,
+			AllowedMethods:      allowedMethods,
+			DeniedMethods:       deniedMethods,
+			BatchChunkSize:      batchChunkSize,
+			HedgePercentile:     hedgePercentile,
+			BroadcastExtrinsics: broadcastSize,
+			QuorumSize:          quorumSize,
+			QuorumMethods:       quorumMethods,
+			QuorumSample:        quorumSample,
+			AnonymousAccess:     anonymousAccess,
+			AnonymousRateLimit:  anonymousRate,
+			AnonymousQuota:      anonymousQuota,
+			IPRateLimit:         ipRateLimit,
+			IPRateBurst:         ipRateBurst,
+			IPWSConnections:     ipWSConnections,
+			TrustForwardedFor:   trustForwarded,
+			WSPoolSize:          wsPoolSize,
+			WSFailover:          wsFailover,
+			WSMaxMessageSize:    wsMaxMessage,
+			WSIdleTimeout:       wsIdleTimeout,
+			WSPingInterval:      wsPingInterval,
+			WSAllowedOrigins:    wsOrigins,
+			WSCompression:       wsCompression,
 			Port:                serverPort,
 			TunnelServerAddress: tunnelServerAddress,
 			PortPool:            pPool,
@@ -3,6 +3,7 @@ module github.com/NodeFactoryIo/vedran
 go 1.15
 
 require (
+	github.com/ChainSafe/go-schnorrkel v0.0.0-20201021020641-d3c6d3118d10
 	github.com/asdine/storm/v3 v3.2.1
 	github.com/cenkalti/backoff v2.2.1+incompatible
 	github.com/centrifuge/go-substrate-rpc-client/v2 v2.1.0
@@ -22,5 +23,6 @@ require (
 	github.com/slok/go-http-metrics v0.9.0
 	github.com/spf13/cobra v1.0.0
 	github.com/stretchr/testify v1.6.1
+	github.com/vedhavyas/go-subkey v1.0.2
 	golang.org/x/net v0.0.0-20200822124328-c89045814202
 )
@@ -6,7 +6,7 @@ import (
 )
 
 type Actions interface {
-	PenalizeNode(node models.Node, repositories repositories.Repos, message string)
+	PenalizeNode(node models.Node, repositories repositories.Repos, reason string)
 }
 
 type actions struct{}
@@ -1,17 +1,25 @@
 package actions
 
 import (
+	"time"
+
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	"github.com/NodeFactoryIo/vedran/internal/schedule/penalize"
 	log "github.com/sirupsen/logrus"
 )
 
-const InitialPenalizeIntervalInMins = 1
+// PenalizeNode records failure of node for reason and once node reaches failure threshold of reason
+// policy, removes provided node from active nodes, sets cooldown defined by policy and schedules check
+// for penalized node by invoking penalize.ScheduleCheckForPenalizedNode
+func (a *actions) PenalizeNode(node models.Node, repositories repositories.Repos, reason string) {
+	now := time.Now()
+	if !penalty.RecordFailure(node.ID, reason, now) {
+		log.Debugf("Node %s failed because of %s, failure threshold not reached", node.ID, reason)
+		return
+	}
 
-// PenalizeNode removes provided node from active nodes, sets initial cooldown of 1 minute and schedules check for
-// penalized node by invoking penalize.ScheduleCheckForPenalizedNode
-func (a *actions) PenalizeNode(node models.Node, repositories repositories.Repos, message string) {
 	// remove node from active
 	err := repositories.NodeRepo.RemoveNodeFromActive(node.ID)
 	if err != nil {
@@ -20,13 +28,13 @@ func (a *actions) PenalizeNode(node models.Node, repositories repositories.Repos
 	}
 
 	// set new cooldown
-	node.Cooldown = InitialPenalizeIntervalInMins
+	penalty.Penalize(&node, reason, now)
 	err = repositories.NodeRepo.Save(&node)
 	if err != nil {
 		log.Errorf("Failed penalizing node %s because of: %v", node.ID, err)
 		return
 	}
 
-	log.Debugf("Penalized node %s, on cooldown for 1 minute, because %s ", node.ID, message)
+	log.Debugf("Penalized node %s, on cooldown for %d minutes, because %s ", node.ID, node.Cooldown, reason)
 	go penalize.ScheduleCheckForPenalizedNode(node, repositories)
 }
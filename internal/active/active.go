@@ -2,6 +2,7 @@ package active
 
 import (
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/probe"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	log "github.com/sirupsen/logrus"
 	"math"
@@ -14,8 +15,9 @@ const (
 	TargetBlockBuffer    = 10
 )
 
-// CheckIfNodeActive checks if nodes last recorded ping is in last IntervalFromLastPing and if nodes last recorded
-// BestBlockHeight and FinalizedBlockHeight are lagging more than AllowedBlocksBehind blocks
+// CheckIfNodeActive checks if nodes last recorded ping is in last IntervalFromLastPing, if nodes last recorded
+// BestBlockHeight and FinalizedBlockHeight are lagging more than AllowedBlocksBehind blocks and if node answered
+// its latest probe with heights that match its recorded metrics
 func CheckIfNodeActive(node models.Node, repos *repositories.Repos) (bool, error) {
 	isPingActive, err := CheckIfPingActive(node.ID, repos)
 	if !isPingActive {
@@ -27,6 +29,58 @@ func CheckIfNodeActive(node models.Node, repos *repositories.Repos) (bool, error
 		return false, err
 	}
 
+	isProbeSuccessful, err := CheckIfProbeSuccessful(node.ID, repos)
+	if !isProbeSuccessful {
+		return false, err
+	}
+
+	isMetricsMatchingProbe, err := CheckIfMetricsMatchProbe(node.ID, repos)
+	if !isMetricsMatchingProbe {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CheckIfProbeSuccessful checks if node answered its latest probe, node that wasn't probed recently
+// or any node if probing is disabled is considered successful
+func CheckIfProbeSuccessful(nodeID string, repos *repositories.Repos) (bool, error) {
+	latestProbe, err := findLatestProbe(nodeID, repos)
+	if latestProbe == nil {
+		return err == nil, err
+	}
+
+	if latestProbe.Error != "" {
+		log.Debugf("Node %s not active as probe failed because of: %s", nodeID, latestProbe.Error)
+		return false, nil
+	}
+	return true, nil
+}
+
+// CheckIfMetricsMatchProbe checks if heights node reported don't diverge from heights node
+// returned in its latest successful probe
+func CheckIfMetricsMatchProbe(nodeID string, repos *repositories.Repos) (bool, error) {
+	latestProbe, err := findLatestProbe(nodeID, repos)
+	if latestProbe == nil || latestProbe.Error != "" {
+		return err == nil, err
+	}
+
+	metrics, err := repos.MetricsRepo.FindByID(nodeID)
+	if err != nil {
+		return false, err
+	}
+
+	if probe.Diverges(*metrics, *latestProbe) {
+		log.Debugf(
+			"Node %s reported metrics diverge from probe. "+
+				"Reported: BestBlockHeight[%d], FinalizedBlockHeight[%d] "+
+				"Probed: BestBlockHeight[%d], FinalizedBlockHeight[%d]",
+			nodeID,
+			metrics.BestBlockHeight, metrics.FinalizedBlockHeight,
+			latestProbe.BestBlockHeight, latestProbe.FinalizedBlockHeight,
+		)
+		return false, nil
+	}
 	return true, nil
 }
 
@@ -93,6 +147,25 @@ func CheckIfMetricsValid(nodeID string, repos *repositories.Repos) (bool, error)
 	return true, nil
 }
 
+// findLatestProbe returns latest probe of node, nil is returned if probing is disabled or node
+// wasn't probed recently
+func findLatestProbe(nodeID string, repos *repositories.Repos) (*models.Probe, error) {
+	if !probe.IsEnabled() {
+		return nil, nil
+	}
+	latestProbe, err := repos.ProbeRepo.FindByNodeID(nodeID)
+	if err != nil {
+		if err.Error() == "not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if probe.IsStale(*latestProbe) {
+		return nil, nil
+	}
+	return latestProbe, nil
+}
+
 // ActivateNodeIfReady adds node to active nodes if latest metrics are valid and node is not penalized
 func ActivateNodeIfReady(nodeID string, repos repositories.Repos) error {
 	nodeIsOnCooldown, err := repos.NodeRepo.IsNodeOnCooldown(nodeID)
@@ -0,0 +1,318 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/pkg/ratelimit"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AnonymousName is name under which usage of requests without api key is tracked
+	AnonymousName = "anonymous"
+	// DateLayout is layout of date for which daily usage is tracked
+	DateLayout = "2006-01-02"
+	// DefaultFlushInterval is interval on which usage of api keys is saved to database
+	DefaultFlushInterval = 1 * time.Minute
+
+	// InvalidKeyTTL is time for which unknown api key is rejected without checking database
+	InvalidKeyTTL = 1 * time.Minute
+
+	keyLength = 16
+	// maxInvalidKeys limits number of cached unknown api keys
+	maxInvalidKeys = 10000
+)
+
+var (
+	ErrKeyRequired   = errors.New("api key required")
+	ErrInvalidKey    = errors.New("invalid api key")
+	ErrRateLimited   = errors.New("rate limit exceeded")
+	ErrQuotaExceeded = errors.New("daily quota exceeded")
+)
+
+type consumer struct {
+	name     string
+	bucket   *ratelimit.TokenBucket
+	quota    int64
+	date     string
+	requests int64
+	saved    int64
+}
+
+// Usage holds number of requests made by consumer on date
+type Usage struct {
+	Name     string
+	Key      string
+	Date     string
+	Requests int64
+}
+
+type contextKey struct{}
+
+var (
+	repo             repositories.APIKeyRepository
+	anonymousEnabled = true
+	anonymous        *consumer
+	consumers        = make(map[string]*consumer)
+	mutex            sync.Mutex
+
+	// invalidKeys maps unknown api keys to time until which they are rejected
+	invalidKeys = make(map[string]time.Time)
+
+	getNow = time.Now
+)
+
+// InitAPIKeys sets repository used for loading api keys and tier used for requests without
+// api key, rate limit is number of requests per second and quota number of requests per
+// day where 0 means unlimited
+func InitAPIKeys(apiKeyRepo repositories.APIKeyRepository, anonymousAccess bool, anonymousRateLimit float64, anonymousQuota int64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	repo = apiKeyRepo
+	anonymousEnabled = anonymousAccess
+	anonymous = newConsumer(AnonymousName, anonymousRateLimit, anonymousQuota)
+	consumers = make(map[string]*consumer)
+	invalidKeys = make(map[string]time.Time)
+}
+
+func newConsumer(name string, rateLimit float64, quota int64) *consumer {
+	c := &consumer{
+		name:  name,
+		quota: quota,
+		date:  today(),
+	}
+	if rateLimit > 0 {
+		c.bucket = ratelimit.NewTokenBucket(rateLimit, int(math.Ceil(rateLimit)))
+	}
+	return c
+}
+
+// Authorize checks if requests with key are allowed, empty key is used for anonymous requests
+func Authorize(key string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	_, err := getConsumer(key)
+	return err
+}
+
+// Use checks if request with key is allowed by rate limit and daily quota of key and
+// counts request in usage of key, empty key is used for anonymous requests
+func Use(key string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	c, err := getConsumer(key)
+	if err != nil {
+		return err
+	}
+
+	date := today()
+	if c.date != date {
+		if key != "" && c.requests != c.saved {
+			go saveUsage(repo, key, c.date, c.requests)
+		}
+		c.date = date
+		c.requests = 0
+		c.saved = 0
+	}
+
+	if c.quota > 0 && c.requests >= c.quota {
+		return ErrQuotaExceeded
+	}
+	if c.bucket != nil && !c.bucket.Allow() {
+		return ErrRateLimited
+	}
+	c.requests++
+	return nil
+}
+
+// getConsumer returns consumer for key, loading api key and its usage for today from
+// database on first use. Must be called with mutex held
+func getConsumer(key string) (*consumer, error) {
+	if key == "" {
+		if !anonymousEnabled {
+			return nil, ErrKeyRequired
+		}
+		if anonymous == nil {
+			anonymous = newConsumer(AnonymousName, 0, 0)
+		}
+		return anonymous, nil
+	}
+
+	if c, ok := consumers[key]; ok {
+		return c, nil
+	}
+	if repo == nil {
+		return nil, ErrInvalidKey
+	}
+	if until, ok := invalidKeys[key]; ok {
+		if getNow().Before(until) {
+			return nil, ErrInvalidKey
+		}
+		delete(invalidKeys, key)
+	}
+
+	apiKey, err := repo.FindByKey(key)
+	if err != nil {
+		if err.Error() == "not found" {
+			rememberInvalidKey(key)
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	c := newConsumer(apiKey.Name, apiKey.RateLimit, apiKey.DailyQuota)
+	c.requests, err = repo.FindUsage(key, c.date)
+	if err != nil {
+		return nil, err
+	}
+	c.saved = c.requests
+	consumers[key] = c
+	return c, nil
+}
+
+// rememberInvalidKey caches unknown api key so repeated requests with it don't reach
+// database, expired keys are removed once cache is full. Must be called with mutex held
+func rememberInvalidKey(key string) {
+	now := getNow()
+	if len(invalidKeys) >= maxInvalidKeys {
+		for k, until := range invalidKeys {
+			if !now.Before(until) {
+				delete(invalidKeys, k)
+			}
+		}
+		if len(invalidKeys) >= maxInvalidKeys {
+			return
+		}
+	}
+	invalidKeys[key] = now.Add(InvalidKeyTTL)
+}
+
+// Forget removes cached api key, should be called when api key is changed or revoked
+func Forget(key string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(consumers, key)
+	delete(invalidKeys, key)
+}
+
+// RequestsToday returns number of requests made with key today
+func RequestsToday(key string) int64 {
+	mutex.Lock()
+	defer mutex.Unlock()
+	c, ok := consumers[key]
+	if key == "" {
+		c, ok = anonymous, anonymous != nil
+	}
+	if !ok || c.date != today() {
+		return 0
+	}
+	return c.requests
+}
+
+// CurrentUsage returns usage for today of anonymous tier and all api keys used since start
+func CurrentUsage() []Usage {
+	mutex.Lock()
+	defer mutex.Unlock()
+	date := today()
+	var usage []Usage
+	if anonymous != nil && anonymousEnabled {
+		usage = append(usage, Usage{Name: AnonymousName, Date: date, Requests: requestsOn(anonymous, date)})
+	}
+	for key, c := range consumers {
+		usage = append(usage, Usage{Name: c.name, Key: key, Date: date, Requests: requestsOn(c, date)})
+	}
+	return usage
+}
+
+func requestsOn(c *consumer, date string) int64 {
+	if c.date != date {
+		return 0
+	}
+	return c.requests
+}
+
+// Flush saves usage of api keys that changed since last flush to database
+func Flush() {
+	mutex.Lock()
+	var changed []Usage
+	for key, c := range consumers {
+		if c.requests != c.saved {
+			changed = append(changed, Usage{Key: key, Date: c.date, Requests: c.requests})
+			c.saved = c.requests
+		}
+	}
+	usageRepo := repo
+	mutex.Unlock()
+
+	for _, u := range changed {
+		saveUsage(usageRepo, u.Key, u.Date, u.Requests)
+	}
+}
+
+// StartScheduledFlush starts task that saves usage of api keys on interval
+func StartScheduledFlush(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			Flush()
+		}
+	}()
+}
+
+// saveUsage takes repo set when usage was collected, so usage is not saved to repo set afterwards
+func saveUsage(usageRepo repositories.APIKeyRepository, key string, date string, requests int64) {
+	if usageRepo == nil {
+		return
+	}
+	err := usageRepo.SaveUsage(key, date, requests)
+	if err != nil {
+		log.Errorf("Failed saving usage for api key because of: %v", err)
+	}
+}
+
+// GenerateKey returns new random api key
+func GenerateKey() (string, error) {
+	b := make([]byte, keyLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewAPIKey creates api key with random key
+func NewAPIKey(name string, rateLimit float64, dailyQuota int64) (*models.APIKey, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &models.APIKey{
+		Key:        key,
+		Name:       name,
+		RateLimit:  rateLimit,
+		DailyQuota: dailyQuota,
+		CreatedAt:  getNow(),
+	}, nil
+}
+
+// WithKey returns copy of context that carries api key
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns api key carried by context, empty key is returned for anonymous requests
+func FromContext(ctx context.Context) string {
+	key, _ := ctx.Value(contextKey{}).(string)
+	return key
+}
+
+func today() string {
+	return getNow().UTC().Format(DateLayout)
+}
@@ -0,0 +1,188 @@
+package apikey
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUse(t *testing.T) {
+	current := time.Date(2020, 10, 10, 12, 0, 0, 0, time.UTC)
+	getNow = func() time.Time { return current }
+	defer func() { getNow = time.Now }()
+
+	tests := []struct {
+		name             string
+		key              string
+		anonymousAccess  bool
+		anonymousQuota   int64
+		apiKey           *models.APIKey
+		findByKeyError   error
+		savedUsage       int64
+		numberOfRequests int
+		wantAllowed      int
+		wantErr          error
+	}{
+		{
+			name:             "anonymous requests are allowed without limits",
+			key:              "",
+			anonymousAccess:  true,
+			numberOfRequests: 5,
+			wantAllowed:      5,
+		},
+		{
+			name:             "anonymous requests are rejected if anonymous access is disabled",
+			key:              "",
+			anonymousAccess:  false,
+			numberOfRequests: 1,
+			wantAllowed:      0,
+			wantErr:          ErrKeyRequired,
+		},
+		{
+			name:             "anonymous requests over quota are rejected",
+			key:              "",
+			anonymousAccess:  true,
+			anonymousQuota:   2,
+			numberOfRequests: 3,
+			wantAllowed:      2,
+			wantErr:          ErrQuotaExceeded,
+		},
+		{
+			name:             "unknown api key is rejected",
+			key:              "unknown",
+			anonymousAccess:  true,
+			findByKeyError:   errors.New("not found"),
+			numberOfRequests: 1,
+			wantAllowed:      0,
+			wantErr:          ErrInvalidKey,
+		},
+		{
+			name:             "requests over quota of api key are rejected",
+			key:              "key",
+			apiKey:           &models.APIKey{Key: "key", Name: "consumer", DailyQuota: 10},
+			savedUsage:       8,
+			numberOfRequests: 3,
+			wantAllowed:      2,
+			wantErr:          ErrQuotaExceeded,
+		},
+		{
+			name:             "requests over rate limit of api key are rejected",
+			key:              "key",
+			apiKey:           &models.APIKey{Key: "key", Name: "consumer", RateLimit: 1},
+			numberOfRequests: 2,
+			wantAllowed:      1,
+			wantErr:          ErrRateLimited,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiKeyRepoMock := mocks.APIKeyRepository{}
+			apiKeyRepoMock.On("FindByKey", test.key).Return(test.apiKey, test.findByKeyError)
+			apiKeyRepoMock.On("FindUsage", test.key, "2020-10-10").Return(test.savedUsage, nil)
+			InitAPIKeys(&apiKeyRepoMock, test.anonymousAccess, 0, test.anonymousQuota)
+
+			allowed := 0
+			var err error
+			for i := 0; i < test.numberOfRequests; i++ {
+				if err = Use(test.key); err == nil {
+					allowed++
+				}
+			}
+			assert.Equal(t, test.wantAllowed, allowed)
+			assert.Equal(t, test.wantErr, err)
+		})
+	}
+}
+
+func TestUse_ResetsUsageOnNewDay(t *testing.T) {
+	current := time.Date(2020, 10, 10, 23, 0, 0, 0, time.UTC)
+	getNow = func() time.Time { return current }
+	defer func() { getNow = time.Now }()
+
+	apiKeyRepoMock := mocks.APIKeyRepository{}
+	apiKeyRepoMock.On("FindByKey", "key").Return(&models.APIKey{Key: "key", DailyQuota: 1}, nil)
+	apiKeyRepoMock.On("FindUsage", "key", "2020-10-10").Return(int64(0), nil)
+	apiKeyRepoMock.On("SaveUsage", "key", mock.Anything, mock.Anything).Return(nil)
+	InitAPIKeys(&apiKeyRepoMock, true, 0, 0)
+
+	assert.NoError(t, Use("key"))
+	assert.Equal(t, ErrQuotaExceeded, Use("key"))
+	assert.Equal(t, int64(1), RequestsToday("key"))
+
+	current = current.Add(2 * time.Hour)
+	assert.Equal(t, int64(0), RequestsToday("key"))
+	assert.NoError(t, Use("key"))
+	assert.Equal(t, int64(1), RequestsToday("key"))
+}
+
+func TestFlush(t *testing.T) {
+	current := time.Date(2020, 10, 10, 12, 0, 0, 0, time.UTC)
+	getNow = func() time.Time { return current }
+	defer func() { getNow = time.Now }()
+
+	apiKeyRepoMock := mocks.APIKeyRepository{}
+	apiKeyRepoMock.On("FindByKey", "key").Return(&models.APIKey{Key: "key"}, nil)
+	apiKeyRepoMock.On("FindUsage", "key", "2020-10-10").Return(int64(3), nil)
+	apiKeyRepoMock.On("SaveUsage", "key", "2020-10-10", int64(5)).Return(nil).Once()
+	InitAPIKeys(&apiKeyRepoMock, true, 0, 0)
+
+	// unchanged usage is not saved
+	assert.NoError(t, Authorize("key"))
+	Flush()
+	apiKeyRepoMock.AssertNotCalled(t, "SaveUsage", mock.Anything, mock.Anything, mock.Anything)
+
+	assert.NoError(t, Use("key"))
+	assert.NoError(t, Use("key"))
+	Flush()
+	Flush()
+	apiKeyRepoMock.AssertNumberOfCalls(t, "SaveUsage", 1)
+}
+
+func TestForget(t *testing.T) {
+	apiKeyRepoMock := mocks.APIKeyRepository{}
+	apiKeyRepoMock.On("FindByKey", "key").Return(&models.APIKey{Key: "key"}, nil).Once()
+	apiKeyRepoMock.On("FindByKey", "key").Return(nil, errors.New("not found"))
+	apiKeyRepoMock.On("FindUsage", "key", mock.Anything).Return(int64(0), nil)
+	InitAPIKeys(&apiKeyRepoMock, true, 0, 0)
+
+	assert.NoError(t, Authorize("key"))
+	// api key is cached after first use
+	assert.NoError(t, Authorize("key"))
+
+	Forget("key")
+	assert.Equal(t, ErrInvalidKey, Authorize("key"))
+}
+
+func TestAuthorize_CachesInvalidKey(t *testing.T) {
+	current := time.Date(2020, 10, 10, 12, 0, 0, 0, time.UTC)
+	getNow = func() time.Time { return current }
+	defer func() { getNow = time.Now }()
+
+	apiKeyRepoMock := mocks.APIKeyRepository{}
+	apiKeyRepoMock.On("FindByKey", "unknown").Return(nil, errors.New("not found"))
+	InitAPIKeys(&apiKeyRepoMock, true, 0, 0)
+
+	assert.Equal(t, ErrInvalidKey, Authorize("unknown"))
+	// unknown api key is rejected without database lookup until cache expires
+	assert.Equal(t, ErrInvalidKey, Authorize("unknown"))
+	apiKeyRepoMock.AssertNumberOfCalls(t, "FindByKey", 1)
+
+	current = current.Add(InvalidKeyTTL)
+	assert.Equal(t, ErrInvalidKey, Authorize("unknown"))
+	apiKeyRepoMock.AssertNumberOfCalls(t, "FindByKey", 2)
+}
+
+func TestGenerateKey(t *testing.T) {
+	first, err := GenerateKey()
+	assert.NoError(t, err)
+	second, err := GenerateKey()
+	assert.NoError(t, err)
+
+	assert.Len(t, first, 2*keyLength)
+	assert.NotEqual(t, first, second)
+}
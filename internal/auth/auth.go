@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"github.com/dgrijalva/jwt-go"
 	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
 )
 
 var authSecret string
@@ -31,10 +35,25 @@ type CustomClaims struct {
 	jwt.StandardClaims
 }
 
+// CreateNewToken returns token with unique id that expires after token ttl
 func CreateNewToken(nodeId string) (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := CustomClaims{
 		Authorized: true,
 		NodeId:     nodeId,
+		StandardClaims: jwt.StandardClaims{
+			Id:       hex.EncodeToString(id),
+			IssuedAt: now.Unix(),
+		},
+	}
+	if tokenTTL > 0 {
+		claims.ExpiresAt = now.Add(tokenTTL).Unix()
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(authSecret))
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestSetAuthSecret(t *testing.T) {
@@ -47,6 +48,8 @@ func TestCreateNewToken(t *testing.T) {
 	}{
 		{name: "Valid token with claims generated"},
 	}
+	InitTokenOptions(time.Hour, "", 0)
+	defer InitTokenOptions(0, "", 0)
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			jwtToken, err := CreateNewToken("test-node-1")
@@ -58,6 +61,8 @@ func TestCreateNewToken(t *testing.T) {
 			claims, ok := token.Claims.(*CustomClaims)
 			assert.True(t, ok, "Should contain custom claims")
 			assert.Equal(t, "test-node-1", claims.NodeId, "Claims should have nodeId")
+			assert.NotEmpty(t, claims.Id, "Claims should have token id")
+			assert.InDelta(t, time.Now().Add(time.Hour).Unix(), claims.ExpiresAt, 1, "Token should expire after ttl")
 		})
 	}
 }
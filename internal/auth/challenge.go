@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ChallengeTTL is time node has to sign challenge and register
+const ChallengeTTL = 5 * time.Minute
+
+var (
+	ErrInvalidChallenge = errors.New("challenge is missing or expired")
+	ErrInvalidSignature = errors.New("invalid challenge signature")
+)
+
+type challenge struct {
+	nodeID    string
+	value     string
+	expiresAt time.Time
+}
+
+var (
+	// challenges maps nonce returned with challenge to challenge, node can have
+	// multiple challenges at once
+	challenges     = map[string]challenge{}
+	challengeMutex sync.Mutex
+)
+
+// NewChallenge returns nonce and random challenge node has to sign with key of its payout
+// address to register, nonce identifies challenge when node registers
+func NewChallenge(nodeID string) (string, string, error) {
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+	value, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	challengeMutex.Lock()
+	defer challengeMutex.Unlock()
+	now := time.Now()
+	for n, c := range challenges {
+		if now.After(c.expiresAt) {
+			delete(challenges, n)
+		}
+	}
+	challenges[nonce] = challenge{nodeID: nodeID, value: value, expiresAt: now.Add(ChallengeTTL)}
+	return nonce, value, nil
+}
+
+// VerifyChallenge checks that hex encoded signature of challenge issued for node under nonce
+// was made with key behind address, challenge is removed once signature is verified
+func VerifyChallenge(nonce string, nodeID string, address string, signature string) error {
+	challengeMutex.Lock()
+	c, ok := challenges[nonce]
+	challengeMutex.Unlock()
+
+	if !ok || c.nodeID != nodeID || time.Now().After(c.expiresAt) {
+		return ErrInvalidChallenge
+	}
+	sig, err := hex.DecodeString(trimHexPrefix(signature))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !VerifyAddressSignature(address, []byte(c.value), sig) {
+		return ErrInvalidSignature
+	}
+
+	// challenge can be used only once, so only one of concurrent registrations succeeds
+	challengeMutex.Lock()
+	defer challengeMutex.Unlock()
+	if _, ok := challenges[nonce]; !ok {
+		return ErrInvalidChallenge
+	}
+	delete(challenges, nonce)
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func trimHexPrefix(s string) string {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:]
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vedhavyas/go-subkey"
+	"github.com/vedhavyas/go-subkey/sr25519"
+)
+
+func TestVerifyChallenge(t *testing.T) {
+	owner, _ := sr25519.Scheme{}.Generate()
+	ownerAddress, _ := owner.SS58Address(42)
+	other, _ := sr25519.Scheme{}.Generate()
+	edPublic, edPrivate, _ := ed25519.GenerateKey(nil)
+	edAddress, _ := subkey.SS58Address(edPublic, 42)
+
+	tests := []struct {
+		name        string
+		address     string
+		sign        func(challenge string) string
+		useTwice    bool
+		noChallenge bool
+		err         error
+	}{
+		{
+			name:    "sr25519 signature of payout address key",
+			address: ownerAddress,
+			sign: func(challenge string) string {
+				sig, _ := owner.Sign([]byte(challenge))
+				return "0x" + hex.EncodeToString(sig)
+			},
+		},
+		{
+			name:    "sr25519 signature of challenge wrapped in bytes tags",
+			address: ownerAddress,
+			sign: func(challenge string) string {
+				sig, _ := owner.Sign([]byte("<Bytes>" + challenge + "</Bytes>"))
+				return hex.EncodeToString(sig)
+			},
+		},
+		{
+			name:    "ed25519 signature of payout address key",
+			address: edAddress,
+			sign: func(challenge string) string {
+				return hex.EncodeToString(ed25519.Sign(edPrivate, []byte(challenge)))
+			},
+		},
+		{
+			name:    "signature of other key",
+			address: ownerAddress,
+			sign: func(challenge string) string {
+				sig, _ := other.Sign([]byte(challenge))
+				return hex.EncodeToString(sig)
+			},
+			err: ErrInvalidSignature,
+		},
+		{
+			name:    "invalid address",
+			address: "invalid-address",
+			sign: func(challenge string) string {
+				sig, _ := owner.Sign([]byte(challenge))
+				return hex.EncodeToString(sig)
+			},
+			err: ErrInvalidSignature,
+		},
+		{
+			name:    "challenge used twice",
+			address: ownerAddress,
+			sign: func(challenge string) string {
+				sig, _ := owner.Sign([]byte(challenge))
+				return hex.EncodeToString(sig)
+			},
+			useTwice: true,
+			err:      ErrInvalidChallenge,
+		},
+		{
+			name:        "challenge not requested",
+			address:     ownerAddress,
+			sign:        func(challenge string) string { return "" },
+			noChallenge: true,
+			err:         ErrInvalidChallenge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var nonce, challenge string
+			if !test.noChallenge {
+				var err error
+				nonce, challenge, err = NewChallenge("test-node")
+				assert.NoError(t, err)
+			}
+			signature := test.sign(challenge)
+			if test.useTwice {
+				assert.NoError(t, VerifyChallenge(nonce, "test-node", test.address, signature))
+			}
+			assert.Equal(t, test.err, VerifyChallenge(nonce, "test-node", test.address, signature))
+		})
+	}
+}
+
+func TestVerifyChallenge_MultipleChallenges(t *testing.T) {
+	owner, _ := sr25519.Scheme{}.Generate()
+	ownerAddress, _ := owner.SS58Address(42)
+	other, _ := sr25519.Scheme{}.Generate()
+	sign := func(signer subkey.KeyPair, challenge string) string {
+		sig, _ := signer.Sign([]byte(challenge))
+		return hex.EncodeToString(sig)
+	}
+
+	// requesting new challenge doesn't invalidate previous challenge of node
+	firstNonce, first, _ := NewChallenge("test-node")
+	secondNonce, second, _ := NewChallenge("test-node")
+	assert.NotEqual(t, firstNonce, secondNonce)
+
+	// challenge can't be used by other node
+	assert.Equal(t, ErrInvalidChallenge, VerifyChallenge(firstNonce, "other-node", ownerAddress, sign(owner, first)))
+
+	// failed signature check doesn't remove challenge
+	assert.Equal(t, ErrInvalidSignature, VerifyChallenge(firstNonce, "test-node", ownerAddress, sign(other, first)))
+	assert.NoError(t, VerifyChallenge(firstNonce, "test-node", ownerAddress, sign(owner, first)))
+	assert.NoError(t, VerifyChallenge(secondNonce, "test-node", ownerAddress, sign(owner, second)))
+}
@@ -14,22 +14,23 @@ const RequestContextKey = ContextKey("request")
 type RequestContext struct {
 	NodeId    string
 	Timestamp time.Time
+	// Claims of token used for request
+	Claims *CustomClaims
 }
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		jwtToken := r.Header.Get("X-Auth-Header")
-		token, err := ParseJwtTokenWithCustomClaims(jwtToken)
+		claims, err := ValidateToken(jwtToken)
 		if err == nil {
-			if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-				c := &RequestContext{
-					NodeId:    claims.NodeId,
-					Timestamp: time.Now(),
-				}
-				ctx := context.WithValue(r.Context(), RequestContextKey, c)
-				next.ServeHTTP(w, r.WithContext(ctx))
-				return
+			c := &RequestContext{
+				NodeId:    claims.NodeId,
+				Timestamp: time.Now(),
+				Claims:    claims,
 			}
+			ctx := context.WithValue(r.Context(), RequestContextKey, c)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
 		}
 
 		log.Errorf("Unauthorized request: %v", err)
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// revokedTokens maps id of revoked token to its expiry, 0 if token doesn't expire
+	revokedTokens = map[string]int64{}
+	revokedMutex  sync.RWMutex
+)
+
+// RevokeToken rejects token with id until it expires, expired tokens are removed from list
+func RevokeToken(id string, expiresAt int64) {
+	revokedMutex.Lock()
+	defer revokedMutex.Unlock()
+
+	now := time.Now().Unix()
+	for revokedID, expiry := range revokedTokens {
+		if expiry != 0 && expiry < now {
+			delete(revokedTokens, revokedID)
+		}
+	}
+	revokedTokens[id] = expiresAt
+}
+
+// IsTokenRevoked returns true if token with id was revoked
+func IsTokenRevoked(id string) bool {
+	revokedMutex.RLock()
+	defer revokedMutex.RUnlock()
+	_, revoked := revokedTokens[id]
+	return revoked
+}
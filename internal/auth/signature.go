@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"errors"
+
+	schnorrkel "github.com/ChainSafe/go-schnorrkel"
+	"github.com/decred/base58"
+	"github.com/vedhavyas/go-subkey"
+)
+
+// DecodeAddress returns public key behind SS58 address
+func DecodeAddress(address string) ([]byte, error) {
+	decoded := base58.Decode(address)
+	// network identifier, 32 bytes of public key and 2 bytes of checksum
+	if len(decoded) != 35 {
+		return nil, errors.New("invalid address length")
+	}
+	publicKey := decoded[1:33]
+	encoded, err := subkey.SS58Address(publicKey, decoded[0])
+	if err != nil || encoded != address {
+		return nil, errors.New("invalid address checksum")
+	}
+	return publicKey, nil
+}
+
+// VerifyAddressSignature returns true if message was signed with sr25519 or ed25519 key behind
+// address. Messages wrapped in <Bytes> tags, as signed by polkadot.js extension, are also accepted
+func VerifyAddressSignature(address string, message []byte, signature []byte) bool {
+	publicKey, err := DecodeAddress(address)
+	if err != nil || len(signature) != 64 {
+		return false
+	}
+	wrapped := append(append([]byte("<Bytes>"), message...), []byte("</Bytes>")...)
+	for _, msg := range [][]byte{message, wrapped} {
+		if verifySr25519(publicKey, msg, signature) || ed25519.Verify(publicKey, msg, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func verifySr25519(publicKey []byte, message []byte, signature []byte) bool {
+	var key [32]byte
+	copy(key[:], publicKey)
+	var sigBytes [64]byte
+	copy(sigBytes[:], signature)
+
+	sig := new(schnorrkel.Signature)
+	if err := sig.Decode(sigBytes); err != nil {
+		return false
+	}
+	return schnorrkel.NewPublicKey(key).Verify(sig, schnorrkel.NewSigningContext([]byte("substrate"), message))
+}
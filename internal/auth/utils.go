@@ -1,15 +1,92 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
+	"time"
+
 	"github.com/dgrijalva/jwt-go"
 )
 
+var (
+	tokenTTL time.Duration
+	// previousSecret is accepted until previousSecretExpiry so nodes can refresh tokens
+	// after auth secret is rotated
+	previousSecret       string
+	previousSecretExpiry time.Time
+	// legacyTokenExpiry is time until which tokens issued without id or expiry are accepted
+	// if tokens expire, so nodes can refresh them
+	legacyTokenExpiry time.Time
+)
+
+// InitTokenOptions sets for how long new tokens are valid, 0 means tokens never expire.
+// If previous auth secret is set, tokens signed with it are accepted during grace period.
+// Tokens issued without id or expiry are accepted if tokens never expire, otherwise only
+// during grace period
+func InitTokenOptions(ttl time.Duration, secret string, gracePeriod time.Duration) {
+	tokenTTL = ttl
+	previousSecret = secret
+	previousSecretExpiry = time.Now().Add(gracePeriod)
+	legacyTokenExpiry = previousSecretExpiry
+}
+
 func ParseJwtTokenWithCustomClaims(jwtToken string) (*jwt.Token, error) {
-	return jwt.ParseWithClaims(jwtToken, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+	token, err := parseWithSecret(jwtToken, authSecret, nil)
+	if isSignatureInvalid(err) && previousSecret != "" && time.Now().Before(previousSecretExpiry) {
+		return parseWithSecret(jwtToken, previousSecret, nil)
+	}
+	return token, err
+}
+
+// ParseTokenClaims returns claims of token signed with one of auth secrets, even if
+// token already expired
+func ParseTokenClaims(jwtToken string) (*CustomClaims, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parseWithSecret(jwtToken, authSecret, parser)
+	if isSignatureInvalid(err) && previousSecret != "" {
+		token, err = parseWithSecret(jwtToken, previousSecret, parser)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token.Claims.(*CustomClaims), nil
+}
+
+// ValidateToken returns claims of token if token is signed with valid auth secret,
+// has not expired and was not revoked
+func ValidateToken(jwtToken string) (*CustomClaims, error) {
+	token, err := ParseJwtTokenWithCustomClaims(jwtToken)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	// tokens issued before tokens got ids and expiry are accepted only until they can be refreshed
+	legacy := claims.Id == "" || (tokenTTL > 0 && claims.ExpiresAt == 0)
+	if legacy && tokenTTL > 0 && time.Now().After(legacyTokenExpiry) {
+		return nil, errors.New("token has no id or expiry")
+	}
+	if claims.Id != "" && IsTokenRevoked(claims.Id) {
+		return nil, errors.New("token is revoked")
+	}
+	return claims, nil
+}
+
+func parseWithSecret(jwtToken string, secret string, parser *jwt.Parser) (*jwt.Token, error) {
+	if parser == nil {
+		parser = &jwt.Parser{}
+	}
+	return parser.ParseWithClaims(jwtToken, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(authSecret), nil
+		return []byte(secret), nil
 	})
 }
+
+func isSignatureInvalid(err error) bool {
+	var validationErr *jwt.ValidationError
+	return errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func signToken(t *testing.T, secret string, claims CustomClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+func TestValidateToken(t *testing.T) {
+	defer func() {
+		authSecret = ""
+		InitTokenOptions(0, "", 0)
+	}()
+	authSecret = "new-secret"
+	now := time.Now()
+	claims := func(id string, expiresAt time.Time) CustomClaims {
+		return CustomClaims{
+			Authorized: true,
+			NodeId:     "test-node",
+			StandardClaims: jwt.StandardClaims{
+				Id:        id,
+				ExpiresAt: expiresAt.Unix(),
+			},
+		}
+	}
+	RevokeToken("revoked", now.Add(time.Hour).Unix())
+
+	tests := []struct {
+		name        string
+		token       string
+		gracePeriod time.Duration
+		shouldFail  bool
+	}{
+		{
+			name:  "Valid token",
+			token: signToken(t, "new-secret", claims("1", now.Add(time.Hour))),
+		},
+		{
+			name:       "Expired token",
+			token:      signToken(t, "new-secret", claims("2", now.Add(-time.Minute))),
+			shouldFail: true,
+		},
+		{
+			name:       "Revoked token",
+			token:      signToken(t, "new-secret", claims("revoked", now.Add(time.Hour))),
+			shouldFail: true,
+		},
+		{
+			name:        "Token without expiry during grace period",
+			token:       signToken(t, "new-secret", CustomClaims{Authorized: true, NodeId: "test-node"}),
+			gracePeriod: time.Hour,
+		},
+		{
+			name:       "Token without expiry after grace period",
+			token:      signToken(t, "new-secret", CustomClaims{Authorized: true, NodeId: "test-node"}),
+			shouldFail: true,
+		},
+		{
+			name:        "Token signed with previous secret during grace period",
+			token:       signToken(t, "old-secret", claims("3", now.Add(time.Hour))),
+			gracePeriod: time.Hour,
+		},
+		{
+			name:       "Token signed with previous secret after grace period",
+			token:      signToken(t, "old-secret", claims("4", now.Add(time.Hour))),
+			shouldFail: true,
+		},
+		{
+			name:        "Token signed with unknown secret",
+			token:       signToken(t, "unknown-secret", claims("5", now.Add(time.Hour))),
+			gracePeriod: time.Hour,
+			shouldFail:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			InitTokenOptions(time.Hour, "old-secret", test.gracePeriod)
+
+			c, err := ValidateToken(test.token)
+			if test.shouldFail {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "test-node", c.NodeId)
+			}
+		})
+	}
+}
+
+func TestValidateToken_WithoutTTL(t *testing.T) {
+	defer func() {
+		authSecret = ""
+		InitTokenOptions(0, "", 0)
+	}()
+	authSecret = "new-secret"
+	InitTokenOptions(0, "", 0)
+
+	// tokens issued without id or expiry stay valid if tokens don't expire
+	c, err := ValidateToken(signToken(t, "new-secret", CustomClaims{Authorized: true, NodeId: "test-node"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node", c.NodeId)
+}
+
+func TestParseTokenClaims(t *testing.T) {
+	defer func() {
+		authSecret = ""
+		InitTokenOptions(0, "", 0)
+	}()
+	authSecret = "new-secret"
+	InitTokenOptions(time.Hour, "old-secret", 0)
+
+	expired := signToken(t, "old-secret", CustomClaims{
+		NodeId:         "test-node",
+		StandardClaims: jwt.StandardClaims{Id: "1", ExpiresAt: time.Now().Add(-time.Hour).Unix()},
+	})
+	claims, err := ParseTokenClaims(expired)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", claims.Id)
+
+	_, err = ParseTokenClaims(signToken(t, "unknown-secret", CustomClaims{NodeId: "test-node"}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+package blockheight
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxKnownHashes is maximum number of block hashes whose block number is remembered
+	MaxKnownHashes = 10000
+	// UnknownHashLag is number of blocks node can be behind most up to date active node
+	// and still serve requests for unknown block hash
+	UnknownHashLag = 3
+)
+
+// Heights holds last known block heights of node
+type Heights struct {
+	Best      int64
+	Finalized int64
+}
+
+var (
+	nodes = make(map[string]Heights)
+	// probed holds block heights of nodes observed by probes
+	probed = make(map[string]Heights)
+	// hashes maps block hash to block number, hashOrder is used for removing oldest hashes
+	hashes    = make(map[string]int64)
+	hashOrder []string
+	mutex     sync.RWMutex
+)
+
+// Load sets block heights of nodes from last saved metrics
+func Load(metricsRepo repositories.MetricsRepository) {
+	metrics, err := metricsRepo.GetAll()
+	if err != nil {
+		log.Errorf("Unable to load block heights of nodes because of: %v", err)
+		return
+	}
+	for _, m := range *metrics {
+		UpdateNode(m.NodeId, m.BestBlockHeight, m.FinalizedBlockHeight)
+	}
+}
+
+// UpdateNode sets block heights reported by node
+func UpdateNode(nodeID string, best int64, finalized int64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	nodes[nodeID] = Heights{Best: best, Finalized: finalized}
+}
+
+// UpdateProbedNode sets block heights of node observed by probe
+func UpdateProbedNode(nodeID string, best int64, finalized int64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	probed[nodeID] = Heights{Best: best, Finalized: finalized}
+}
+
+// RemoveNode forgets block heights of node that left pool or was deactivated
+func RemoveNode(nodeID string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(nodes, nodeID)
+	delete(probed, nodeID)
+}
+
+// FinalizedHeight returns block height that is finalized in pool of nodes. Probed heights are
+// preferred over heights nodes report and lower median of nodes is used, so single node that
+// reports too high finalized height can't make block seem finalized
+func FinalizedHeight() int64 {
+	mutex.RLock()
+	heights := make([]int64, 0, len(nodes)+len(probed))
+	for _, h := range probed {
+		heights = append(heights, h.Finalized)
+	}
+	for nodeID, h := range nodes {
+		if _, ok := probed[nodeID]; !ok {
+			heights = append(heights, h.Finalized)
+		}
+	}
+	mutex.RUnlock()
+
+	if len(heights) == 0 {
+		return 0
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights[(len(heights)-1)/2]
+}
+
+// NodeHeights returns last known block heights of node
+func NodeHeights(nodeID string) (Heights, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	heights, ok := nodes[nodeID]
+	return heights, ok
+}
+
+// RecordBlockHash remembers block number of block hash
+func RecordBlockHash(hash string, number int64) {
+	hash = strings.ToLower(hash)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, ok := hashes[hash]; !ok {
+		hashOrder = append(hashOrder, hash)
+		if len(hashOrder) > MaxKnownHashes {
+			delete(hashes, hashOrder[0])
+			hashOrder = hashOrder[1:]
+		}
+	}
+	hashes[hash] = number
+}
+
+// RequiredHeight returns block height node must have to serve request. Requests for unknown
+// block hash require height of most up to date active node less UnknownHashLag, as unknown
+// hash is most likely new block
+func RequiredHeight(req rpc.RPCRequest, activeNodes []models.Node) int64 {
+	if number, ok := rpc.BlockNumberParam(req); ok {
+		return number
+	}
+	hash, ok := rpc.BlockHashParam(req)
+	if !ok {
+		return 0
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if number, ok := hashes[strings.ToLower(hash)]; ok {
+		return number
+	}
+	var best int64
+	for _, node := range activeNodes {
+		if height, ok := bestHeight(node.ID); ok && height > best {
+			best = height
+		}
+	}
+	return best - UnknownHashLag
+}
+
+// FilterNodes returns nodes whose best block height is at least minHeight, order of nodes is preserved.
+// Probed height of node is used if node was probed
+func FilterNodes(activeNodes []models.Node, minHeight int64) []models.Node {
+	if minHeight <= 0 {
+		return activeNodes
+	}
+	mutex.RLock()
+	defer mutex.RUnlock()
+	filtered := make([]models.Node, 0, len(activeNodes))
+	for _, node := range activeNodes {
+		if height, ok := bestHeight(node.ID); ok && height >= minHeight {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// bestHeight returns probed best block height of node, or height node reported if it wasn't
+// probed yet, so node can't attract requests by reporting too high height. Has to be called
+// while holding lock
+func bestHeight(nodeID string) (int64, bool) {
+	if heights, ok := probed[nodeID]; ok {
+		return heights.Best, true
+	}
+	heights, ok := nodes[nodeID]
+	return heights.Best, ok
+}
+
+type header struct {
+	Number     string `json:"number"`
+	ParentHash string `json:"parentHash"`
+}
+
+// LearnFromResponse records block numbers of block hashes found in response of node
+func LearnFromResponse(req rpc.RPCRequest, body []byte) {
+	var response rpc.RPCResponse
+	if err := json.Unmarshal(body, &response); err != nil || response.Result == nil {
+		return
+	}
+
+	switch req.Method {
+	case "chain_getBlockHash":
+		number, ok := rpc.BlockNumberParam(req)
+		var hash string
+		if ok && json.Unmarshal(*response.Result, &hash) == nil && hash != "" {
+			RecordBlockHash(hash, number)
+		}
+	case "chain_getHeader":
+		var h header
+		if json.Unmarshal(*response.Result, &h) != nil {
+			return
+		}
+		learnFromHeader(req, h)
+	case "chain_getBlock":
+		var block struct {
+			Block struct {
+				Header header `json:"header"`
+			} `json:"block"`
+		}
+		if json.Unmarshal(*response.Result, &block) != nil {
+			return
+		}
+		learnFromHeader(req, block.Block.Header)
+	}
+}
+
+func learnFromHeader(req rpc.RPCRequest, h header) {
+	number, err := strconv.ParseInt(strings.TrimPrefix(h.Number, "0x"), 16, 64)
+	if err != nil {
+		return
+	}
+	if hash, ok := rpc.BlockHashParam(req); ok {
+		RecordBlockHash(hash, number)
+	}
+	if number > 0 && h.ParentHash != "" {
+		RecordBlockHash(h.ParentHash, number-1)
+	}
+}
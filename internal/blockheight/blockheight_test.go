@@ -0,0 +1,138 @@
+package blockheight
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	knownHash   = "0x" + strings.Repeat("aa", 32)
+	parentHash  = "0x" + strings.Repeat("bb", 32)
+	unknownHash = "0x" + strings.Repeat("cc", 32)
+)
+
+func reset() {
+	nodes = make(map[string]Heights)
+	probed = make(map[string]Heights)
+	hashes = make(map[string]int64)
+	hashOrder = nil
+}
+
+func TestRequiredHeight(t *testing.T) {
+	reset()
+	UpdateNode("1", 100, 90)
+	UpdateNode("2", 95, 90)
+	// inactive node and node reporting too high height don't raise required height
+	UpdateNode("3", 1000, 990)
+	UpdateNode("4", 500, 90)
+	UpdateProbedNode("4", 99, 90)
+	RecordBlockHash(knownHash, 50)
+	activeNodes := []models.Node{{ID: "1"}, {ID: "2"}, {ID: "4"}}
+
+	tests := []struct {
+		name string
+		req  rpc.RPCRequest
+		want int64
+	}{
+		{
+			name: "request without block reference",
+			req:  rpc.RPCRequest{Method: "system_health"},
+			want: 0,
+		},
+		{
+			name: "block hash request for block number",
+			req:  rpc.RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(97)}},
+			want: 97,
+		},
+		{
+			name: "request for known block hash",
+			req:  rpc.RPCRequest{Method: "state_getStorage", Params: []interface{}{"0x00", knownHash}},
+			want: 50,
+		},
+		{
+			name: "request for unknown block hash requires height close to most up to date node",
+			req:  rpc.RPCRequest{Method: "chain_getBlock", Params: []interface{}{unknownHash}},
+			want: 100 - UnknownHashLag,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, RequiredHeight(test.req, activeNodes))
+		})
+	}
+}
+
+func TestFilterNodes(t *testing.T) {
+	reset()
+	UpdateNode("1", 100, 90)
+	UpdateNode("2", 95, 90)
+	activeNodes := []models.Node{{ID: "1"}, {ID: "2"}, {ID: "unknown"}}
+
+	assert.Equal(t, activeNodes, FilterNodes(activeNodes, 0))
+	assert.Equal(t, []models.Node{{ID: "1"}, {ID: "2"}}, FilterNodes(activeNodes, 95))
+	assert.Equal(t, []models.Node{{ID: "1"}}, FilterNodes(activeNodes, 96))
+	assert.Empty(t, FilterNodes(activeNodes, 101))
+
+	// probed height is used instead of reported height
+	UpdateProbedNode("2", 101, 90)
+	assert.Equal(t, []models.Node{{ID: "2"}}, FilterNodes(activeNodes, 101))
+
+	// removed node is not at any height
+	RemoveNode("2")
+	assert.Empty(t, FilterNodes(activeNodes, 101))
+	_, ok := NodeHeights("2")
+	assert.False(t, ok)
+}
+
+func TestLearnFromResponse(t *testing.T) {
+	reset()
+
+	LearnFromResponse(
+		rpc.RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(10)}},
+		[]byte(`{"jsonrpc":"2.0","id":1,"result":"`+knownHash+`"}`),
+	)
+	LearnFromResponse(
+		rpc.RPCRequest{Method: "chain_getHeader", Params: []interface{}{unknownHash}},
+		[]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x14","parentHash":"`+parentHash+`"}}`),
+	)
+
+	assert.Equal(t, int64(10), hashes[knownHash])
+	assert.Equal(t, int64(20), hashes[unknownHash])
+	assert.Equal(t, int64(19), hashes[parentHash])
+}
+
+func TestRecordBlockHash_RemovesOldestHashes(t *testing.T) {
+	reset()
+	RecordBlockHash(knownHash, 1)
+	for i := 0; i < MaxKnownHashes; i++ {
+		RecordBlockHash(fmt.Sprintf("0x%064x", i), int64(i))
+	}
+	assert.LessOrEqual(t, len(hashes), MaxKnownHashes)
+	_, ok := hashes[knownHash]
+	assert.False(t, ok)
+}
+
+func TestFinalizedHeight(t *testing.T) {
+	reset()
+	defer reset()
+	assert.Equal(t, int64(0), FinalizedHeight())
+
+	// single node reporting too high finalized height doesn't raise finalized height
+	UpdateNode("1", 100, 90)
+	UpdateNode("2", 100, 92)
+	UpdateNode("3", 10000, 9999)
+	assert.Equal(t, int64(92), FinalizedHeight())
+
+	// probed heights are used instead of reported heights
+	UpdateProbedNode("3", 100, 91)
+	assert.Equal(t, int64(91), FinalizedHeight())
+
+	// lower median is used for even number of nodes
+	UpdateNode("4", 100, 95)
+	assert.Equal(t, int64(91), FinalizedHeight())
+}
@@ -0,0 +1,259 @@
+package breaker
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// State of circuit breaker of node
+type State int
+
+const (
+	// Closed breaker lets all requests to node
+	Closed State = iota
+	// Open breaker keeps node out of selection until open duration passes
+	Open
+	// HalfOpen breaker lets only trickle of requests to node to check if it recovered
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+const (
+	DefaultWindow       = time.Minute
+	DefaultErrorRate    = 0.5
+	DefaultRetryBudget  = 5
+	DefaultOpenDuration = 30 * time.Second
+	DefaultTrickle      = 0.1
+	DefaultTrips        = 3
+	// TripWindow is time in which trips of breaker are counted toward penalty
+	TripWindow = time.Hour
+	// TrialRequests is number of successful requests half open breaker has to see before it closes
+	TrialRequests = 3
+	// windowBuckets is number of buckets requests in sliding window are counted in
+	windowBuckets = 10
+)
+
+// Options configure when breakers of nodes trip and recover
+type Options struct {
+	// Window is sliding window in which error rate of node is calculated, 0 disables breakers
+	Window time.Duration
+	// ErrorRate is fraction (0-1] of failed requests in window that trips breaker
+	ErrorRate float64
+	// RetryBudget is number of failed requests in window that are only retried on other
+	// nodes, breaker trips only if node fails more requests than budget allows
+	RetryBudget int
+	// OpenDuration is time node is kept out of selection after breaker trips
+	OpenDuration time.Duration
+	// Trickle is fraction (0-1] of requests half open node is selected for
+	Trickle float64
+	// Trips is number of trips in trip window after which node is penalized
+	Trips int
+}
+
+// DefaultOptions returns options used if breakers are not configured
+func DefaultOptions() Options {
+	return Options{
+		Window:       DefaultWindow,
+		ErrorRate:    DefaultErrorRate,
+		RetryBudget:  DefaultRetryBudget,
+		OpenDuration: DefaultOpenDuration,
+		Trickle:      DefaultTrickle,
+		Trips:        DefaultTrips,
+	}
+}
+
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+type nodeBreaker struct {
+	state    State
+	buckets  []bucket
+	openedAt time.Time
+	trials   int
+	trips    []time.Time
+}
+
+var (
+	options  Options
+	breakers = make(map[string]*nodeBreaker)
+	mutex    sync.Mutex
+	// random decides if half open node is selected, replaced in tests
+	random = rand.Float64
+)
+
+// InitBreakers sets options of breakers and resets state of all breakers
+func InitBreakers(opts Options) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	options = opts
+	breakers = make(map[string]*nodeBreaker)
+}
+
+// IsEnabled returns true if failed requests are handled by breakers
+func IsEnabled() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return options.Window > 0
+}
+
+// StateOf returns state of breaker of node
+func StateOf(nodeID string) State {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if b, ok := breakers[nodeID]; ok {
+		return b.state
+	}
+	return Closed
+}
+
+// Allow returns true if node can be selected for request. Open breaker becomes half open
+// once open duration passes, after which node is selected for trickle of requests
+func Allow(nodeID string, now time.Time) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	b, ok := breakers[nodeID]
+	if options.Window <= 0 || !ok {
+		return true
+	}
+
+	switch b.state {
+	case Open:
+		if now.Sub(b.openedAt) < options.OpenDuration {
+			return false
+		}
+		b.state = HalfOpen
+		b.trials = 0
+		log.Debugf("Circuit breaker of node %s is half open", nodeID)
+		return random() < options.Trickle
+	case HalfOpen:
+		return random() < options.Trickle
+	default:
+		return true
+	}
+}
+
+// RecordSuccess records successful request of node, half open breaker
+// closes after enough successful trial requests
+func RecordSuccess(nodeID string, now time.Time) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if options.Window <= 0 {
+		return
+	}
+	b := breakerOf(nodeID)
+
+	switch b.state {
+	case Closed:
+		b.record(now, false)
+	case HalfOpen:
+		b.trials++
+		if b.trials >= TrialRequests {
+			b.state = Closed
+			b.buckets = nil
+			log.Infof("Circuit breaker of node %s closed", nodeID)
+		}
+	}
+}
+
+// RecordFailure records failed request of node and returns true if node should be penalized
+// because its breaker tripped too many times in trip window. Closed breaker trips once failures
+// exceed retry budget and error rate in window, half open breaker trips on any failure
+func RecordFailure(nodeID string, now time.Time) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if options.Window <= 0 {
+		return false
+	}
+	b := breakerOf(nodeID)
+
+	switch b.state {
+	case Closed:
+		b.record(now, true)
+		successes, failures := b.counts(now)
+		if failures <= options.RetryBudget ||
+			float64(failures)/float64(successes+failures) < options.ErrorRate {
+			return false
+		}
+	case Open:
+		// request was sent before breaker tripped
+		return false
+	}
+
+	b.state = Open
+	b.openedAt = now
+	b.buckets = nil
+	recent := []time.Time{now}
+	for _, trip := range b.trips {
+		if now.Sub(trip) < TripWindow {
+			recent = append(recent, trip)
+		}
+	}
+	b.trips = recent
+	log.Infof("Circuit breaker of node %s opened, tripped %d times in last %v", nodeID, len(recent), TripWindow)
+
+	if len(recent) >= options.Trips {
+		// penalized node is removed from active nodes so breaker starts closed once it returns
+		delete(breakers, nodeID)
+		return true
+	}
+	return false
+}
+
+func breakerOf(nodeID string) *nodeBreaker {
+	b, ok := breakers[nodeID]
+	if !ok {
+		b = &nodeBreaker{}
+		breakers[nodeID] = b
+	}
+	return b
+}
+
+// record counts request in latest bucket of window, dropping buckets outside window
+func (b *nodeBreaker) record(now time.Time, failed bool) {
+	b.prune(now)
+	width := options.Window / windowBuckets
+	if len(b.buckets) == 0 || now.Sub(b.buckets[len(b.buckets)-1].start) >= width {
+		b.buckets = append(b.buckets, bucket{start: now})
+	}
+	latest := &b.buckets[len(b.buckets)-1]
+	if failed {
+		latest.failures++
+	} else {
+		latest.successes++
+	}
+}
+
+// counts returns number of successful and failed requests in window
+func (b *nodeBreaker) counts(now time.Time) (int, int) {
+	b.prune(now)
+	successes, failures := 0, 0
+	for _, bucket := range b.buckets {
+		successes += bucket.successes
+		failures += bucket.failures
+	}
+	return successes, failures
+}
+
+func (b *nodeBreaker) prune(now time.Time) {
+	i := 0
+	for i < len(b.buckets) && now.Sub(b.buckets[i].start) >= options.Window {
+		i++
+	}
+	b.buckets = b.buckets[i:]
+}
@@ -0,0 +1,120 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFailure(t *testing.T) {
+	InitBreakers(DefaultOptions())
+	defer InitBreakers(Options{})
+	now := time.Now()
+
+	// failures within retry budget don't trip breaker regardless of error rate
+	for i := 0; i < DefaultRetryBudget; i++ {
+		assert.False(t, RecordFailure("1", now))
+	}
+	assert.Equal(t, Closed, StateOf("1"))
+
+	// failures over budget don't trip breaker while error rate is low
+	for i := 0; i < 10; i++ {
+		RecordSuccess("1", now)
+	}
+	assert.False(t, RecordFailure("1", now))
+	assert.Equal(t, Closed, StateOf("1"))
+
+	// old requests slide out of window so error rate rises
+	later := now.Add(DefaultWindow)
+	for i := 0; i < DefaultRetryBudget; i++ {
+		assert.False(t, RecordFailure("1", later))
+	}
+	assert.Equal(t, Closed, StateOf("1"))
+	assert.False(t, RecordFailure("1", later))
+	assert.Equal(t, Open, StateOf("1"))
+	assert.False(t, Allow("1", later))
+
+	// failures of requests sent before breaker opened are ignored
+	assert.False(t, RecordFailure("1", later))
+	assert.Equal(t, Open, StateOf("1"))
+}
+
+func TestHalfOpen(t *testing.T) {
+	InitBreakers(DefaultOptions())
+	defer InitBreakers(Options{})
+	defer func(r func() float64) { random = r }(random)
+	now := time.Now()
+
+	for i := 0; i <= DefaultRetryBudget; i++ {
+		RecordFailure("1", now)
+	}
+	assert.Equal(t, Open, StateOf("1"))
+
+	// half open node gets only trickle of requests
+	later := now.Add(DefaultOpenDuration)
+	random = func() float64 { return 0.5 }
+	assert.False(t, Allow("1", later))
+	assert.Equal(t, HalfOpen, StateOf("1"))
+	random = func() float64 { return 0.05 }
+	assert.True(t, Allow("1", later))
+
+	// failed trial request opens breaker again
+	assert.False(t, RecordFailure("1", later))
+	assert.Equal(t, Open, StateOf("1"))
+	assert.False(t, Allow("1", later))
+
+	// breaker closes after successful trial requests
+	later = later.Add(DefaultOpenDuration)
+	assert.True(t, Allow("1", later))
+	for i := 0; i < TrialRequests; i++ {
+		assert.Equal(t, HalfOpen, StateOf("1"))
+		RecordSuccess("1", later)
+	}
+	assert.Equal(t, Closed, StateOf("1"))
+	assert.True(t, Allow("1", later))
+}
+
+func TestRepeatedTrips(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RetryBudget = 0
+	InitBreakers(opts)
+	defer InitBreakers(Options{})
+	defer func(r func() float64) { random = r }(random)
+	random = func() float64 { return 0 }
+	now := time.Now()
+
+	// node is penalized on third trip in trip window
+	assert.False(t, RecordFailure("1", now))
+	now = now.Add(DefaultOpenDuration)
+	assert.True(t, Allow("1", now))
+	assert.False(t, RecordFailure("1", now))
+	now = now.Add(DefaultOpenDuration)
+	assert.True(t, Allow("1", now))
+	assert.True(t, RecordFailure("1", now))
+
+	// breaker of penalized node starts closed
+	assert.Equal(t, Closed, StateOf("1"))
+
+	// trips outside trip window are not counted
+	assert.False(t, RecordFailure("2", now))
+	now = now.Add(TripWindow)
+	assert.True(t, Allow("2", now))
+	assert.False(t, RecordFailure("2", now))
+	now = now.Add(DefaultOpenDuration)
+	assert.True(t, Allow("2", now))
+	assert.False(t, RecordFailure("2", now))
+	assert.Equal(t, Open, StateOf("2"))
+}
+
+func TestDisabled(t *testing.T) {
+	InitBreakers(Options{})
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		assert.False(t, RecordFailure("1", now))
+	}
+	assert.Equal(t, Closed, StateOf("1"))
+	assert.True(t, Allow("1", now))
+	assert.False(t, IsEnabled())
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+)
+
+type entry struct {
+	key    string
+	result json.RawMessage
+}
+
+// ResponseCache is LRU cache of rpc results bounded by total size of cached results in bytes
+type ResponseCache struct {
+	maxBytes int
+	bytes    int
+	entries  map[string]*list.Element
+	order    *list.List
+	mutex    sync.Mutex
+}
+
+var responseCache *ResponseCache
+
+// InitResponseCache initializes response cache that holds at most maxBytes of results,
+// setting maxBytes to 0 disables caching
+func InitResponseCache(maxBytes int) {
+	if maxBytes <= 0 {
+		responseCache = nil
+		return
+	}
+	responseCache = NewResponseCache(maxBytes)
+}
+
+func NewResponseCache(maxBytes int) *ResponseCache {
+	return &ResponseCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns cached result for key and marks it as recently used
+func (c *ResponseCache) Get(key string) (json.RawMessage, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).result, true
+}
+
+// Add caches result under key, evicting least recently used results if cache is full.
+// Results larger than whole cache are not cached
+func (c *ResponseCache) Add(key string, result json.RawMessage) {
+	size := len(key) + len(result)
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, result: result})
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		oldest := c.order.Back()
+		e := oldest.Value.(*entry)
+		c.order.Remove(oldest)
+		delete(c.entries, e.key)
+		c.bytes -= len(e.key) + len(e.result)
+	}
+}
+
+// Len returns number of cached results
+func (c *ResponseCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.entries)
+}
+
+// Key creates cache key from rpc method and params
+func Key(method string, params interface{}) (string, error) {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	return method + string(p), nil
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+var testHash = "0x" + strings.Repeat("ab", 32)
+
+func TestResponseCache_Eviction(t *testing.T) {
+	c := NewResponseCache(20)
+
+	c.Add("a", json.RawMessage(`"111"`))
+	c.Add("b", json.RawMessage(`"222"`))
+	c.Add("c", json.RawMessage(`"333"`))
+	assert.Equal(t, 3, c.Len())
+
+	// mark a as recently used so b is evicted first
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Add("d", json.RawMessage(`"444"`))
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	result, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, `"111"`, string(result))
+
+	// result larger than cache is not stored
+	c.Add("e", json.RawMessage(`"this result is too large"`))
+	_, ok = c.Get("e")
+	assert.False(t, ok)
+}
+
+func TestIsImmutableRequest(t *testing.T) {
+	tests := []struct {
+		name            string
+		req             rpc.RPCRequest
+		finalizedHeight int64
+		want            bool
+	}{
+		{
+			name: "block by hash",
+			req:  rpc.RPCRequest{Method: "chain_getBlock", Params: []interface{}{testHash}},
+			want: true,
+		},
+		{
+			name: "latest block",
+			req:  rpc.RPCRequest{Method: "chain_getBlock", Params: []interface{}{}},
+			want: false,
+		},
+		{
+			name: "storage at block hash",
+			req:  rpc.RPCRequest{Method: "state_getStorage", Params: []interface{}{"0x26aa", testHash}},
+			want: true,
+		},
+		{
+			name: "storage at latest block",
+			req:  rpc.RPCRequest{Method: "state_getStorage", Params: []interface{}{"0x26aa"}},
+			want: false,
+		},
+		{
+			name: "invalid hash",
+			req:  rpc.RPCRequest{Method: "state_getMetadata", Params: []interface{}{"0xzz"}},
+			want: false,
+		},
+		{
+			name:            "finalized block hash",
+			req:             rpc.RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(100)}},
+			finalizedHeight: 100,
+			want:            true,
+		},
+		{
+			name:            "finalized block hash as hex",
+			req:             rpc.RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{"0x64"}},
+			finalizedHeight: 100,
+			want:            true,
+		},
+		{
+			name:            "non finalized block hash",
+			req:             rpc.RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(101)}},
+			finalizedHeight: 100,
+			want:            false,
+		},
+		{
+			name: "block hash when finalized height is unknown",
+			req:  rpc.RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(1)}},
+			want: false,
+		},
+		{
+			name: "mutable method",
+			req:  rpc.RPCRequest{Method: "system_health"},
+			want: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, IsImmutableRequest(test.req, test.finalizedHeight))
+		})
+	}
+}
+
+func TestSaveAndGetResponse(t *testing.T) {
+	InitResponseCache(1024)
+	defer InitResponseCache(0)
+
+	req := rpc.RPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "chain_getHeader", Params: []interface{}{testHash}}
+	_, ok := GetResponse(req, 0)
+	assert.False(t, ok)
+
+	SaveResponse(req, []byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x1"}}`), 0)
+
+	req.ID = json.RawMessage(`"seven"`)
+	resp, ok := GetResponse(req, 0)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":"seven","result":{"number":"0x1"}}`, string(resp))
+
+	// null results are not cached
+	nullReq := rpc.RPCRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "chain_getBlock", Params: []interface{}{testHash}}
+	SaveResponse(nullReq, []byte(`{"jsonrpc":"2.0","id":1,"result":null}`), 0)
+	_, ok = GetResponse(nullReq, 0)
+	assert.False(t, ok)
+}
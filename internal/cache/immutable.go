@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"encoding/json"
+
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+)
+
+// IsImmutableRequest checks if result of rpc request can never change, which is true
+// for requests that reference explicit block hash and for block hash requests of
+// block numbers at or below finalized height of pool of nodes
+func IsImmutableRequest(req rpc.RPCRequest, finalizedHeight int64) bool {
+	if req.Method == "chain_getBlockHash" {
+		blockNumber, ok := rpc.BlockNumberParam(req)
+		return ok && blockNumber <= finalizedHeight
+	}
+
+	_, ok := rpc.BlockHashParam(req)
+	return ok
+}
+
+// GetResponse returns cached response for request, with id of provided request
+func GetResponse(req rpc.RPCRequest, finalizedHeight int64) ([]byte, bool) {
+	if responseCache == nil || req.IsNotification() || !IsImmutableRequest(req, finalizedHeight) {
+		return nil, false
+	}
+	key, err := Key(req.Method, req.Params)
+	if err != nil {
+		return nil, false
+	}
+	result, ok := responseCache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	resp, err := json.Marshal(rpc.RPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  &result,
+	})
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+// SaveResponse caches result from node response if request is immutable
+func SaveResponse(req rpc.RPCRequest, respBody []byte, finalizedHeight int64) {
+	if responseCache == nil || req.IsNotification() || !IsImmutableRequest(req, finalizedHeight) {
+		return
+	}
+	var resp rpc.RPCResponse
+	err := json.Unmarshal(respBody, &resp)
+	if err != nil || resp.Error != nil || resp.Result == nil || string(*resp.Result) == "null" {
+		return
+	}
+	key, err := Key(req.Method, req.Params)
+	if err != nil {
+		return
+	}
+	responseCache.Add(key, *resp.Result)
+}
@@ -0,0 +1,140 @@
+package capacity
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// limit is maximum number of nodes that hold slot, 0 means unlimited
+	limit int64
+	// mutex serializes admissions so two nodes never take same slot
+	mutex sync.Mutex
+)
+
+// InitCapacity sets maximum number of nodes that can be in pool at once, value lower
+// than 1 means unlimited capacity
+func InitCapacity(capacity int64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if capacity < 1 {
+		capacity = 0
+	}
+	limit = capacity
+}
+
+// Admit gives node slot in pool if there is free slot and no node waiting before it, otherwise
+// node is put on waiting list. Returns position of node on waiting list, 0 if node was admitted.
+// If capacity is limited, node is saved before returning
+func Admit(node *models.Node, repos repositories.Repos) (int, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// banned and deactivated nodes don't count toward capacity
+	if limit == 0 || !node.Active || node.Banned {
+		node.Waiting = false
+		node.WaitingSince = 0
+		return 0, nil
+	}
+
+	nodes, err := repos.NodeRepo.GetAll()
+	if err != nil && err.Error() != "not found" {
+		return 0, err
+	}
+
+	holders := int64(0)
+	var waiting []models.Node
+	for _, n := range *nodes {
+		if n.ID == node.ID {
+			// node keeps slot it already holds or was promoted to while on waiting list
+			if n.HoldsSlot() {
+				node.Waiting = false
+				node.WaitingSince = 0
+				return 0, repos.NodeRepo.Save(node)
+			}
+			continue
+		}
+		if n.HoldsSlot() {
+			holders++
+		} else if isWaiting(n) {
+			waiting = append(waiting, n)
+		}
+	}
+
+	if !node.Waiting {
+		node.Waiting = true
+		node.WaitingSince = time.Now().UnixNano()
+	}
+	ahead := 0
+	for _, n := range waiting {
+		if n.WaitingSince < node.WaitingSince {
+			ahead++
+		}
+	}
+
+	if holders+int64(ahead) < limit {
+		node.Waiting = false
+		node.WaitingSince = 0
+		return 0, repos.NodeRepo.Save(node)
+	}
+	return ahead + 1, repos.NodeRepo.Save(node)
+}
+
+// PromoteWaitingNodes gives free slots to nodes on waiting list in order in which they
+// joined list. Node on waiting list has no token, so promoted node isn't notified, instead
+// slot is reserved for it and it gets token as soon as it registers again
+func PromoteWaitingNodes(repos repositories.Repos) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if limit == 0 {
+		return
+	}
+
+	nodes, err := repos.NodeRepo.GetAll()
+	if err != nil {
+		if err.Error() != "not found" {
+			log.Errorf("Unable to promote waiting nodes because of %v", err)
+		}
+		return
+	}
+
+	holders := int64(0)
+	var waiting []models.Node
+	for _, n := range *nodes {
+		if n.HoldsSlot() {
+			holders++
+		} else if isWaiting(n) {
+			waiting = append(waiting, n)
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].WaitingSince < waiting[j].WaitingSince
+	})
+
+	for i := 0; i < len(waiting) && holders < limit; i++ {
+		node := waiting[i]
+		node.Waiting = false
+		node.WaitingSince = 0
+		if node.ChainError != "" {
+			// chain of node is verified again once it registers
+			node.ChainError = models.ChainNotVerified
+		}
+		err = repos.NodeRepo.Save(&node)
+		if err != nil {
+			log.Errorf("Unable to promote node %s from waiting list because of %v", node.ID, err)
+			return
+		}
+		holders++
+		log.Infof("Node %s promoted from waiting list", node.ID)
+	}
+}
+
+// isWaiting returns true if node is on waiting list and could take slot once promoted
+func isWaiting(node models.Node) bool {
+	return node.Waiting && node.Active && !node.Banned && !node.Left
+}
@@ -0,0 +1,150 @@
+package capacity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAdmit(t *testing.T) {
+	defer InitCapacity(0)
+	tests := []struct {
+		name            string
+		capacity        int64
+		node            models.Node
+		nodes           []models.Node
+		getAllError     error
+		position        int
+		saveNumOfCalls  int
+		shouldBeWaiting bool
+	}{
+		{
+			name:     "unlimited capacity",
+			capacity: -1,
+			node:     models.Node{ID: "1", Active: true},
+		},
+		{
+			name:           "free slot",
+			capacity:       2,
+			node:           models.Node{ID: "3", Active: true},
+			nodes:          []models.Node{{ID: "1", Active: true}, {ID: "2", Active: true, Left: true}},
+			saveNumOfCalls: 1,
+		},
+		{
+			name:            "no free slot",
+			capacity:        2,
+			node:            models.Node{ID: "3", Active: true},
+			nodes:           []models.Node{{ID: "1", Active: true}, {ID: "2", Active: true, Draining: true}},
+			position:        1,
+			saveNumOfCalls:  1,
+			shouldBeWaiting: true,
+		},
+		{
+			name:     "free slot taken by node waiting before",
+			capacity: 2,
+			node:     models.Node{ID: "3", Active: true},
+			nodes: []models.Node{
+				{ID: "1", Active: true},
+				{ID: "2", Active: true, Waiting: true, WaitingSince: 100},
+			},
+			position:        2,
+			saveNumOfCalls:  1,
+			shouldBeWaiting: true,
+		},
+		{
+			name:     "first node on waiting list gets free slot",
+			capacity: 2,
+			node:     models.Node{ID: "2", Active: true, Waiting: true, WaitingSince: 100},
+			nodes: []models.Node{
+				{ID: "1", Active: true},
+				{ID: "2", Active: true, Waiting: true, WaitingSince: 100},
+				{ID: "3", Active: true, Waiting: true, WaitingSince: 200},
+			},
+			saveNumOfCalls: 1,
+		},
+		{
+			name:           "node keeps its slot",
+			capacity:       1,
+			node:           models.Node{ID: "1", Active: true},
+			nodes:          []models.Node{{ID: "1", Active: true}, {ID: "2", Active: true, Waiting: true, WaitingSince: 100}},
+			saveNumOfCalls: 1,
+		},
+		{
+			name:     "node refused because of chain releases its slot",
+			capacity: 2,
+			node:     models.Node{ID: "3", Active: true},
+			nodes: []models.Node{
+				{ID: "1", Active: true, ChainError: models.ChainNotVerified},
+				{ID: "2", Active: true, ChainError: "node serves wrong chain"},
+			},
+			saveNumOfCalls: 1,
+		},
+		{
+			name:           "promoted node gets slot it was given",
+			capacity:       1,
+			node:           models.Node{ID: "2", Active: true},
+			nodes:          []models.Node{{ID: "1", Active: true, Left: true}, {ID: "2", Active: true}},
+			saveNumOfCalls: 1,
+		},
+		{
+			name:     "deactivated node doesn't need slot",
+			capacity: 1,
+			node:     models.Node{ID: "2", Active: false},
+			nodes:    []models.Node{{ID: "1", Active: true}},
+		},
+		{
+			name:        "unable to fetch nodes",
+			capacity:    1,
+			node:        models.Node{ID: "2", Active: true},
+			getAllError: errors.New("db error"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			InitCapacity(test.capacity)
+			nodeRepoMock := mocks.NodeRepository{}
+			nodeRepoMock.On("GetAll").Return(&test.nodes, test.getAllError)
+			nodeRepoMock.On("Save", mock.Anything).Return(nil)
+
+			node := test.node
+			position, err := Admit(&node, repositories.Repos{NodeRepo: &nodeRepoMock})
+			if test.getAllError != nil {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.position, position)
+			assert.Equal(t, test.shouldBeWaiting, node.Waiting)
+			assert.Equal(t, test.shouldBeWaiting, node.WaitingSince != 0)
+			nodeRepoMock.AssertNumberOfCalls(t, "Save", test.saveNumOfCalls)
+		})
+	}
+}
+
+func TestPromoteWaitingNodes(t *testing.T) {
+	defer InitCapacity(0)
+	InitCapacity(3)
+
+	nodeRepoMock := mocks.NodeRepository{}
+	nodeRepoMock.On("GetAll").Return(&[]models.Node{
+		{ID: "1", Active: true},
+		{ID: "2", Active: true, Left: true},
+		{ID: "3", Active: false},
+		{ID: "4", Active: true, Waiting: true, WaitingSince: 300},
+		{ID: "5", Active: true, Waiting: true, WaitingSince: 100},
+		{ID: "6", Active: true, Waiting: true, WaitingSince: 200, Banned: true},
+		{ID: "7", Active: true, ChainError: "node serves wrong chain"},
+	}, nil)
+	nodeRepoMock.On("Save", mock.Anything).Return(nil)
+
+	PromoteWaitingNodes(repositories.Repos{NodeRepo: &nodeRepoMock})
+
+	nodeRepoMock.AssertNumberOfCalls(t, "Save", 2)
+	nodeRepoMock.AssertCalled(t, "Save", &models.Node{ID: "5", Active: true})
+	nodeRepoMock.AssertCalled(t, "Save", &models.Node{ID: "4", Active: true})
+}
@@ -0,0 +1,218 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/active"
+	"github.com/NodeFactoryIo/vedran/internal/capacity"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// VerifyAttempts is number of times chain of node is queried before node is refused
+	// because its chain couldn't be verified
+	VerifyAttempts      = 5
+	VerifyRetryInterval = 10 * time.Second
+)
+
+// ErrNotVerified is recorded on node until chain it serves is verified
+var ErrNotVerified = errors.New(models.ChainNotVerified)
+
+// MismatchError is returned if node serves different chain than load balancer expects
+type MismatchError struct {
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("node serves wrong chain, %s is %s but %s is expected", e.Field, e.Actual, e.Expected)
+}
+
+var (
+	genesisHash string
+	chainName   string
+	repos       repositories.Repos
+	// retryInterval is wait between queries of node, overridden in tests
+	retryInterval = VerifyRetryInterval
+	// nodeLocks serialize verifications of same node
+	nodeLocks sync.Map
+)
+
+// InitChainVerification sets chain nodes have to serve, chain is identified by genesis hash
+// and optionally by chain name. Verification is disabled if genesis hash is empty
+func InitChainVerification(expectedGenesisHash string, expectedChainName string, r repositories.Repos) {
+	genesisHash = strings.ToLower(expectedGenesisHash)
+	chainName = expectedChainName
+	repos = r
+}
+
+// IsEnabled returns true if load balancer is configured with expected chain
+func IsEnabled() bool {
+	return genesisHash != ""
+}
+
+// VerifyNode queries genesis hash and chain name through tunnel of node and compares them with
+// expected chain, *MismatchError is returned if node serves wrong chain
+func VerifyNode(nodeID string) error {
+	var hash string
+	err := query(nodeID, "chain_getBlockHash", []int{0}, &hash)
+	if err != nil {
+		return fmt.Errorf("unable to query genesis hash: %v", err)
+	}
+	if !strings.EqualFold(hash, genesisHash) {
+		return &MismatchError{Field: "genesis hash", Expected: genesisHash, Actual: hash}
+	}
+
+	if chainName == "" {
+		return nil
+	}
+	var name string
+	err = query(nodeID, "system_chain", []int{}, &name)
+	if err != nil {
+		return fmt.Errorf("unable to query chain name: %v", err)
+	}
+	if !strings.EqualFold(name, chainName) {
+		return &MismatchError{Field: "chain name", Expected: chainName, Actual: name}
+	}
+	return nil
+}
+
+// IsMismatch checks if error means that node serves wrong chain
+func IsMismatch(err error) bool {
+	var mismatchError *MismatchError
+	return errors.As(err, &mismatchError)
+}
+
+// CheckNodeChain verifies chain of node after its tunnel connects and records
+// result on node. Node that serves wrong chain, or whose chain couldn't be verified in
+// VerifyAttempts queries, is removed from active nodes until its chain is verified again
+func CheckNodeChain(nodeID string) {
+	if !IsEnabled() {
+		return
+	}
+	lock, _ := nodeLocks.LoadOrStore(nodeID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	var err error
+	for attempt := 1; attempt <= VerifyAttempts; attempt++ {
+		err = VerifyNode(nodeID)
+		if err == nil || IsMismatch(err) {
+			break
+		}
+		log.Debugf("Verifying chain of node %s failed (attempt %d) because of: %v", nodeID, attempt, err)
+		if attempt < VerifyAttempts {
+			time.Sleep(retryInterval)
+		}
+	}
+
+	err = recordResult(nodeID, err)
+	if err != nil {
+		log.Errorf("Unable to record chain verification of node %s because of: %v", nodeID, err)
+	}
+}
+
+// recordResult saves verification result on node, refused node is removed from active nodes
+// and its slot is given to waiting node. Verified node is activated once its metrics are valid,
+// node that was refused before has to get slot again
+func recordResult(nodeID string, verifyErr error) error {
+	node, err := repos.NodeRepo.FindByID(nodeID)
+	if err != nil {
+		return err
+	}
+
+	if verifyErr != nil {
+		log.Warnf("Node %s refused because of: %v", nodeID, verifyErr)
+		heldSlot := node.HoldsSlot()
+		node.ChainError = verifyErr.Error()
+		err = repos.NodeRepo.Save(node)
+		if err != nil {
+			return err
+		}
+		if heldSlot {
+			capacity.PromoteWaitingNodes(repos)
+		}
+		if repos.NodeRepo.IsNodeActive(nodeID) {
+			return repos.NodeRepo.RemoveNodeFromActive(nodeID)
+		}
+		return nil
+	}
+
+	if node.ChainError == "" {
+		return nil
+	}
+	log.Infof("Chain of node %s verified", nodeID)
+	refused := node.ChainError != ErrNotVerified.Error()
+	node.ChainError = ""
+	if refused {
+		position, err := capacity.Admit(node, repos)
+		if err != nil {
+			return err
+		}
+		if position > 0 {
+			log.Infof("Node %s is on waiting list at position %d", nodeID, position)
+			return nil
+		}
+	}
+	err = repos.NodeRepo.Save(node)
+	if err != nil {
+		return err
+	}
+	if repos.NodeRepo.IsNodeActive(nodeID) {
+		return nil
+	}
+	err = active.ActivateNodeIfReady(nodeID, repos)
+	if err != nil && err.Error() != "not found" {
+		return err
+	}
+	return nil
+}
+
+// ResetVerification records on node that its chain has to be verified before it serves
+// requests, if verification is disabled result of previous verification is cleared
+func ResetVerification(node *models.Node) {
+	if IsEnabled() {
+		node.ChainError = ErrNotVerified.Error()
+	} else {
+		node.ChainError = ""
+	}
+}
+
+func query(nodeID string, method string, params interface{}, result interface{}) error {
+	reqBody, err := json.Marshal(rpc.RPCRequest{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage("1"),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	body, err := rpc.SendRequestToNode(false, nodeID, reqBody)
+	if err != nil {
+		return err
+	}
+
+	var response rpc.RPCResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("rpc error %d: %s", response.Error.Code, response.Error.Message)
+	}
+	if response.Result == nil {
+		return errors.New("empty result")
+	}
+	return json.Unmarshal(*response.Result, result)
+}
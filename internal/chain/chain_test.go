@@ -0,0 +1,265 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/NodeFactoryIo/vedran/internal/capacity"
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	tunnelMocks "github.com/NodeFactoryIo/vedran/mocks/http-tunnel/server"
+	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	polkadotGenesis = "0x91b171bb158e2d3848fa23a9f1c25182fb8e20313b2c1eb49219da7a70ce90c3"
+	kusamaGenesis   = "0xb0a8d493285c2df73290dfb7e61f870f17b41801197a149ca93654499ea3dafe"
+)
+
+// newNodeServer starts server that answers chain queries as node would and
+// routes tunnel of node with id "1" to it
+func newNodeServer(genesis string, name string) func() {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpc.RPCRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "chain_getBlockHash":
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"%s"}`, genesis)
+		case "system_chain":
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"%s"}`, name)
+		default:
+			_, _ = fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`)
+		}
+	}))
+	u, _ := url.Parse(s.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	poolerMock := tunnelMocks.Pooler{}
+	poolerMock.On("GetHTTPPort", "1").Return(port, nil)
+	configuration.Config.PortPool = &poolerMock
+	return func() {
+		s.Close()
+		configuration.Config.PortPool = nil
+	}
+}
+
+func TestVerifyNode(t *testing.T) {
+	tests := []struct {
+		name            string
+		expectedGenesis string
+		expectedName    string
+		nodeGenesis     string
+		nodeName        string
+		isMismatch      bool
+		mismatchedField string
+	}{
+		{
+			name:            "node serves expected chain",
+			expectedGenesis: polkadotGenesis,
+			expectedName:    "Polkadot",
+			nodeGenesis:     polkadotGenesis,
+			nodeName:        "Polkadot",
+		},
+		{
+			name:            "genesis hash is compared case insensitive",
+			expectedGenesis: "0x91B171BB158E2D3848FA23A9F1C25182FB8E20313B2C1EB49219DA7A70CE90C3",
+			nodeGenesis:     polkadotGenesis,
+			nodeName:        "Polkadot",
+		},
+		{
+			name:            "chain name is not checked if not configured",
+			expectedGenesis: polkadotGenesis,
+			nodeGenesis:     polkadotGenesis,
+			nodeName:        "Development",
+		},
+		{
+			name:            "node serves different chain",
+			expectedGenesis: polkadotGenesis,
+			expectedName:    "Polkadot",
+			nodeGenesis:     kusamaGenesis,
+			nodeName:        "Kusama",
+			isMismatch:      true,
+			mismatchedField: "genesis hash",
+		},
+		{
+			name:            "node serves chain with different name",
+			expectedGenesis: polkadotGenesis,
+			expectedName:    "Polkadot",
+			nodeGenesis:     polkadotGenesis,
+			nodeName:        "Development",
+			isMismatch:      true,
+			mismatchedField: "chain name",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			closeServer := newNodeServer(test.nodeGenesis, test.nodeName)
+			defer closeServer()
+			InitChainVerification(test.expectedGenesis, test.expectedName, repositories.Repos{})
+			defer InitChainVerification("", "", repositories.Repos{})
+
+			err := VerifyNode("1")
+
+			assert.Equal(t, test.isMismatch, IsMismatch(err))
+			if test.isMismatch {
+				var mismatchError *MismatchError
+				assert.True(t, errors.As(err, &mismatchError))
+				assert.Equal(t, test.mismatchedField, mismatchError.Field)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckNodeChain(t *testing.T) {
+	retryInterval = 0
+	defer func() { retryInterval = VerifyRetryInterval }()
+
+	tests := []struct {
+		name              string
+		node              *models.Node
+		nodeGenesis       string
+		nodeUnreachable   bool
+		isNodeActive      bool
+		savedNode         *models.Node
+		removeNumOfCalls  int
+		cooldownNumOfCall int
+	}{
+		{
+			name:              "unverified node serves expected chain",
+			node:              &models.Node{ID: "1", Active: true, ChainError: ErrNotVerified.Error()},
+			nodeGenesis:       polkadotGenesis,
+			savedNode:         &models.Node{ID: "1", Active: true},
+			cooldownNumOfCall: 1,
+		},
+		{
+			name:        "verified node serves expected chain",
+			node:        &models.Node{ID: "1", Active: true},
+			nodeGenesis: polkadotGenesis,
+		},
+		{
+			name:        "node serves different chain",
+			node:        &models.Node{ID: "1", Active: true, ChainError: ErrNotVerified.Error()},
+			nodeGenesis: kusamaGenesis,
+			savedNode: &models.Node{ID: "1", Active: true, ChainError: (&MismatchError{
+				Field: "genesis hash", Expected: polkadotGenesis, Actual: kusamaGenesis,
+			}).Error()},
+		},
+		{
+			name:             "active node doesn't answer chain queries",
+			node:             &models.Node{ID: "1", Active: true},
+			nodeUnreachable:  true,
+			isNodeActive:     true,
+			removeNumOfCalls: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			closeServer := newNodeServer(test.nodeGenesis, "Polkadot")
+			defer closeServer()
+			if test.nodeUnreachable {
+				poolerMock := tunnelMocks.Pooler{}
+				poolerMock.On("GetHTTPPort", "1").Return(0, errors.New("no tunnel"))
+				configuration.Config.PortPool = &poolerMock
+			}
+
+			nodeRepoMock := mocks.NodeRepository{}
+			nodeRepoMock.On("FindByID", "1").Return(test.node, nil)
+			nodeRepoMock.On("Save", mock.Anything).Return(nil)
+			nodeRepoMock.On("IsNodeActive", "1").Return(test.isNodeActive)
+			nodeRepoMock.On("RemoveNodeFromActive", "1").Return(nil)
+			nodeRepoMock.On("IsNodeOnCooldown", "1").Return(true, nil)
+			InitChainVerification(polkadotGenesis, "", repositories.Repos{NodeRepo: &nodeRepoMock})
+			defer InitChainVerification("", "", repositories.Repos{})
+
+			CheckNodeChain("1")
+
+			if test.nodeUnreachable {
+				assert.Contains(t, test.node.ChainError, "unable to query genesis hash")
+				nodeRepoMock.AssertNumberOfCalls(t, "Save", 1)
+			} else if test.savedNode != nil {
+				nodeRepoMock.AssertCalled(t, "Save", test.savedNode)
+			} else {
+				nodeRepoMock.AssertNotCalled(t, "Save", mock.Anything)
+			}
+			nodeRepoMock.AssertNumberOfCalls(t, "RemoveNodeFromActive", test.removeNumOfCalls)
+			nodeRepoMock.AssertNumberOfCalls(t, "IsNodeOnCooldown", test.cooldownNumOfCall)
+		})
+	}
+}
+
+func TestCheckNodeChain_Capacity(t *testing.T) {
+	retryInterval = 0
+	defer func() { retryInterval = VerifyRetryInterval }()
+	capacity.InitCapacity(1)
+	defer capacity.InitCapacity(0)
+
+	tests := []struct {
+		name      string
+		genesis   string
+		nodes     []models.Node
+		savedNode *models.Node
+	}{
+		{
+			name:    "refused node gives its slot to waiting node",
+			genesis: kusamaGenesis,
+			nodes: []models.Node{
+				{ID: "1", Active: true},
+				{ID: "2", Active: true, Waiting: true, WaitingSince: 100},
+			},
+			savedNode: &models.Node{ID: "2", Active: true},
+		},
+		{
+			name:    "verified node that was refused waits for free slot",
+			genesis: polkadotGenesis,
+			nodes: []models.Node{
+				{ID: "1", Active: true, ChainError: "node serves wrong chain"},
+				{ID: "2", Active: true},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			closeServer := newNodeServer(test.genesis, "Polkadot")
+			defer closeServer()
+
+			node := test.nodes[0]
+			nodeRepoMock := mocks.NodeRepository{}
+			nodeRepoMock.On("FindByID", "1").Return(&node, nil)
+			nodeRepoMock.On("GetAll").Return(&test.nodes, nil)
+			// saved nodes are seen by later queries of repository
+			nodeRepoMock.On("Save", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				saved := args.Get(0).(*models.Node)
+				for i := range test.nodes {
+					if test.nodes[i].ID == saved.ID {
+						test.nodes[i] = *saved
+					}
+				}
+			})
+			nodeRepoMock.On("IsNodeActive", "1").Return(false)
+			InitChainVerification(polkadotGenesis, "", repositories.Repos{NodeRepo: &nodeRepoMock})
+			defer InitChainVerification("", "", repositories.Repos{})
+
+			CheckNodeChain("1")
+
+			if test.savedNode != nil {
+				nodeRepoMock.AssertCalled(t, "Save", test.savedNode)
+			} else {
+				assert.True(t, node.Waiting)
+				assert.Empty(t, node.ChainError)
+				nodeRepoMock.AssertNotCalled(t, "IsNodeActive", "1")
+			}
+		})
+	}
+}
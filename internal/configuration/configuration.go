@@ -2,7 +2,10 @@ package configuration
 
 import (
 	"net/url"
+	"time"
 
+	"github.com/NodeFactoryIo/vedran/internal/breaker"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
 	"github.com/NodeFactoryIo/vedran/pkg/http-tunnel/server"
 )
 
@@ -15,13 +18,48 @@ type PayoutConfiguration struct {
 
 type Configuration struct {
 	AuthSecret          string
+	PreviousAuthSecret  string
+	AuthGracePeriod     time.Duration
+	AuthTokenTTL        time.Duration
+	AdminToken          string
 	Name                string
 	CertFile            string
 	KeyFile             string
 	Capacity            int64
+	ChainGenesisHash    string
+	ChainName           string
+	ProbeInterval       time.Duration
+	ProbeDivergence     int64
+	PenaltyPolicies     map[string]penalty.Policy
+	PenaltyWindow       time.Duration
+	PenaltyDecay        time.Duration
+	Breaker             breaker.Options
 	WhitelistEnabled    bool
 	Fee                 float32
 	Selection           string
+	CacheSize           int
+	AllowedMethods      []string
+	DeniedMethods       []string
+	BatchChunkSize      int
+	HedgePercentile     float64
+	BroadcastExtrinsics int
+	QuorumSize          int
+	QuorumMethods       []string
+	QuorumSample        float64
+	AnonymousAccess     bool
+	AnonymousRateLimit  float64
+	AnonymousQuota      int64
+	IPRateLimit         float64
+	IPRateBurst         int
+	IPWSConnections     int
+	TrustForwardedFor   bool
+	WSPoolSize          int
+	WSFailover          bool
+	WSMaxMessageSize    int64
+	WSIdleTimeout       time.Duration
+	WSPingInterval      time.Duration
+	WSAllowedOrigins    []string
+	WSCompression       bool
 	Port                int32
 	PortPool            server.Pooler
 	TunnelServerAddress string
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/active"
+	"github.com/NodeFactoryIo/vedran/internal/blockheight"
+	"github.com/NodeFactoryIo/vedran/internal/breaker"
+	"github.com/NodeFactoryIo/vedran/internal/capacity"
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	muxhelpper "github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errNodeBanned      = errors.New("node is banned")
+	errCapacityReached = errors.New("capacity is reached, node is on waiting list")
+	errWrongChain      = errors.New("chain of node is not verified")
+)
+
+type AdminNodeMetrics struct {
+	PeerCount             int32     `json:"peer_count"`
+	BestBlockHeight       int64     `json:"best_block_height"`
+	FinalizedBlockHeight  int64     `json:"finalized_block_height"`
+	TargetBlockHeight     int64     `json:"target_block_height"`
+	ReadyTransactionCount int32     `json:"ready_transaction_count"`
+	Timestamp             time.Time `json:"timestamp"`
+}
+
+type AdminNodeProbe struct {
+	PeerCount            int32     `json:"peer_count"`
+	IsSyncing            bool      `json:"is_syncing"`
+	BestBlockHeight      int64     `json:"best_block_height"`
+	FinalizedBlockHeight int64     `json:"finalized_block_height"`
+	LatencyMs            int64     `json:"latency_ms"`
+	Error                string    `json:"error,omitempty"`
+	Timestamp            time.Time `json:"timestamp"`
+}
+
+type AdminNodeResponse struct {
+	ID            string `json:"id"`
+	ConfigHash    string `json:"config_hash"`
+	PayoutAddress string `json:"payout_address"`
+	Capacity      int64  `json:"capacity"`
+	// Active is set if node currently serves requests
+	Active bool `json:"active"`
+	// Penalized is set if node is on cooldown
+	Penalized bool `json:"penalized"`
+	// Deactivated is set if node reached maximum cooldown
+	Deactivated bool              `json:"deactivated"`
+	Banned      bool              `json:"banned"`
+	Draining    bool              `json:"draining"`
+	Maintenance bool              `json:"maintenance"`
+	Left        bool              `json:"left"`
+	Waiting     bool              `json:"waiting"`
+	ChainError  string            `json:"chain_error,omitempty"`
+	Cooldown    int               `json:"cooldown"`
+	Penalty     string            `json:"penalty_reason,omitempty"`
+	Breaker     string            `json:"breaker,omitempty"`
+	LastUsed    int64             `json:"last_used"`
+	LastPing    *time.Time        `json:"last_ping"`
+	Metrics     *AdminNodeMetrics `json:"metrics"`
+	Probe       *AdminNodeProbe   `json:"probe"`
+	HTTPPort    int               `json:"http_port,omitempty"`
+	WSPort      int               `json:"ws_port,omitempty"`
+}
+
+// adminNodeActions are actions operator can execute on node, updated node is saved by handler
+var adminNodeActions = map[string]func(c *ApiController, node *models.Node) error{
+	"ban":            (*ApiController).banNode,
+	"unban":          (*ApiController).unbanNode,
+	"activate":       (*ApiController).activateNode,
+	"drain":          (*ApiController).drainNode,
+	"reset-cooldown": (*ApiController).resetNodeCooldown,
+	"revoke-token":   (*ApiController).revokeNodeToken,
+}
+
+// handler for `GET /api/v1/admin/nodes` - admin token verification in middleware
+func (c *ApiController) AdminGetNodesHandler(w http.ResponseWriter, r *http.Request) {
+	nodes, err := c.repositories.NodeRepo.GetAll()
+	if err != nil && err.Error() != "not found" {
+		log.Errorf("Failed fetching nodes, because %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]AdminNodeResponse, len(*nodes))
+	for i, node := range *nodes {
+		response[i] = c.newAdminNodeResponse(node)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// handler for `GET /api/v1/admin/nodes/{id}` - admin token verification in middleware
+func (c *ApiController) AdminGetNodeHandler(w http.ResponseWriter, r *http.Request) {
+	node, ok := c.findAdminNode(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c.newAdminNodeResponse(*node))
+}
+
+// handler for `POST /api/v1/admin/nodes/{id}/{action}` - admin token verification in middleware
+func (c *ApiController) AdminNodeActionHandler(w http.ResponseWriter, r *http.Request) {
+	action, ok := adminNodeActions[muxhelpper.Vars(r)["action"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	node, ok := c.findAdminNode(w, r)
+	if !ok {
+		return
+	}
+
+	err := action(c, node)
+	if err != nil {
+		if err == errNodeBanned || err == errCapacityReached || err == errWrongChain {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			log.Errorf("Failed executing action on node %s, because %v", node.ID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+	log.Infof("Operator executed action %s on node %s", muxhelpper.Vars(r)["action"], node.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c.newAdminNodeResponse(*node))
+}
+
+func (c *ApiController) findAdminNode(w http.ResponseWriter, r *http.Request) (*models.Node, bool) {
+	node, err := c.repositories.NodeRepo.FindByID(muxhelpper.Vars(r)["id"])
+	if err != nil {
+		if err.Error() == "not found" {
+			http.NotFound(w, r)
+		} else {
+			log.Errorf("Failed fetching node, because %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return node, true
+}
+
+// banNode removes node from active nodes until operator unbans it
+func (c *ApiController) banNode(node *models.Node) error {
+	node.Banned = true
+	err := c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		return err
+	}
+	c.removeFromActive(node.ID)
+	blockheight.RemoveNode(node.ID)
+	capacity.PromoteWaitingNodes(c.repositories)
+	return nil
+}
+
+// unbanNode clears ban and penalties of node, node is activated once its metrics are valid
+// or put on waiting list if capacity is reached
+func (c *ApiController) unbanNode(node *models.Node) error {
+	node.Banned = false
+	node.Active = true
+	node.Cooldown = 0
+	_, err := capacity.Admit(node, c.repositories)
+	if err != nil {
+		return err
+	}
+	err = c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		return err
+	}
+	return c.activateIfReady(node.ID)
+}
+
+// activateNode adds node to active nodes without checking its metrics, even if node
+// is draining, in maintenance, left pool, is penalized or reached maximum cooldown. If
+// capacity is reached node is put on waiting list instead. Node whose chain is not
+// verified can't be activated
+func (c *ApiController) activateNode(node *models.Node) error {
+	if node.Banned {
+		return errNodeBanned
+	}
+	if node.ChainError != "" {
+		return errWrongChain
+	}
+	if node.Maintenance {
+		_, err := c.endMaintenance(node.ID, time.Now())
+		if err != nil && err.Error() != "not found" {
+			return err
+		}
+	}
+	node.Draining = false
+	node.Maintenance = false
+	node.Left = false
+	node.Active = true
+	node.Cooldown = 0
+	position, err := capacity.Admit(node, c.repositories)
+	if err != nil {
+		return err
+	}
+	err = c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		return err
+	}
+	if position > 0 {
+		return errCapacityReached
+	}
+	if c.repositories.NodeRepo.IsNodeActive(node.ID) {
+		return nil
+	}
+	return c.repositories.NodeRepo.AddNodeToActive(node.ID)
+}
+
+// drainNode stops routing new requests to node, already open connections are kept
+func (c *ApiController) drainNode(node *models.Node) error {
+	node.Draining = true
+	err := c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		return err
+	}
+	c.removeFromActive(node.ID)
+	return nil
+}
+
+// resetNodeCooldown ends penalty of node, node is activated once its metrics are valid
+func (c *ApiController) resetNodeCooldown(node *models.Node) error {
+	updated, err := c.repositories.NodeRepo.ResetNodeCooldown(node.ID)
+	if err != nil {
+		return err
+	}
+	*node = *updated
+	return c.activateIfReady(node.ID)
+}
+
+// revokeNodeToken revokes current token of node, node has to register again to get new token
+func (c *ApiController) revokeNodeToken(node *models.Node) error {
+	return c.revokeToken(node.Token)
+}
+
+func (c *ApiController) activateIfReady(nodeID string) error {
+	if c.repositories.NodeRepo.IsNodeActive(nodeID) {
+		return nil
+	}
+	err := active.ActivateNodeIfReady(nodeID, c.repositories)
+	if err != nil && err.Error() != "not found" {
+		return err
+	}
+	return nil
+}
+
+func (c *ApiController) removeFromActive(nodeID string) {
+	if !c.repositories.NodeRepo.IsNodeActive(nodeID) {
+		return
+	}
+	err := c.repositories.NodeRepo.RemoveNodeFromActive(nodeID)
+	if err != nil {
+		log.Errorf("Unable to remove node %s from active because of %v", nodeID, err)
+	}
+}
+
+func (c *ApiController) newAdminNodeResponse(node models.Node) AdminNodeResponse {
+	response := AdminNodeResponse{
+		ID:            node.ID,
+		ConfigHash:    node.ConfigHash,
+		PayoutAddress: node.PayoutAddress,
+		Capacity:      node.Capacity,
+		Active:        c.repositories.NodeRepo.IsNodeActive(node.ID),
+		Penalized:     node.Cooldown > 0,
+		Deactivated:   !node.Active,
+		Banned:        node.Banned,
+		Draining:      node.Draining,
+		Maintenance:   node.Maintenance,
+		Left:          node.Left,
+		Waiting:       node.Waiting,
+		ChainError:    node.ChainError,
+		Cooldown:      node.Cooldown,
+		Penalty:       node.PenaltyReason,
+		LastUsed:      node.LastUsed,
+	}
+
+	if breaker.IsEnabled() {
+		response.Breaker = breaker.StateOf(node.ID).String()
+	}
+	if ping, err := c.repositories.PingRepo.FindByNodeID(node.ID); err == nil {
+		response.LastPing = &ping.Timestamp
+	}
+	if metrics, err := c.repositories.MetricsRepo.FindByID(node.ID); err == nil {
+		response.Metrics = &AdminNodeMetrics{
+			PeerCount:             metrics.PeerCount,
+			BestBlockHeight:       metrics.BestBlockHeight,
+			FinalizedBlockHeight:  metrics.FinalizedBlockHeight,
+			TargetBlockHeight:     metrics.TargetBlockHeight,
+			ReadyTransactionCount: metrics.ReadyTransactionCount,
+			Timestamp:             metrics.Timestamp,
+		}
+	}
+	if probe, err := c.repositories.ProbeRepo.FindByNodeID(node.ID); err == nil {
+		response.Probe = &AdminNodeProbe{
+			PeerCount:            probe.PeerCount,
+			IsSyncing:            probe.IsSyncing,
+			BestBlockHeight:      probe.BestBlockHeight,
+			FinalizedBlockHeight: probe.FinalizedBlockHeight,
+			LatencyMs:            probe.Latency.Milliseconds(),
+			Error:                probe.Error,
+			Timestamp:            probe.Timestamp,
+		}
+	}
+	if pool := configuration.Config.PortPool; pool != nil {
+		response.HTTPPort, _ = pool.GetHTTPPort(node.ID)
+		response.WSPort, _ = pool.GetWSPort(node.ID)
+	}
+	return response
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	tunnelMocks "github.com/NodeFactoryIo/vedran/mocks/http-tunnel/server"
+	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	muxhelpper "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApiController_AdminGetNodesHandler(t *testing.T) {
+	pingTime := time.Date(2020, 10, 10, 12, 0, 0, 0, time.UTC)
+	nodeRepoMock := mocks.NodeRepository{}
+	nodeRepoMock.On("GetAll").Return(&[]models.Node{
+		{ID: "1", PayoutAddress: "address-1", Active: true},
+		{ID: "2", PayoutAddress: "address-2", Active: true, Cooldown: 4},
+	}, nil)
+	nodeRepoMock.On("IsNodeActive", "1").Return(true)
+	nodeRepoMock.On("IsNodeActive", "2").Return(false)
+	pingRepoMock := mocks.PingRepository{}
+	pingRepoMock.On("FindByNodeID", "1").Return(&models.Ping{NodeId: "1", Timestamp: pingTime}, nil)
+	pingRepoMock.On("FindByNodeID", "2").Return(nil, errors.New("not found"))
+	metricsRepoMock := mocks.MetricsRepository{}
+	metricsRepoMock.On("FindByID", "1").Return(&models.Metrics{NodeId: "1", BestBlockHeight: 100, PeerCount: 5}, nil)
+	metricsRepoMock.On("FindByID", "2").Return(nil, errors.New("not found"))
+	probeRepoMock := mocks.ProbeRepository{}
+	probeRepoMock.On("FindByNodeID", "1").Return(&models.Probe{
+		NodeId: "1", BestBlockHeight: 101, Latency: 40 * time.Millisecond, Timestamp: pingTime,
+	}, nil)
+	probeRepoMock.On("FindByNodeID", "2").Return(nil, errors.New("not found"))
+	poolerMock := tunnelMocks.Pooler{}
+	poolerMock.On("GetHTTPPort", "1").Return(20001, nil)
+	poolerMock.On("GetWSPort", "1").Return(20002, nil)
+	poolerMock.On("GetHTTPPort", "2").Return(0, errors.New("no tunnel"))
+	poolerMock.On("GetWSPort", "2").Return(0, errors.New("no tunnel"))
+	configuration.Config.PortPool = &poolerMock
+	defer func() { configuration.Config.PortPool = nil }()
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:    &nodeRepoMock,
+		PingRepo:    &pingRepoMock,
+		MetricsRepo: &metricsRepoMock,
+		ProbeRepo:   &probeRepoMock,
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/api/v1/admin/nodes", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(apiController.AdminGetNodesHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response []AdminNodeResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.Len(t, response, 2)
+
+	assert.True(t, response[0].Active)
+	assert.False(t, response[0].Penalized)
+	assert.Equal(t, pingTime, *response[0].LastPing)
+	assert.Equal(t, int64(100), response[0].Metrics.BestBlockHeight)
+	assert.Equal(t, int32(5), response[0].Metrics.PeerCount)
+	assert.Equal(t, int64(101), response[0].Probe.BestBlockHeight)
+	assert.Equal(t, int64(40), response[0].Probe.LatencyMs)
+	assert.Equal(t, 20001, response[0].HTTPPort)
+	assert.Equal(t, 20002, response[0].WSPort)
+
+	assert.False(t, response[1].Active)
+	assert.True(t, response[1].Penalized)
+	assert.Equal(t, 4, response[1].Cooldown)
+	assert.Nil(t, response[1].LastPing)
+	assert.Nil(t, response[1].Metrics)
+	assert.Nil(t, response[1].Probe)
+	assert.Equal(t, 0, response[1].HTTPPort)
+}
+
+func TestApiController_AdminNodeActionHandler(t *testing.T) {
+	_ = auth.SetAuthSecret("test-auth-secret")
+	nodeToken, _ := auth.CreateNewToken("1")
+	tests := []struct {
+		name                      string
+		action                    string
+		node                      *models.Node
+		findNodeError             error
+		isNodeActive              bool
+		httpStatus                int
+		savedNode                 *models.Node
+		removeFromActiveNumOfCall int
+		addToActiveNumOfCalls     int
+		resetCooldownNumOfCalls   int
+		revokeTokenNumOfCalls     int
+	}{
+		{
+			name:                      "ban active node",
+			action:                    "ban",
+			node:                      &models.Node{ID: "1", Active: true},
+			isNodeActive:              true,
+			httpStatus:                http.StatusOK,
+			savedNode:                 &models.Node{ID: "1", Active: true, Banned: true},
+			removeFromActiveNumOfCall: 1,
+		},
+		{
+			name:       "force activate banned node",
+			action:     "activate",
+			node:       &models.Node{ID: "1", Active: true, Banned: true},
+			httpStatus: http.StatusConflict,
+		},
+		{
+			name:       "force activate node serving wrong chain",
+			action:     "activate",
+			node:       &models.Node{ID: "1", Active: true, ChainError: "node serves wrong chain"},
+			httpStatus: http.StatusConflict,
+		},
+		{
+			name:                  "force activate deactivated node",
+			action:                "activate",
+			node:                  &models.Node{ID: "1", Active: false, Cooldown: 2048, Draining: true},
+			httpStatus:            http.StatusOK,
+			savedNode:             &models.Node{ID: "1", Active: true},
+			addToActiveNumOfCalls: 1,
+		},
+		{
+			name:                      "drain active node",
+			action:                    "drain",
+			node:                      &models.Node{ID: "1", Active: true},
+			isNodeActive:              true,
+			httpStatus:                http.StatusOK,
+			savedNode:                 &models.Node{ID: "1", Active: true, Draining: true},
+			removeFromActiveNumOfCall: 1,
+		},
+		{
+			name:                    "reset cooldown of node",
+			action:                  "reset-cooldown",
+			node:                    &models.Node{ID: "1", Active: true, Cooldown: 8},
+			isNodeActive:            true,
+			httpStatus:              http.StatusOK,
+			resetCooldownNumOfCalls: 1,
+		},
+		{
+			name:                  "revoke token of node",
+			action:                "revoke-token",
+			node:                  &models.Node{ID: "1", Active: true, Token: nodeToken},
+			isNodeActive:          true,
+			httpStatus:            http.StatusOK,
+			revokeTokenNumOfCalls: 1,
+		},
+		{
+			name:       "unknown action",
+			action:     "delete",
+			node:       &models.Node{ID: "1", Active: true},
+			httpStatus: http.StatusNotFound,
+		},
+		{
+			name:          "unknown node",
+			action:        "ban",
+			findNodeError: errors.New("not found"),
+			httpStatus:    http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodeRepoMock := mocks.NodeRepository{}
+			nodeRepoMock.On("FindByID", "1").Return(test.node, test.findNodeError)
+			nodeRepoMock.On("Save", mock.Anything).Return(nil)
+			nodeRepoMock.On("IsNodeActive", "1").Return(test.isNodeActive)
+			nodeRepoMock.On("RemoveNodeFromActive", "1").Return(nil)
+			nodeRepoMock.On("AddNodeToActive", "1").Return(nil)
+			nodeRepoMock.On("ResetNodeCooldown", "1").Return(&models.Node{ID: "1", Active: true}, nil)
+			pingRepoMock := mocks.PingRepository{}
+			pingRepoMock.On("FindByNodeID", "1").Return(nil, errors.New("not found"))
+			metricsRepoMock := mocks.MetricsRepository{}
+			metricsRepoMock.On("FindByID", "1").Return(nil, errors.New("not found"))
+			revokedTokenRepoMock := mocks.RevokedTokenRepository{}
+			revokedTokenRepoMock.On("Save", mock.Anything).Return(nil)
+			probeRepoMock := mocks.ProbeRepository{}
+			probeRepoMock.On("FindByNodeID", "1").Return(nil, errors.New("not found"))
+
+			apiController := NewApiController(false, repositories.Repos{
+				NodeRepo:         &nodeRepoMock,
+				PingRepo:         &pingRepoMock,
+				MetricsRepo:      &metricsRepoMock,
+				RevokedTokenRepo: &revokedTokenRepoMock,
+				ProbeRepo:        &probeRepoMock,
+			}, nil)
+
+			req, _ := http.NewRequest("POST", "/api/v1/admin/nodes/1/"+test.action, nil)
+			req = muxhelpper.SetURLVars(req, map[string]string{"id": "1", "action": test.action})
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(apiController.AdminNodeActionHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, test.httpStatus, rr.Code)
+			if test.savedNode != nil {
+				nodeRepoMock.AssertCalled(t, "Save", test.savedNode)
+			} else {
+				nodeRepoMock.AssertNotCalled(t, "Save", mock.Anything)
+			}
+			nodeRepoMock.AssertNumberOfCalls(t, "RemoveNodeFromActive", test.removeFromActiveNumOfCall)
+			nodeRepoMock.AssertNumberOfCalls(t, "AddNodeToActive", test.addToActiveNumOfCalls)
+			nodeRepoMock.AssertNumberOfCalls(t, "ResetNodeCooldown", test.resetCooldownNumOfCalls)
+			revokedTokenRepoMock.AssertNumberOfCalls(t, "Save", test.revokeTokenNumOfCalls)
+			if rr.Code == http.StatusOK {
+				var response AdminNodeResponse
+				_ = json.Unmarshal(rr.Body.Bytes(), &response)
+				assert.Equal(t, "1", response.ID)
+				assert.Equal(t, 0, response.Cooldown)
+			}
+		})
+	}
+}
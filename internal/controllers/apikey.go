@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/apikey"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	muxhelpper "github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+type CreateAPIKeyRequest struct {
+	Name       string  `json:"name"`
+	RateLimit  float64 `json:"rate_limit"`
+	DailyQuota int64   `json:"daily_quota"`
+}
+
+type APIKeyResponse struct {
+	Key           string    `json:"key"`
+	Name          string    `json:"name"`
+	RateLimit     float64   `json:"rate_limit"`
+	DailyQuota    int64     `json:"daily_quota"`
+	CreatedAt     time.Time `json:"created_at"`
+	RequestsToday int64     `json:"requests_today"`
+}
+
+type APIKeyUsageResponse struct {
+	Name          string           `json:"name"`
+	RateLimit     float64          `json:"rate_limit"`
+	DailyQuota    int64            `json:"daily_quota"`
+	RequestsToday int64            `json:"requests_today"`
+	Usage         map[string]int64 `json:"usage"`
+}
+
+// handler for `POST /api/v1/keys` - signature verification in middleware
+func (c *ApiController) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var request CreateAPIKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Name == "" || request.RateLimit < 0 || request.DailyQuota < 0 {
+		log.Errorf("Invalid api key request: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	apiKey, err := apikey.NewAPIKey(request.Name, request.RateLimit, request.DailyQuota)
+	if err != nil {
+		log.Errorf("Failed generating api key, because %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	err = c.repositories.APIKeyRepo.Save(apiKey)
+	if err != nil {
+		log.Errorf("Failed saving api key, because %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(newAPIKeyResponse(*apiKey))
+}
+
+// handler for `GET /api/v1/keys` - signature verification in middleware
+func (c *ApiController) GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	apiKeys, err := c.repositories.APIKeyRepo.GetAll()
+	if err != nil {
+		log.Errorf("Failed fetching api keys, because %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]APIKeyResponse, len(*apiKeys))
+	for i, apiKey := range *apiKeys {
+		response[i] = newAPIKeyResponse(apiKey)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// handler for `DELETE /api/v1/keys/{key}` - signature verification in middleware
+func (c *ApiController) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key := muxhelpper.Vars(r)["key"]
+	err := c.repositories.APIKeyRepo.Delete(key)
+	if err != nil {
+		log.Errorf("Failed revoking api key, because %v", err)
+		if err.Error() == "not found" {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+	apikey.Forget(key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handler for `GET /api/v1/stats/keys/{key}`
+func (c *ApiController) StatisticsHandlerStatsForAPIKey(w http.ResponseWriter, r *http.Request) {
+	key := muxhelpper.Vars(r)["key"]
+	apiKey, err := c.repositories.APIKeyRepo.FindByKey(key)
+	if err != nil {
+		if err.Error() == "not found" {
+			http.NotFound(w, r)
+		} else {
+			log.Errorf("Failed fetching api key, because %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	usage, err := c.repositories.APIKeyRepo.GetUsage(key)
+	if err != nil {
+		log.Errorf("Failed fetching api key usage, because %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	response := APIKeyUsageResponse{
+		Name:       apiKey.Name,
+		RateLimit:  apiKey.RateLimit,
+		DailyQuota: apiKey.DailyQuota,
+		Usage:      make(map[string]int64, len(usage)+1),
+	}
+	for _, u := range usage {
+		response.Usage[u.Date] = u.Requests
+	}
+	// usage for today that is not yet saved to database
+	today := getNow().UTC().Format(apikey.DateLayout)
+	if requests := apikey.RequestsToday(key); requests > response.Usage[today] {
+		response.Usage[today] = requests
+	}
+	response.RequestsToday = response.Usage[today]
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func newAPIKeyResponse(apiKey models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		Key:           apiKey.Key,
+		Name:          apiKey.Name,
+		RateLimit:     apiKey.RateLimit,
+		DailyQuota:    apiKey.DailyQuota,
+		CreatedAt:     apiKey.CreatedAt,
+		RequestsToday: apikey.RequestsToday(apiKey.Key),
+	}
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/apikey"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	muxhelpper "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApiController_CreateAPIKeyHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		saveError  error
+		httpStatus int
+	}{
+		{
+			name:       "create valid api key",
+			body:       `{"name": "consumer", "rate_limit": 10, "daily_quota": 1000}`,
+			httpStatus: http.StatusCreated,
+		},
+		{
+			name:       "reject api key without name",
+			body:       `{"rate_limit": 10}`,
+			httpStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "reject api key with negative limit",
+			body:       `{"name": "consumer", "rate_limit": -1}`,
+			httpStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "saving api key fails",
+			body:       `{"name": "consumer"}`,
+			saveError:  errors.New("db error"),
+			httpStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiKeyRepoMock := mocks.APIKeyRepository{}
+			apiKeyRepoMock.On("Save", mock.Anything).Return(test.saveError)
+			apiController := NewApiController(false, repositories.Repos{
+				APIKeyRepo: &apiKeyRepoMock,
+			}, nil)
+
+			req, _ := http.NewRequest("POST", "/api/v1/keys", bytes.NewReader([]byte(test.body)))
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(apiController.CreateAPIKeyHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, test.httpStatus, rr.Code, fmt.Sprintf("Response status code should be %d", test.httpStatus))
+			if rr.Code == http.StatusCreated {
+				var response APIKeyResponse
+				_ = json.Unmarshal(rr.Body.Bytes(), &response)
+				assert.Len(t, response.Key, 32)
+				assert.Equal(t, "consumer", response.Name)
+				assert.Equal(t, float64(10), response.RateLimit)
+				assert.Equal(t, int64(1000), response.DailyQuota)
+			}
+		})
+	}
+}
+
+func TestApiController_StatisticsHandlerStatsForAPIKey(t *testing.T) {
+	now := time.Date(2020, 10, 10, 12, 0, 0, 0, time.UTC)
+	getNow = func() time.Time { return now }
+	defer func() { getNow = time.Now }()
+
+	tests := []struct {
+		name              string
+		findByKeyReturns  *models.APIKey
+		findByKeyError    error
+		getUsageReturns   []models.APIKeyUsage
+		httpStatus        int
+		wantRequestsToday int64
+	}{
+		{
+			name:             "get usage for api key",
+			findByKeyReturns: &models.APIKey{Key: "key", Name: "consumer", DailyQuota: 100},
+			getUsageReturns: []models.APIKeyUsage{
+				{Key: "key", Date: "2020-10-09", Requests: 50},
+				{Key: "key", Date: "2020-10-10", Requests: 20},
+			},
+			httpStatus:        http.StatusOK,
+			wantRequestsToday: 20,
+		},
+		{
+			name:           "unknown api key",
+			findByKeyError: errors.New("not found"),
+			httpStatus:     http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiKeyRepoMock := mocks.APIKeyRepository{}
+			apiKeyRepoMock.On("FindByKey", "key").Return(test.findByKeyReturns, test.findByKeyError)
+			apiKeyRepoMock.On("GetUsage", "key").Return(test.getUsageReturns, nil)
+			apikey.InitAPIKeys(&apiKeyRepoMock, true, 0, 0)
+			apiController := NewApiController(false, repositories.Repos{
+				APIKeyRepo: &apiKeyRepoMock,
+			}, nil)
+
+			req, _ := http.NewRequest("GET", "/api/v1/stats/keys/key", bytes.NewReader(nil))
+			rr := httptest.NewRecorder()
+			router := muxhelpper.NewRouter()
+			router.HandleFunc("/api/v1/stats/keys/{key}", apiController.StatisticsHandlerStatsForAPIKey)
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.httpStatus, rr.Code, fmt.Sprintf("Response status code should be %d", test.httpStatus))
+			if rr.Code == http.StatusOK {
+				var response APIKeyUsageResponse
+				_ = json.Unmarshal(rr.Body.Bytes(), &response)
+				assert.Equal(t, "consumer", response.Name)
+				assert.Equal(t, test.wantRequestsToday, response.RequestsToday)
+				assert.Len(t, response.Usage, len(test.getUsageReturns))
+			}
+		})
+	}
+}
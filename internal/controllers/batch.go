@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/record"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/NodeFactoryIo/vedran/internal/selection"
+	log "github.com/sirupsen/logrus"
+)
+
+type chunkResult struct {
+	node      models.Node
+	reqs      []rpc.RPCRequest
+	responses map[string]rpc.BatchEntryResponse
+	err       error
+}
+
+// sendSplitBatch splits batch requests into chunks that are sent in parallel to active nodes and
+// merges responses in order of requests. Chunks that couldn't be sent or parsed and entries node
+// didn't answer are retried on other nodes, while nodes that failed are not used again for this batch
+func (c ApiController) sendSplitBatch(reqs []rpc.RPCRequest, nodes []models.Node, chunkSize int) ([]byte, error) {
+	selector := selection.For(configuration.Config.Selection)
+	responses := make(map[string]rpc.BatchEntryResponse, len(reqs))
+	pending := reqs
+	available := nodes
+
+	for len(pending) != 0 && len(available) != 0 {
+		chunks := rpc.SplitBatch(pending, chunkSize)
+		results := make(chan chunkResult, len(chunks))
+		for i, chunk := range chunks {
+			go func(node models.Node, chunk []rpc.RPCRequest) {
+				results <- c.sendBatchChunk(node, chunk, selector)
+			}(available[i%len(available)], chunk)
+		}
+
+		pending = nil
+		failedNodes := make(map[string]bool)
+		for range chunks {
+			result := <-results
+			if result.err != nil {
+				log.Errorf("Batch chunk failed on node %s because of: %v", result.node.ID, result.err)
+				go record.FailedRequest(result.node, c.repositories, c.actions)
+				failedNodes[result.node.ID] = true
+				pending = append(pending, result.reqs...)
+				continue
+			}
+
+			go record.SuccessfulRequest(result.node, c.repositories)
+			for _, req := range result.reqs {
+				if req.IsNotification() {
+					continue
+				}
+				// rpc error responses are returned unchanged, as request itself can be invalid
+				key := rpc.IDKey(req.ID)
+				response, ok := result.responses[key]
+				if !ok {
+					failedNodes[result.node.ID] = true
+					pending = append(pending, req)
+					continue
+				}
+				responses[key] = response
+			}
+		}
+
+		var stillAvailable []models.Node
+		for _, node := range available {
+			if !failedNodes[node.ID] {
+				stillAvailable = append(stillAvailable, node)
+			}
+		}
+		available = stillAvailable
+	}
+
+	if len(responses) == 0 && len(pending) != 0 {
+		return nil, errors.New("all nodes failed to serve batch request")
+	}
+	if len(pending) != 0 {
+		log.Debugf("%d batch entries were not served by any node", len(pending))
+	}
+	return rpc.MergeBatchResponses(reqs, responses)
+}
+
+func (c ApiController) sendBatchChunk(node models.Node, reqs []rpc.RPCRequest, selector selection.Selector) chunkResult {
+	result := chunkResult{node: node, reqs: reqs}
+
+	reqBody, err := json.Marshal(reqs)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	selector.RequestStarted(node.ID)
+	start := time.Now()
+	body, err := rpc.SendRequestToNode(true, node.ID, reqBody)
+	selector.RequestFinished(node.ID, time.Since(start), err)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	result.responses, result.err = rpc.ParseBatchResponse(body)
+	return result
+}
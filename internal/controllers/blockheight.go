@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/NodeFactoryIo/vedran/internal/blockheight"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+)
+
+// MinBlockHeightHeader is header in which client can send minimum block height node must have
+const MinBlockHeightHeader = "X-Min-Block-Height"
+
+var (
+	errNoNodesAtHeight       = errors.New("no nodes at requested block height")
+	errInvalidMinBlockHeight = errors.New("invalid min block height header")
+)
+
+// nodesAtHeight returns nodes that have block height required by client header and blocks
+// referenced by requests. If no node has block referenced by request all nodes are returned,
+// as node answer is correct for blocks that don't exist yet, while minimum height from
+// header is always enforced
+func nodesAtHeight(r *http.Request, nodes []models.Node, reqs []rpc.RPCRequest) ([]models.Node, error) {
+	minHeight, err := minBlockHeightFromHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	activeNodes := nodes
+	nodes = blockheight.FilterNodes(nodes, minHeight)
+	if len(nodes) == 0 {
+		return nil, errNoNodesAtHeight
+	}
+
+	var requiredHeight int64
+	for _, req := range reqs {
+		if height := blockheight.RequiredHeight(req, activeNodes); height > requiredHeight {
+			requiredHeight = height
+		}
+	}
+	if filtered := blockheight.FilterNodes(nodes, requiredHeight); len(filtered) != 0 {
+		return filtered, nil
+	}
+	return nodes, nil
+}
+
+func minBlockHeightFromHeader(r *http.Request) (int64, error) {
+	header := r.Header.Get(MinBlockHeightHeader)
+	if header == "" {
+		return 0, nil
+	}
+	minHeight, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || minHeight < 0 {
+		return 0, errInvalidMinBlockHeight
+	}
+	return minHeight, nil
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/record"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/NodeFactoryIo/vedran/internal/selection"
+	log "github.com/sirupsen/logrus"
+)
+
+type broadcastResult struct {
+	node     models.Node
+	body     []byte
+	response rpc.RPCResponse
+	err      error
+}
+
+// sendBroadcastRequest sends extrinsic submission to all nodes at once and returns first response
+// containing extrinsic hash. If no node accepted extrinsic, response with node error is returned
+// (e.g. extrinsic already imported or invalid). Every node that returned valid response is rewarded
+// because each of them validated and propagated extrinsic, while nodes that failed are penalized.
+// Returned error is rpc.NotSentError only if request wasn't sent to any node, so it can be retried
+func (c ApiController) sendBroadcastRequest(reqBody []byte, nodes []models.Node) ([]byte, error) {
+	selector := selection.For(configuration.Config.Selection)
+	results := make(chan broadcastResult, len(nodes))
+	for _, node := range nodes {
+		go func(node models.Node) {
+			selector.RequestStarted(node.ID)
+			start := time.Now()
+			body, err := rpc.SendRequestToNode(false, node.ID, reqBody)
+			selector.RequestFinished(node.ID, time.Since(start), err)
+
+			result := broadcastResult{node: node, body: body, err: err}
+			if err == nil {
+				err = json.Unmarshal(body, &result.response)
+				if err != nil {
+					result.err = err
+				}
+			}
+			if result.err != nil {
+				log.Errorf("Extrinsic broadcast failed to node %s because of: %v", node.ID, result.err)
+				go record.FailedRequest(node, c.repositories, c.actions)
+			} else {
+				go record.SuccessfulRequest(node, c.repositories)
+			}
+			results <- result
+		}(node)
+	}
+
+	var fallback *broadcastResult
+	notSent := 0
+	for range nodes {
+		result := <-results
+		if result.err != nil {
+			if rpc.IsNotSent(result.err) {
+				notSent++
+			}
+			continue
+		}
+		if result.response.Error == nil {
+			return result.body, nil
+		}
+		// already imported means that node accepted extrinsic earlier, so it is preferred
+		// over other node errors
+		if fallback == nil || result.response.Error.Code == rpc.AlreadyImported {
+			fallback = &result
+		}
+	}
+
+	if fallback != nil {
+		return fallback.body, nil
+	}
+	err := errors.New("extrinsic broadcast failed on all nodes")
+	if notSent == len(nodes) {
+		return nil, &rpc.NotSentError{Err: err}
+	}
+	return nil, err
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/hedge"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/record"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/NodeFactoryIo/vedran/internal/selection"
+	log "github.com/sirupsen/logrus"
+)
+
+type hedgeResult struct {
+	node    models.Node
+	body    []byte
+	err     error
+	latency time.Duration
+}
+
+// sendHedgedRequest sends request to first node and, if node doesn't respond in delay, sends
+// same request to next node. First valid response is returned and other requests are canceled.
+// Only node whose response is used is rewarded, canceled requests are not penalized
+func (c ApiController) sendHedgedRequest(reqBody []byte, nodes []models.Node, delay time.Duration) ([]byte, error) {
+	selector := selection.For(configuration.Config.Selection)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan hedgeResult, len(nodes))
+	send := func(node models.Node) {
+		selector.RequestStarted(node.ID)
+		start := time.Now()
+		body, err := rpc.SendRequestToNodeWithContext(ctx, false, node.ID, reqBody)
+		latency := time.Since(start)
+		if ctx.Err() != nil {
+			// request canceled because other node already responded
+			selector.RequestFinished(node.ID, latency, nil)
+		} else {
+			selector.RequestFinished(node.ID, latency, err)
+		}
+		results <- hedgeResult{node: node, body: body, err: err, latency: latency}
+	}
+
+	go send(nodes[0])
+	launched, pending := 1, 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if launched < len(nodes) {
+				log.Debugf("Node %s didn't respond in %v, hedging request to node %s", nodes[0].ID, delay, nodes[launched].ID)
+				go send(nodes[launched])
+				launched++
+				pending++
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				log.Errorf("Request failed to node %s because of: %v", result.node.ID, result.err)
+				go record.FailedRequest(result.node, c.repositories, c.actions)
+				if launched < len(nodes) {
+					go send(nodes[launched])
+					launched++
+					pending++
+				}
+				continue
+			}
+
+			// latency of node that responded is recorded, without delay before request was hedged
+			hedge.RecordLatency(result.latency)
+			go record.SuccessfulRequest(result.node, c.repositories)
+			return result.body, nil
+		}
+	}
+
+	return nil, errors.New("hedged request failed on all nodes")
+}
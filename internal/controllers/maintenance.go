@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/blockheight"
+	"github.com/NodeFactoryIo/vedran/internal/capacity"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	log "github.com/sirupsen/logrus"
+)
+
+type MaintenanceResponse struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// handler for `POST /api/v1/nodes/maintenance` - node stops getting new requests until it ends
+// maintenance, open connections of node are kept until clients close them
+func (c ApiController) StartMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	request := r.Context().Value(auth.RequestContextKey).(*auth.RequestContext)
+
+	node, err := c.repositories.NodeRepo.FindByID(request.NodeId)
+	if err != nil {
+		log.Errorf("Unable to find node %s, error: %v", request.NodeId, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	maintenance, err := c.repositories.MaintenanceRepo.FindOngoing(node.ID)
+	if err != nil {
+		if err.Error() != "not found" {
+			log.Errorf("Unable to check maintenance of node %s, error: %v", node.ID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		maintenance = &models.Maintenance{NodeId: node.ID, Start: request.Timestamp}
+		err = c.repositories.MaintenanceRepo.Save(maintenance)
+		if err != nil {
+			log.Errorf("Unable to save maintenance of node %s, error: %v", node.ID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	node.Maintenance = true
+	err = c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		log.Errorf("Unable to save node %s, error: %v", node.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	c.removeFromActive(node.ID)
+
+	log.Infof("Node %s started maintenance", node.ID)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(MaintenanceResponse{Start: maintenance.Start})
+}
+
+// handler for `DELETE /api/v1/nodes/maintenance` - node is added to active nodes
+// once its metrics are valid
+func (c ApiController) EndMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	request := r.Context().Value(auth.RequestContextKey).(*auth.RequestContext)
+
+	node, err := c.repositories.NodeRepo.FindByID(request.NodeId)
+	if err != nil {
+		log.Errorf("Unable to find node %s, error: %v", request.NodeId, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	maintenance, err := c.endMaintenance(node.ID, request.Timestamp)
+	if err != nil {
+		if err.Error() == "not found" {
+			http.NotFound(w, r)
+		} else {
+			log.Errorf("Unable to end maintenance of node %s, error: %v", node.ID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	node.Maintenance = false
+	err = c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		log.Errorf("Unable to save node %s, error: %v", node.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	err = c.activateIfReady(node.ID)
+	if err != nil {
+		log.Errorf("Unable to activate node %s, error: %v", node.ID, err)
+	}
+
+	log.Infof("Node %s ended maintenance", node.ID)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(MaintenanceResponse{Start: maintenance.Start, End: &maintenance.End})
+}
+
+// handler for `DELETE /api/v1/nodes` - node leaves pool until it registers again, open
+// connections of node are kept until clients close them
+func (c ApiController) DeregisterHandler(w http.ResponseWriter, r *http.Request) {
+	request := r.Context().Value(auth.RequestContextKey).(*auth.RequestContext)
+
+	node, err := c.repositories.NodeRepo.FindByID(request.NodeId)
+	if err != nil {
+		log.Errorf("Unable to find node %s, error: %v", request.NodeId, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	_, err = c.endMaintenance(node.ID, request.Timestamp)
+	if err != nil && err.Error() != "not found" {
+		log.Errorf("Unable to end maintenance of node %s, error: %v", node.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	node.Maintenance = false
+	node.Left = true
+	node.Waiting = false
+	err = c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		log.Errorf("Unable to save node %s, error: %v", node.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	c.removeFromActive(node.ID)
+	blockheight.RemoveNode(node.ID)
+	capacity.PromoteWaitingNodes(c.repositories)
+
+	log.Infof("Node %s left pool", node.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// endMaintenance ends maintenance of node that is in progress
+func (c ApiController) endMaintenance(nodeID string, end time.Time) (*models.Maintenance, error) {
+	maintenance, err := c.repositories.MaintenanceRepo.FindOngoing(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	maintenance.End = end
+	return maintenance, c.repositories.MaintenanceRepo.Save(maintenance)
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApiController_MaintenanceHandlers(t *testing.T) {
+	now := time.Now()
+	ongoing := &models.Maintenance{ID: 1, NodeId: "1", Start: now.Add(-time.Hour)}
+	tests := []struct {
+		name    string
+		method  string
+		handler func(c *ApiController) http.HandlerFunc
+		node    *models.Node
+		// MaintenanceRepo.FindOngoing
+		findOngoingReturns *models.Maintenance
+		findOngoingError   error
+		// NodeRepo.IsNodeActive
+		isNodeActive bool
+		httpStatus   int
+		// NodeRepo.Save
+		savedNode *models.Node
+		// MaintenanceRepo.Save
+		savedMaintenance *models.Maintenance
+		// NodeRepo.RemoveNodeFromActive
+		removeFromActiveNumOfCalls int
+		// NodeRepo.AddNodeToActive
+		addToActiveNumOfCalls int
+	}{
+		{
+			name:   "start maintenance of active node",
+			method: "POST",
+			handler: func(c *ApiController) http.HandlerFunc {
+				return c.StartMaintenanceHandler
+			},
+			node:                       &models.Node{ID: "1", Active: true},
+			findOngoingError:           errors.New("not found"),
+			isNodeActive:               true,
+			httpStatus:                 http.StatusOK,
+			savedNode:                  &models.Node{ID: "1", Active: true, Maintenance: true},
+			savedMaintenance:           &models.Maintenance{NodeId: "1", Start: now},
+			removeFromActiveNumOfCalls: 1,
+		},
+		{
+			name:   "start maintenance of node already in maintenance",
+			method: "POST",
+			handler: func(c *ApiController) http.HandlerFunc {
+				return c.StartMaintenanceHandler
+			},
+			node:               &models.Node{ID: "1", Active: true, Maintenance: true},
+			findOngoingReturns: ongoing,
+			httpStatus:         http.StatusOK,
+			savedNode:          &models.Node{ID: "1", Active: true, Maintenance: true},
+		},
+		{
+			name:   "end maintenance of node",
+			method: "DELETE",
+			handler: func(c *ApiController) http.HandlerFunc {
+				return c.EndMaintenanceHandler
+			},
+			node:                  &models.Node{ID: "1", Active: true, Maintenance: true},
+			findOngoingReturns:    ongoing,
+			httpStatus:            http.StatusOK,
+			savedNode:             &models.Node{ID: "1", Active: true},
+			savedMaintenance:      &models.Maintenance{ID: 1, NodeId: "1", Start: ongoing.Start, End: now},
+			addToActiveNumOfCalls: 1,
+		},
+		{
+			name:   "end maintenance of node not in maintenance",
+			method: "DELETE",
+			handler: func(c *ApiController) http.HandlerFunc {
+				return c.EndMaintenanceHandler
+			},
+			node:             &models.Node{ID: "1", Active: true},
+			findOngoingError: errors.New("not found"),
+			isNodeActive:     true,
+			httpStatus:       http.StatusNotFound,
+		},
+		{
+			name:   "deregister node in maintenance",
+			method: "DELETE",
+			handler: func(c *ApiController) http.HandlerFunc {
+				return c.DeregisterHandler
+			},
+			node:               &models.Node{ID: "1", Active: true, Maintenance: true},
+			findOngoingReturns: ongoing,
+			httpStatus:         http.StatusNoContent,
+			savedNode:          &models.Node{ID: "1", Active: true, Left: true},
+			savedMaintenance:   &models.Maintenance{ID: 1, NodeId: "1", Start: ongoing.Start, End: now},
+		},
+		{
+			name:   "deregister active node",
+			method: "DELETE",
+			handler: func(c *ApiController) http.HandlerFunc {
+				return c.DeregisterHandler
+			},
+			node:                       &models.Node{ID: "1", Active: true},
+			findOngoingError:           errors.New("not found"),
+			isNodeActive:               true,
+			httpStatus:                 http.StatusNoContent,
+			savedNode:                  &models.Node{ID: "1", Active: true, Left: true},
+			removeFromActiveNumOfCalls: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodeRepoMock := mocks.NodeRepository{}
+			nodeRepoMock.On("FindByID", "1").Return(test.node, nil)
+			nodeRepoMock.On("Save", mock.Anything).Return(nil)
+			nodeRepoMock.On("IsNodeActive", "1").Return(test.isNodeActive)
+			nodeRepoMock.On("IsNodeOnCooldown", "1").Return(false, nil)
+			nodeRepoMock.On("RemoveNodeFromActive", "1").Return(nil)
+			nodeRepoMock.On("AddNodeToActive", "1").Return(nil)
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			if test.findOngoingReturns != nil {
+				// copy so saved maintenance of one test doesn't affect others
+				maintenance := *test.findOngoingReturns
+				maintenanceRepoMock.On("FindOngoing", "1").Return(&maintenance, nil)
+			} else {
+				maintenanceRepoMock.On("FindOngoing", "1").Return(nil, test.findOngoingError)
+			}
+			maintenanceRepoMock.On("Save", mock.Anything).Return(nil)
+			metricsRepoMock := mocks.MetricsRepository{}
+			metricsRepoMock.On("FindByID", "1").Return(&models.Metrics{
+				NodeId:               "1",
+				BestBlockHeight:      1000,
+				FinalizedBlockHeight: 995,
+				TargetBlockHeight:    1000,
+				Timestamp:            now,
+			}, nil)
+			metricsRepoMock.On("GetLatestBlockMetrics").Return(&models.LatestBlockMetrics{
+				BestBlockHeight:      1000,
+				FinalizedBlockHeight: 995,
+			}, nil)
+
+			apiController := NewApiController(false, repositories.Repos{
+				NodeRepo:        &nodeRepoMock,
+				MetricsRepo:     &metricsRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
+			}, nil)
+
+			req, _ := http.NewRequest(test.method, "/api/v1/nodes/maintenance", nil)
+			req = req.WithContext(context.WithValue(req.Context(), auth.RequestContextKey, &auth.RequestContext{
+				NodeId:    "1",
+				Timestamp: now,
+			}))
+			rr := httptest.NewRecorder()
+			test.handler(apiController).ServeHTTP(rr, req)
+
+			assert.Equal(t, test.httpStatus, rr.Code)
+			if test.savedNode != nil {
+				nodeRepoMock.AssertCalled(t, "Save", test.savedNode)
+			} else {
+				nodeRepoMock.AssertNotCalled(t, "Save", mock.Anything)
+			}
+			if test.savedMaintenance != nil {
+				maintenanceRepoMock.AssertCalled(t, "Save", test.savedMaintenance)
+			} else {
+				maintenanceRepoMock.AssertNotCalled(t, "Save", mock.Anything)
+			}
+			nodeRepoMock.AssertNumberOfCalls(t, "RemoveNodeFromActive", test.removeFromActiveNumOfCalls)
+			nodeRepoMock.AssertNumberOfCalls(t, "AddNodeToActive", test.addToActiveNumOfCalls)
+			if rr.Code == http.StatusOK {
+				var response MaintenanceResponse
+				_ = json.Unmarshal(rr.Body.Bytes(), &response)
+				assert.False(t, response.Start.IsZero())
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/quorum"
+	"github.com/NodeFactoryIo/vedran/internal/record"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/NodeFactoryIo/vedran/internal/selection"
+	log "github.com/sirupsen/logrus"
+)
+
+type quorumAnswer struct {
+	node   models.Node
+	body   []byte
+	answer string
+	err    error
+}
+
+// sendQuorumRequest sends request to all provided nodes in parallel and returns answer
+// of majority of nodes. Nodes that answered differently than majority are penalized, if
+// there is no majority most common answer is returned and no node is penalized
+func (c ApiController) sendQuorumRequest(reqBody []byte, nodes []models.Node) ([]byte, error) {
+	selector := selection.For(configuration.Config.Selection)
+
+	results := make(chan quorumAnswer, len(nodes))
+	for _, node := range nodes {
+		go func(node models.Node) {
+			selector.RequestStarted(node.ID)
+			start := time.Now()
+			body, err := rpc.SendRequestToNode(false, node.ID, reqBody)
+			selector.RequestFinished(node.ID, time.Since(start), err)
+
+			result := quorumAnswer{node: node, body: body, err: err}
+			if err == nil {
+				result.answer, result.err = quorum.Normalize(body)
+			}
+			results <- result
+		}(node)
+	}
+
+	var valid []quorumAnswer
+	for range nodes {
+		result := <-results
+		if result.err != nil {
+			log.Errorf("Request failed to node %s because of: %v", result.node.ID, result.err)
+			go record.FailedRequest(result.node, c.repositories, c.actions)
+			continue
+		}
+		valid = append(valid, result)
+	}
+
+	if len(valid) == 0 {
+		return nil, errors.New("all quorum nodes failed to serve request")
+	}
+
+	answers := make([]string, len(valid))
+	for i, result := range valid {
+		answers[i] = result.answer
+	}
+	majority, ok := quorum.Majority(answers)
+	if !ok {
+		log.Warnf("Quorum not reached between %d nodes, returning most common answer", len(valid))
+	}
+
+	for _, result := range valid {
+		if result.answer == answers[majority] {
+			go record.SuccessfulRequest(result.node, c.repositories)
+		} else if ok {
+			log.Warnf("Node %s answer differs from majority of quorum nodes", result.node.ID)
+			go record.WrongAnswer(result.node, c.repositories, c.actions)
+		}
+	}
+
+	return valid[majority].body, nil
+}
@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/NodeFactoryIo/vedran/internal/whitelist"
 
 	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/capacity"
+	"github.com/NodeFactoryIo/vedran/internal/chain"
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/iplimit"
 	"github.com/NodeFactoryIo/vedran/internal/models"
 	"github.com/NodeFactoryIo/vedran/pkg/util"
 	log "github.com/sirupsen/logrus"
@@ -20,6 +24,28 @@ type RegisterRequest struct {
 	Id            string `json:"id"`
 	ConfigHash    string `json:"config_hash"`
 	PayoutAddress string `json:"payout_address"`
+	Capacity      int64  `json:"capacity,omitempty"`
+	// Signature of challenge made with key behind payout address of node
+	Signature string `json:"signature"`
+	// Nonce returned together with signed challenge
+	Nonce string `json:"nonce"`
+}
+
+// WaitingListRetryAfter is number of seconds after which node on waiting list should register again
+const WaitingListRetryAfter = 60
+
+type WaitingListResponse struct {
+	Message  string `json:"message"`
+	Position int    `json:"position"`
+}
+
+type ChallengeRequest struct {
+	Id string `json:"id"`
+}
+
+type ChallengeResponse struct {
+	Challenge string `json:"challenge"`
+	Nonce     string `json:"nonce"`
 }
 
 type RegisterResponse struct {
@@ -27,6 +53,49 @@ type RegisterResponse struct {
 	TunnelServerAddress string `json:"tunnel_server_address"`
 }
 
+// handler for `POST /api/v1/nodes/challenge` - returns challenge node has to sign to register
+func (c ApiController) ChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	ip := iplimit.ClientIP(r)
+	if !iplimit.AllowChallenge(ip) {
+		log.Debugf("Challenge request from %s rejected because of rate limit", ip)
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+
+	var challengeRequest ChallengeRequest
+	err := util.DecodeJSONBody(w, r, &challengeRequest)
+	if err != nil {
+		var mr *util.MalformedRequest
+		if errors.As(err, &mr) {
+			log.Errorf("Malformed request error: %v", err)
+			http.Error(w, mr.Msg, mr.Status)
+		} else {
+			log.Error(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if challengeRequest.Id == "" {
+		http.Error(w, "Node id is required", http.StatusBadRequest)
+		return
+	}
+	if c.whitelistEnabled && !whitelist.IsNodeWhitelisted(challengeRequest.Id) {
+		http.Error(w, fmt.Sprintf("Node %s is not whitelisted", challengeRequest.Id), http.StatusBadRequest)
+		return
+	}
+
+	nonce, challenge, err := auth.NewChallenge(challengeRequest.Id)
+	if err != nil {
+		log.Errorf("Unable to create challenge for node %s, error: %v", challengeRequest.Id, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ChallengeResponse{Challenge: challenge, Nonce: nonce})
+}
+
 func (c ApiController) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	// decode request body
 	var registerRequest RegisterRequest
@@ -52,40 +121,85 @@ func (c ApiController) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	// look if node already registered
 	node, err := c.repositories.NodeRepo.FindByID(registerRequest.Id)
-	if err != nil {
-		// node not registered
-		if err.Error() == "not found" {
-			// generate auth token
-			token, err := auth.CreateNewToken(registerRequest.Id)
-			if err != nil {
-				// unknown error
-				log.Errorf("Unable to create auth token, error: %v", err)
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
-			}
-
-			// save node to database
-			node = &models.Node{
-				ID:            registerRequest.Id,
-				ConfigHash:    registerRequest.ConfigHash,
-				PayoutAddress: registerRequest.PayoutAddress,
-				Token:         token,
-				LastUsed:      time.Now().Unix(),
-				Active:        true,
-			}
-			err = c.repositories.NodeRepo.Save(node)
-			if err != nil {
-				log.Errorf("Unable to save node %v to database, error: %v", node, err)
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
-			}
-
-			log.Infof("New node %s registered", node.ID)
-		} else {
-			log.Errorf("Unable to check if node %s already created, error: %v", node.ID, err)
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if err != nil && err.Error() != "not found" {
+		log.Errorf("Unable to check if node %s already created, error: %v", registerRequest.Id, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	newNode := err != nil
+	// registered node proves ownership with key of payout address it registered with, so its id
+	// can't be taken over, new node has to sign challenge only if it sends signature
+	address := registerRequest.PayoutAddress
+	if !newNode {
+		address = node.PayoutAddress
+	}
+	if !newNode || registerRequest.Signature != "" {
+		err = auth.VerifyChallenge(registerRequest.Nonce, registerRequest.Id, address, registerRequest.Signature)
+		if err != nil {
+			log.Warnf("Registration of node %s rejected because of: %v", registerRequest.Id, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if newNode {
+		node = &models.Node{
+			ID:            registerRequest.Id,
+			ConfigHash:    registerRequest.ConfigHash,
+			PayoutAddress: registerRequest.PayoutAddress,
+			Capacity:      registerRequest.Capacity,
+			LastUsed:      time.Now().Unix(),
+			Active:        true,
 		}
 	}
+	// node that left pool rejoins it by registering again
+	rejoined := node.Left
+	node.Left = false
+
+	// node gets token only if it gets slot in pool
+	position, err := capacity.Admit(node, c.repositories)
+	if err != nil {
+		log.Errorf("Unable to check capacity for node %s, error: %v", node.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if position > 0 {
+		log.Infof("Node %s is on waiting list at position %d", node.ID, position)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(WaitingListRetryAfter))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(WaitingListResponse{
+			Message:  "Capacity of load balancer is reached, node is on waiting list",
+			Position: position,
+		})
+		return
+	}
+
+	// node serves requests only after chain it serves is verified once its tunnel connects
+	chain.ResetVerification(node)
+
+	// generate auth token, previous token of node is revoked
+	err = c.issueToken(node)
+	if err != nil {
+		log.Errorf("Unable to create auth token, error: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// save node to database
+	err = c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		log.Errorf("Unable to save node %v to database, error: %v", node, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if newNode {
+		log.Infof("New node %s registered", node.ID)
+	} else if rejoined {
+		log.Infof("Node %s rejoined pool", node.ID)
+	}
 
 	// return token
 	w.Header().Set("Content-Type", "application/json")
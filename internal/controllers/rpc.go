@@ -1,16 +1,28 @@
 package controllers
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"time"
 
+	"github.com/NodeFactoryIo/vedran/internal/blockheight"
+	"github.com/NodeFactoryIo/vedran/internal/cache"
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/hedge"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/policy"
+	"github.com/NodeFactoryIo/vedran/internal/quorum"
 	"github.com/NodeFactoryIo/vedran/internal/record"
 	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/NodeFactoryIo/vedran/internal/selection"
 	log "github.com/sirupsen/logrus"
 )
 
+// outcomeUnknownMessage is returned if non idempotent request failed after it was sent to node
+const outcomeUnknownMessage = "Request failed and was not retried, node could have processed it"
+
 func (c ApiController) RPCHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -23,48 +35,256 @@ func (c ApiController) RPCHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// invalid batch entries are answered without forwarding them to nodes, same as
+	// entries with methods not allowed by method policy
 	var reqRPCBody rpc.RPCRequest
 	var reqRPCBodies []rpc.RPCRequest
+	var localResponses []rpc.RPCResponse
+	var errResponse *rpc.RPCResponse
 	isBatch := rpc.IsBatch(reqBody)
 	if isBatch {
-		err = json.Unmarshal(reqBody, &reqRPCBodies)
+		reqRPCBodies, localResponses, errResponse = rpc.ParseBatchRequest(reqBody)
 	} else {
-		err = json.Unmarshal(reqBody, &reqRPCBody)
+		reqRPCBody, errResponse = rpc.ParseRequest(reqBody)
+	}
+	if errResponse != nil {
+		log.Errorf("Request failed because of: %s", errResponse.Error.Message)
+		_ = json.NewEncoder(w).Encode(errResponse)
+		return
 	}
 
-	if err != nil {
-		log.Errorf("Request failed because of: %v", err)
-		_ = json.NewEncoder(w).Encode(
-			rpc.CreateRPCError(isBatch, reqRPCBody, reqRPCBodies, rpc.ParseError, "Parse error"))
+	// reject methods not allowed by method policy, batch requests are forwarded without
+	// blocked entries and errors for blocked entries are appended to batch response
+	if isBatch {
+		var blockedResponses []rpc.RPCResponse
+		reqRPCBodies, blockedResponses = policy.FilterBatch(reqRPCBodies)
+		localResponses = append(localResponses, blockedResponses...)
+		if len(reqRPCBodies) == 0 {
+			log.Debug("Request rejected because all batch requests are invalid or not allowed")
+			_ = json.NewEncoder(w).Encode(localResponses)
+			return
+		}
+		if len(localResponses) != 0 {
+			reqBody, _ = json.Marshal(reqRPCBodies)
+		}
+	} else if !policy.IsMethodAllowed(reqRPCBody.Method) {
+		if reqRPCBody.IsNotification() {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		log.Debugf("Request rejected because method %s is not allowed", reqRPCBody.Method)
+		_ = json.NewEncoder(w).Encode(policy.MethodNotAllowedError(reqRPCBody))
 		return
 	}
 
-	nodes := c.repositories.NodeRepo.GetActiveNodes(configuration.Config.Selection)
-	if len(*nodes) == 0 {
+	// finalized height decides if block hash requests are cached, it is computed once per request
+	var finalizedHeight int64
+	if !isBatch {
+		finalizedHeight = blockheight.FinalizedHeight()
+		if cachedResponse, ok := cache.GetResponse(reqRPCBody, finalizedHeight); ok {
+			_, _ = w.Write(cachedResponse)
+			return
+		}
+	}
+
+	activeNodes := c.repositories.NodeRepo.GetActiveNodes(configuration.Config.Selection)
+	if len(*activeNodes) == 0 {
 		log.Error("Request failed because vedran has no available nodes")
-		_ = json.NewEncoder(w).Encode(
-			rpc.CreateRPCError(isBatch, reqRPCBody, reqRPCBodies, rpc.InternalServerError, "No available nodes"))
+		writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InternalServerError, "No available nodes")
+		return
+	}
+
+	// route request only to nodes that have referenced blocks
+	reqs := reqRPCBodies
+	if !isBatch {
+		reqs = []rpc.RPCRequest{reqRPCBody}
+	}
+	nodes, err := nodesAtHeight(r, *activeNodes, reqs)
+	if err == errInvalidMinBlockHeight {
+		writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InvalidRequest, "Invalid min block height")
+		return
+	} else if err != nil {
+		log.Debugf("Request failed because of: %v", err)
+		writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InternalServerError, "No nodes at requested block height")
 		return
 	}
 
-	for _, node := range *nodes {
+	// non idempotent requests (e.g. author_submitExtrinsic) are retried on other node only
+	// if request wasn't sent to failed node, otherwise node could have already processed it
+	idempotent := rpc.IsIdempotentBatch(reqs)
+
+	// notification is forwarded to first node that accepts it and client doesn't get response
+	if !isBatch && reqRPCBody.IsNotification() {
+		c.sendNotification(reqBody, nodes, idempotent)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// batches with non idempotent requests are not split because failed chunks are retried
+	chunkSize := configuration.Config.BatchChunkSize
+	if isBatch && idempotent && chunkSize > 0 && len(reqRPCBodies) > chunkSize {
+		// responses of chunks are paired with requests by id, so ids have to be unique
+		if rpc.HasDuplicateIDs(reqRPCBodies) {
+			log.Debug("Request rejected because batch contains duplicate ids")
+			writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InvalidRequest, "Duplicate request id in batch")
+			return
+		}
+		byteResponse, err := c.sendSplitBatch(reqRPCBodies, nodes, chunkSize)
+		if err != nil {
+			log.Errorf("Request failed because of: %v", err)
+			writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InternalServerError, "Internal Server Error")
+			return
+		}
+		writeBatchResponse(w, byteResponse, localResponses)
+		return
+	}
+
+	// selected read only requests are cross checked between multiple nodes
+	if !isBatch && len(nodes) > 1 && quorum.IsRequired(reqRPCBody, finalizedHeight) {
+		quorumNodes := nodes
+		if len(quorumNodes) > quorum.Size() {
+			quorumNodes = quorumNodes[:quorum.Size()]
+		}
+		byteResponse, err := c.sendQuorumRequest(reqBody, quorumNodes)
+		if err != nil {
+			log.Errorf("Request failed because of: %v", err)
+			writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InternalServerError, "Internal Server Error")
+			return
+		}
+		cache.SaveResponse(reqRPCBody, byteResponse, finalizedHeight)
+		blockheight.LearnFromResponse(reqRPCBody, byteResponse)
+		_, _ = w.Write(byteResponse)
+		return
+	}
+
+	// extrinsic submissions are broadcast to multiple nodes for faster propagation
+	remainingNodes := nodes
+	broadcastSize := configuration.Config.BroadcastExtrinsics
+	if !isBatch && broadcastSize > 1 && len(remainingNodes) > 1 && rpc.IsBroadcastMethod(reqRPCBody.Method) {
+		if len(remainingNodes) < broadcastSize {
+			broadcastSize = len(remainingNodes)
+		}
+		byteResponse, err := c.sendBroadcastRequest(reqBody, remainingNodes[:broadcastSize])
+		if err == nil {
+			_, _ = w.Write(byteResponse)
+			return
+		}
+		log.Errorf("Request failed because of: %v", err)
+		if !rpc.IsNotSent(err) {
+			writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InternalServerError, outcomeUnknownMessage)
+			return
+		}
+		remainingNodes = remainingNodes[broadcastSize:]
+	}
+
+	// read only requests are hedged to second node if first node is slower than recent requests
+	if delay, ok := hedge.Delay(); ok && !isBatch && len(remainingNodes) > 1 && rpc.IsReadOnlyMethod(reqRPCBody.Method) {
+		byteResponse, err := c.sendHedgedRequest(reqBody, remainingNodes[:2], delay)
+		if err == nil {
+			cache.SaveResponse(reqRPCBody, byteResponse, finalizedHeight)
+			blockheight.LearnFromResponse(reqRPCBody, byteResponse)
+			_, _ = w.Write(byteResponse)
+			return
+		}
+		remainingNodes = remainingNodes[2:]
+	}
+
+	selector := selection.For(configuration.Config.Selection)
+	for _, node := range remainingNodes {
+		selector.RequestStarted(node.ID)
+		start := time.Now()
 		byteResponse, err := rpc.SendRequestToNode(
 			isBatch,
 			node.ID,
 			reqBody,
 		)
+		latency := time.Since(start)
+		selector.RequestFinished(node.ID, latency, err)
 		if err != nil {
 			log.Errorf("Request failed to node %s because of: %v", node.ID, err)
 			go record.FailedRequest(node, c.repositories, c.actions)
+			if !idempotent && !rpc.IsNotSent(err) {
+				log.Error("Request not retried on other node because it is not idempotent")
+				writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InternalServerError, outcomeUnknownMessage)
+				return
+			}
 			continue
 		}
+		if !isBatch {
+			hedge.RecordLatency(latency)
+		}
 
 		go record.SuccessfulRequest(node, c.repositories)
+		if isBatch {
+			writeBatchResponse(w, byteResponse, localResponses)
+			return
+		}
+		cache.SaveResponse(reqRPCBody, byteResponse, finalizedHeight)
+		blockheight.LearnFromResponse(reqRPCBody, byteResponse)
 		_, _ = w.Write(byteResponse)
 		return
 	}
 
 	log.Error("Request failed because all nodes returned invalid rpc response")
-	_ = json.NewEncoder(w).Encode(
-		rpc.CreateRPCError(isBatch, reqRPCBody, reqRPCBodies, rpc.InternalServerError, "Internal Server Error"))
+	writeRPCError(w, isBatch, reqRPCBody, reqRPCBodies, localResponses, rpc.InternalServerError, "Internal Server Error")
+}
+
+// writeRPCError writes rpc errors for appropriate request ids together with responses
+// for batch entries that were not forwarded to nodes
+func writeRPCError(
+	w http.ResponseWriter,
+	isBatch bool,
+	reqRPCBody rpc.RPCRequest,
+	reqRPCBodies []rpc.RPCRequest,
+	batchResponses []rpc.RPCResponse,
+	code int,
+	message string,
+) {
+	rpcError := rpc.CreateRPCError(isBatch, reqRPCBody, reqRPCBodies, code, message)
+	if isBatch {
+		responses := append(rpcError.([]rpc.RPCResponse), batchResponses...)
+		if len(responses) == 0 {
+			// batch contained only notifications
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rpcError = responses
+	}
+	_ = json.NewEncoder(w).Encode(rpcError)
+}
+
+// writeBatchResponse writes batch response from node together with responses for batch entries
+// that were not forwarded to nodes, nothing is written if batch contained only notifications
+func writeBatchResponse(w http.ResponseWriter, body []byte, batchResponses []rpc.RPCResponse) {
+	if len(batchResponses) != 0 {
+		merged, err := rpc.AppendBatchResponses(body, batchResponses)
+		if err == nil {
+			body = merged
+		}
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+// sendNotification sends notification to first node that accepts it, non idempotent
+// notification is not sent to other node if node could have already processed it
+func (c ApiController) sendNotification(reqBody []byte, nodes []models.Node, idempotent bool) {
+	for _, node := range nodes {
+		_, err := rpc.SendRequestToNode(false, node.ID, reqBody)
+		if err != nil {
+			log.Errorf("Notification failed to node %s because of: %v", node.ID, err)
+			go record.FailedRequest(node, c.repositories, c.actions)
+			if !idempotent && !rpc.IsNotSent(err) {
+				log.Error("Notification not retried on other node because it is not idempotent")
+				return
+			}
+			continue
+		}
+		go record.SuccessfulRequest(node, c.repositories)
+		return
+	}
+	log.Error("Notification failed because all nodes returned invalid rpc response")
 }
@@ -10,15 +10,23 @@ import (
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/NodeFactoryIo/vedran/internal/blockheight"
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/hedge"
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
+	"github.com/NodeFactoryIo/vedran/internal/policy"
+	"github.com/NodeFactoryIo/vedran/internal/quorum"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	"github.com/NodeFactoryIo/vedran/internal/rpc"
 	actionMocks "github.com/NodeFactoryIo/vedran/mocks/actions"
 	tunnelMocks "github.com/NodeFactoryIo/vedran/mocks/http-tunnel/server"
 	repoMocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -71,7 +79,7 @@ func TestApiController_RPCHandler(t *testing.T) {
 			name:       "Returns response if node returnes valid rpc response",
 			rpcRequest: `{"jsonrpc": "2.0", "id": 1, "method": "system"}`,
 			rpcResponse: rpc.RPCResponse{
-				ID:      1,
+				ID:      json.RawMessage("1"),
 				JSONRPC: "2.0",
 				Error:   nil,
 			},
@@ -83,7 +91,7 @@ func TestApiController_RPCHandler(t *testing.T) {
 			name:       "Returns parse error if json invalid",
 			rpcRequest: `INVALID`,
 			rpcResponse: rpc.RPCResponse{
-				ID:      0,
+				ID:      json.RawMessage("null"),
 				JSONRPC: "2.0",
 				Error:   &rpc.RPCError{Code: -32700, Message: "Parse error"},
 			},
@@ -94,7 +102,7 @@ func TestApiController_RPCHandler(t *testing.T) {
 			name:       "Returns server error if no available nodes",
 			rpcRequest: `{"jsonrpc": "2.0", "id": 1, "method": "system"}`,
 			rpcResponse: rpc.RPCResponse{
-				ID:      1,
+				ID:      json.RawMessage("1"),
 				JSONRPC: "2.0",
 				Error:   &rpc.RPCError{Code: -32603, Message: "No available nodes"},
 			},
@@ -106,7 +114,7 @@ func TestApiController_RPCHandler(t *testing.T) {
 			name:       "Returns server error if all nodes return invalid rpc response",
 			rpcRequest: `{"jsonrpc": "2.0", "id": 1, "method": "system"}`,
 			rpcResponse: rpc.RPCResponse{
-				ID:      1,
+				ID:      json.RawMessage("1"),
 				JSONRPC: "2.0",
 				Error:   &rpc.RPCError{Code: -32603, Message: "Internal Server Error"},
 			},
@@ -137,7 +145,7 @@ func TestApiController_RPCHandler(t *testing.T) {
 			var body rpc.RPCResponse
 			_ = json.Unmarshal(rr.Body.Bytes(), &body)
 
-			if test.rpcResponse != (rpc.RPCResponse{}) && !reflect.DeepEqual(body, test.rpcResponse) {
+			if test.rpcResponse.JSONRPC != "" && !reflect.DeepEqual(body, test.rpcResponse) {
 				t.Errorf("SendRequestToNode() body = %v, want %v", body, test.rpcResponse)
 				return
 			}
@@ -181,7 +189,7 @@ func TestApiController_BatchRPCHandler(t *testing.T) {
 			rpcRequest: `[{"jsonrpc": "2.0", "id": 1, "method": "system"}]`,
 			rpcResponses: []rpc.RPCResponse{
 				{
-					ID:      1,
+					ID:      json.RawMessage("1"),
 					JSONRPC: "2.0",
 					Error:   &rpc.RPCError{Code: -32603, Message: "Internal Server Error"}},
 			},
@@ -236,7 +244,7 @@ func TestApiController_RPCHandler_InvalidBody(t *testing.T) {
 		{
 			name: "Returns parse error if reading request body fails",
 			rpcResponse: rpc.RPCResponse{
-				ID:      0,
+				ID:      json.RawMessage("null"),
 				JSONRPC: "2.0",
 				Error:   &rpc.RPCError{Code: -32700, Message: "Parse error"}}},
 	}
@@ -267,3 +275,615 @@ func TestApiController_RPCHandler_InvalidBody(t *testing.T) {
 		teardown()
 	}
 }
+
+func TestApiController_RPCHandler_MethodPolicy(t *testing.T) {
+	setup()
+	defer teardown()
+
+	_ = policy.InitMethodPolicy(nil, []string{"author_rotateKeys"})
+	defer func() { _ = policy.InitMethodPolicy(nil, nil) }()
+
+	poolerMock := &tunnelMocks.Pooler{}
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+	poolerMock.On("GetHTTPPort", mock.Anything).Return(port, nil)
+	configuration.Config.PortPool = poolerMock
+
+	nodes := []models.Node{{ID: "test-id"}}
+	nodeRepoMock := repoMocks.NodeRepository{}
+	nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+	nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+	recordRepoMock := repoMocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+	actionsMockObject := new(actionMocks.Actions)
+
+	forwarded := 0
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		forwarded++
+		_, _ = io.WriteString(w, `[{"id": 1, "jsonrpc": "2.0", "result": "0x1"}]`)
+	})
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:   &nodeRepoMock,
+		RecordRepo: &recordRepoMock,
+	}, actionsMockObject)
+	handler := http.HandlerFunc(apiController.RPCHandler)
+
+	// single blocked request is not forwarded
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(
+		`{"jsonrpc": "2.0", "id": 2, "method": "author_rotateKeys"}`)))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var body rpc.RPCResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	assert.Equal(t, rpc.RPCResponse{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage("2"),
+		Error:   &rpc.RPCError{Code: rpc.MethodNotFound, Message: "Method not found"},
+	}, body)
+	assert.Equal(t, 0, forwarded)
+
+	// blocked entries are removed from batch and errors appended to response
+	req, _ = http.NewRequest("POST", "/", bytes.NewReader([]byte(
+		`[{"jsonrpc": "2.0", "id": 1, "method": "chain_getBlockHash"}, {"jsonrpc": "2.0", "id": 2, "method": "author_rotateKeys"}]`)))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var bodies []rpc.RPCResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &bodies)
+	assert.Len(t, bodies, 2)
+	assert.Equal(t, json.RawMessage("1"), bodies[0].ID)
+	assert.Nil(t, bodies[0].Error)
+	assert.Equal(t, json.RawMessage("2"), bodies[1].ID)
+	assert.Equal(t, rpc.MethodNotFound, bodies[1].Error.Code)
+	assert.Equal(t, 1, forwarded)
+	actionsMockObject.AssertNotCalled(t, "PenalizeNode", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApiController_SplitBatchRPCHandler(t *testing.T) {
+	configuration.Config.BatchChunkSize = 2
+	defer func() { configuration.Config.BatchChunkSize = 0 }()
+
+	// healthy node answers every entry
+	healthyNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []rpc.RPCRequest
+		_ = json.NewDecoder(r.Body).Decode(&reqs)
+		responses := make([]rpc.RPCResponse, len(reqs))
+		for i, req := range reqs {
+			result := json.RawMessage(`"healthy"`)
+			responses[len(reqs)-1-i] = rpc.RPCResponse{JSONRPC: "2.0", ID: req.ID, Result: &result}
+		}
+		_ = json.NewEncoder(w).Encode(responses)
+	}))
+	defer healthyNode.Close()
+	// erroring node answers entries with even ids with rpc errors, e.g. for invalid storage key
+	erroringNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []rpc.RPCRequest
+		_ = json.NewDecoder(r.Body).Decode(&reqs)
+		responses := make([]rpc.RPCResponse, len(reqs))
+		for i, req := range reqs {
+			if id, _ := strconv.Atoi(string(req.ID)); id%2 == 0 {
+				responses[i] = rpc.RPCResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpc.RPCError{Code: rpc.InternalServerError, Message: "invalid key"}}
+			} else {
+				result := json.RawMessage(`"erroring"`)
+				responses[i] = rpc.RPCResponse{JSONRPC: "2.0", ID: req.ID, Result: &result}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(responses)
+	}))
+	defer erroringNode.Close()
+	// broken node returns response that can't be parsed
+	brokenNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "not json")
+	}))
+	defer brokenNode.Close()
+
+	poolerMock := &tunnelMocks.Pooler{}
+	for id, server := range map[string]*httptest.Server{"healthy": healthyNode, "erroring": erroringNode, "broken": brokenNode} {
+		u, _ := url.Parse(server.URL)
+		port, _ := strconv.Atoi(u.Port())
+		poolerMock.On("GetHTTPPort", id).Return(port, nil)
+	}
+	configuration.Config.PortPool = poolerMock
+
+	nodes := []models.Node{{ID: "broken"}, {ID: "erroring"}, {ID: "healthy"}}
+	nodeRepoMock := repoMocks.NodeRepository{}
+	nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+	nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+	recordRepoMock := repoMocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+	actionsMockObject := new(actionMocks.Actions)
+	actionsMockObject.On("PenalizeNode", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:   &nodeRepoMock,
+		RecordRepo: &recordRepoMock,
+	}, actionsMockObject)
+	handler := http.HandlerFunc(apiController.RPCHandler)
+
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(`[
+		{"jsonrpc": "2.0", "id": 1, "method": "chain_getBlockHash"},
+		{"jsonrpc": "2.0", "id": 2, "method": "chain_getBlockHash"},
+		{"jsonrpc": "2.0", "id": 3, "method": "chain_getBlockHash"},
+		{"jsonrpc": "2.0", "id": 4, "method": "chain_getBlockHash"},
+		{"jsonrpc": "2.0", "id": 5, "method": "chain_getBlockHash"}
+	]`)))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var bodies []rpc.RPCResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &bodies)
+	assert.Len(t, bodies, 5)
+	for i, body := range bodies {
+		assert.Equal(t, json.RawMessage(strconv.Itoa(i+1)), body.ID)
+	}
+	// chunk of broken node is retried on erroring node, which is still used after rpc errors
+	assert.Equal(t, `"erroring"`, string(*bodies[0].Result))
+	assert.Equal(t, `"erroring"`, string(*bodies[2].Result))
+	assert.Equal(t, `"healthy"`, string(*bodies[4].Result))
+	// rpc errors are returned to client unchanged instead of being retried
+	assert.Equal(t, "invalid key", bodies[1].Error.Message)
+	assert.Equal(t, "invalid key", bodies[3].Error.Message)
+
+	// batch with duplicate ids is rejected as responses couldn't be paired with requests
+	req, _ = http.NewRequest("POST", "/", bytes.NewReader([]byte(`[
+		{"jsonrpc": "2.0", "id": 1, "method": "chain_getBlockHash"},
+		{"jsonrpc": "2.0", "id": 2, "method": "chain_getBlockHash"},
+		{"jsonrpc": "2.0", "id": 1, "method": "chain_getBlockHash"}
+	]`)))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	bodies = nil
+	_ = json.Unmarshal(rr.Body.Bytes(), &bodies)
+	assert.Len(t, bodies, 3)
+	for _, body := range bodies {
+		assert.Equal(t, rpc.InvalidRequest, body.Error.Code)
+	}
+}
+
+func TestApiController_HedgedRPCHandler(t *testing.T) {
+	hedge.InitHedging(0.5)
+	for i := 0; i < hedge.MinSamples; i++ {
+		hedge.RecordLatency(10 * time.Millisecond)
+	}
+	defer hedge.InitHedging(0)
+
+	slowNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		_, _ = io.WriteString(w, `{"id": 1, "jsonrpc": "2.0", "result": "slow"}`)
+	}))
+	defer slowNode.Close()
+	fastNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"id": 1, "jsonrpc": "2.0", "result": "fast"}`)
+	}))
+	defer fastNode.Close()
+
+	slowURL, _ := url.Parse(slowNode.URL)
+	slowPort, _ := strconv.Atoi(slowURL.Port())
+	fastURL, _ := url.Parse(fastNode.URL)
+	fastPort, _ := strconv.Atoi(fastURL.Port())
+	poolerMock := &tunnelMocks.Pooler{}
+	poolerMock.On("GetHTTPPort", "slow").Return(slowPort, nil)
+	poolerMock.On("GetHTTPPort", "fast").Return(fastPort, nil)
+	configuration.Config.PortPool = poolerMock
+
+	nodes := []models.Node{{ID: "slow"}, {ID: "fast"}}
+	nodeRepoMock := repoMocks.NodeRepository{}
+	nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+	nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+	recordRepoMock := repoMocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+	actionsMockObject := new(actionMocks.Actions)
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:   &nodeRepoMock,
+		RecordRepo: &recordRepoMock,
+	}, actionsMockObject)
+	handler := http.HandlerFunc(apiController.RPCHandler)
+
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(
+		`{"jsonrpc": "2.0", "id": 1, "method": "chain_getHeader"}`)))
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rr, req)
+
+	var body rpc.RPCResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	assert.Equal(t, `"fast"`, string(*body.Result))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	time.Sleep(100 * time.Millisecond)
+	actionsMockObject.AssertNotCalled(t, "PenalizeNode", mock.Anything, mock.Anything, mock.Anything)
+	nodeRepoMock.AssertNumberOfCalls(t, "UpdateNodeUsed", 1)
+	nodeRepoMock.AssertCalled(t, "UpdateNodeUsed", models.Node{ID: "fast"})
+}
+
+func TestApiController_QuorumRPCHandler(t *testing.T) {
+	_ = quorum.InitQuorum(3, []string{"state_getStorage"}, 0)
+	defer func() { _ = quorum.InitQuorum(quorum.DefaultQuorumSize, nil, 0) }()
+
+	poolerMock := &tunnelMocks.Pooler{}
+	var nodes []models.Node
+	for id, result := range map[string]string{"honest-1": `"0x01"`, "honest-2": `"0x01"`, "liar": `"0x02"`} {
+		response := `{"id": 1, "jsonrpc": "2.0", "result": ` + result + `}`
+		node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, response)
+		}))
+		defer node.Close()
+		nodeURL, _ := url.Parse(node.URL)
+		port, _ := strconv.Atoi(nodeURL.Port())
+		poolerMock.On("GetHTTPPort", id).Return(port, nil)
+		nodes = append(nodes, models.Node{ID: id})
+	}
+	configuration.Config.PortPool = poolerMock
+
+	nodeRepoMock := repoMocks.NodeRepository{}
+	nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+	nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+	recordRepoMock := repoMocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+	actionsMockObject := new(actionMocks.Actions)
+	actionsMockObject.On("PenalizeNode", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:   &nodeRepoMock,
+		RecordRepo: &recordRepoMock,
+	}, actionsMockObject)
+	handler := http.HandlerFunc(apiController.RPCHandler)
+
+	// storage is cross checked only at explicit block hash that nodes have
+	blockHash := "0x" + strings.Repeat("ab", 32)
+	blockheight.RecordBlockHash(blockHash, 10)
+	for _, node := range nodes {
+		blockheight.UpdateNode(node.ID, 100, 95)
+	}
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(
+		`{"jsonrpc": "2.0", "id": 1, "method": "state_getStorage", "params": ["0x26aa", "`+blockHash+`"]}`)))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var body rpc.RPCResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	assert.Equal(t, `"0x01"`, string(*body.Result))
+
+	time.Sleep(100 * time.Millisecond)
+	actionsMockObject.AssertNumberOfCalls(t, "PenalizeNode", 1)
+	actionsMockObject.AssertCalled(t, "PenalizeNode", models.Node{ID: "liar"}, mock.Anything, penalty.WrongAnswer)
+	nodeRepoMock.AssertNumberOfCalls(t, "UpdateNodeUsed", 2)
+}
+
+func TestApiController_RPCHandler_RequestIDs(t *testing.T) {
+	// node echoes request id, except for node that returns wrong id
+	newNode := func(responseID string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req rpc.RPCRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.IsNotification() {
+				return
+			}
+			id := req.ID
+			if responseID != "" {
+				id = json.RawMessage(responseID)
+			}
+			result := json.RawMessage(`"ok"`)
+			_ = json.NewEncoder(w).Encode(rpc.RPCResponse{JSONRPC: "2.0", ID: id, Result: &result})
+		}))
+	}
+	wrongNode := newNode(`"wrong"`)
+	defer wrongNode.Close()
+	validNode := newNode("")
+	defer validNode.Close()
+
+	wrongURL, _ := url.Parse(wrongNode.URL)
+	wrongPort, _ := strconv.Atoi(wrongURL.Port())
+	validURL, _ := url.Parse(validNode.URL)
+	validPort, _ := strconv.Atoi(validURL.Port())
+	poolerMock := &tunnelMocks.Pooler{}
+	poolerMock.On("GetHTTPPort", "wrong").Return(wrongPort, nil)
+	poolerMock.On("GetHTTPPort", "valid").Return(validPort, nil)
+	configuration.Config.PortPool = poolerMock
+
+	nodes := []models.Node{{ID: "wrong"}, {ID: "valid"}}
+	nodeRepoMock := repoMocks.NodeRepository{}
+	nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+	nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+	recordRepoMock := repoMocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+	actionsMockObject := new(actionMocks.Actions)
+	actionsMockObject.On("PenalizeNode", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:   &nodeRepoMock,
+		RecordRepo: &recordRepoMock,
+	}, actionsMockObject)
+	handler := http.HandlerFunc(apiController.RPCHandler)
+
+	tests := []struct {
+		name       string
+		rpcRequest string
+		httpStatus int
+		want       string
+	}{
+		{
+			name:       "Returns response with string id from node that echoed request id",
+			rpcRequest: `{"jsonrpc": "2.0", "id": "req-1", "method": "system_health"}`,
+			httpStatus: http.StatusOK,
+			want:       `{"jsonrpc": "2.0", "id": "req-1", "result": "ok"}`,
+		},
+		{
+			name:       "Returns no content for notification",
+			rpcRequest: `{"jsonrpc": "2.0", "method": "system_health"}`,
+			httpStatus: http.StatusNoContent,
+		},
+		{
+			name:       "Returns invalid request error for request without version",
+			rpcRequest: `{"id": "req-2", "method": "system_health"}`,
+			httpStatus: http.StatusOK,
+			want:       `{"jsonrpc": "2.0", "id": "req-2", "error": {"code": -32600, "message": "Invalid Request"}}`,
+		},
+		{
+			name:       "Returns responses only for batch entries that are not notifications",
+			rpcRequest: `[{"jsonrpc": "2.0", "method": "system_health"}, {"jsonrpc": "2.0", "id": null, "method": 5}]`,
+			httpStatus: http.StatusOK,
+			want:       `[{"jsonrpc": "2.0", "id": null, "error": {"code": -32600, "message": "Invalid Request"}}]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(test.rpcRequest)))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.httpStatus, rr.Code)
+			if test.want == "" {
+				assert.Empty(t, rr.Body.String())
+			} else {
+				assert.JSONEq(t, test.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestApiController_RPCHandler_BlockHeightRouting(t *testing.T) {
+	// node answers with its own id so test can check which node served request
+	newNode := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req rpc.RPCRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			result := json.RawMessage(`"` + name + `"`)
+			_ = json.NewEncoder(w).Encode(rpc.RPCResponse{JSONRPC: "2.0", ID: req.ID, Result: &result})
+		}))
+	}
+	laggingNode := newNode("lagging")
+	defer laggingNode.Close()
+	syncedNode := newNode("synced")
+	defer syncedNode.Close()
+
+	laggingURL, _ := url.Parse(laggingNode.URL)
+	laggingPort, _ := strconv.Atoi(laggingURL.Port())
+	syncedURL, _ := url.Parse(syncedNode.URL)
+	syncedPort, _ := strconv.Atoi(syncedURL.Port())
+	poolerMock := &tunnelMocks.Pooler{}
+	poolerMock.On("GetHTTPPort", "lagging").Return(laggingPort, nil)
+	poolerMock.On("GetHTTPPort", "synced").Return(syncedPort, nil)
+	configuration.Config.PortPool = poolerMock
+
+	blockheight.UpdateNode("lagging", 90, 85)
+	blockheight.UpdateNode("synced", 100, 95)
+
+	nodes := []models.Node{{ID: "lagging"}, {ID: "synced"}}
+	nodeRepoMock := repoMocks.NodeRepository{}
+	nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+	nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+	recordRepoMock := repoMocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+	metricsRepoMock := repoMocks.MetricsRepository{}
+	metricsRepoMock.On("GetLatestBlockMetrics").Return(&models.LatestBlockMetrics{}, nil)
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:    &nodeRepoMock,
+		RecordRepo:  &recordRepoMock,
+		MetricsRepo: &metricsRepoMock,
+	}, new(actionMocks.Actions))
+	handler := http.HandlerFunc(apiController.RPCHandler)
+
+	tests := []struct {
+		name           string
+		rpcRequest     string
+		minBlockHeight string
+		want           string
+	}{
+		{
+			name:       "Request without block reference is served by first node",
+			rpcRequest: `{"jsonrpc": "2.0", "id": 1, "method": "system_health"}`,
+			want:       `{"jsonrpc": "2.0", "id": 1, "result": "lagging"}`,
+		},
+		{
+			name:       "Request for block is served by node that has block",
+			rpcRequest: `{"jsonrpc": "2.0", "id": 1, "method": "chain_getBlockHash", "params": [95]}`,
+			want:       `{"jsonrpc": "2.0", "id": 1, "result": "synced"}`,
+		},
+		{
+			name:       "Request for future block is served by any node",
+			rpcRequest: `{"jsonrpc": "2.0", "id": 1, "method": "chain_getBlockHash", "params": [200]}`,
+			want:       `{"jsonrpc": "2.0", "id": 1, "result": "lagging"}`,
+		},
+		{
+			name:           "Request with min block height header is served by node at height",
+			rpcRequest:     `{"jsonrpc": "2.0", "id": 1, "method": "system_health"}`,
+			minBlockHeight: "91",
+			want:           `{"jsonrpc": "2.0", "id": 1, "result": "synced"}`,
+		},
+		{
+			name:           "Request with min block height header fails if no node is at height",
+			rpcRequest:     `{"jsonrpc": "2.0", "id": 1, "method": "system_health"}`,
+			minBlockHeight: "101",
+			want:           `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32603, "message": "No nodes at requested block height"}}`,
+		},
+		{
+			name:           "Request with invalid min block height header",
+			rpcRequest:     `{"jsonrpc": "2.0", "id": 1, "method": "system_health"}`,
+			minBlockHeight: "latest",
+			want:           `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32600, "message": "Invalid min block height"}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(test.rpcRequest)))
+			if test.minBlockHeight != "" {
+				req.Header.Set(MinBlockHeightHeader, test.minBlockHeight)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.JSONEq(t, test.want, rr.Body.String())
+		})
+	}
+}
+
+func TestApiController_RPCHandler_NonIdempotent(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		firstPortError error
+		wantResult     string
+		wantError      string
+		wantRetried    bool
+	}{
+		{
+			name:        "non idempotent request is not retried",
+			method:      "author_submitExtrinsic",
+			wantError:   outcomeUnknownMessage,
+			wantRetried: false,
+		},
+		{
+			name:           "non idempotent request is retried if it was not sent",
+			method:         "author_submitExtrinsic",
+			firstPortError: errors.New("no port"),
+			wantResult:     `"0x01"`,
+			wantRetried:    true,
+		},
+		{
+			name:        "idempotent request is retried",
+			method:      "chain_getHeader",
+			wantResult:  `"0x01"`,
+			wantRetried: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failingNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Error", http.StatusBadGateway)
+			}))
+			defer failingNode.Close()
+			retried := false
+			workingNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				retried = true
+				_, _ = io.WriteString(w, `{"id": 1, "jsonrpc": "2.0", "result": "0x01"}`)
+			}))
+			defer workingNode.Close()
+
+			failingURL, _ := url.Parse(failingNode.URL)
+			failingPort, _ := strconv.Atoi(failingURL.Port())
+			workingURL, _ := url.Parse(workingNode.URL)
+			workingPort, _ := strconv.Atoi(workingURL.Port())
+			poolerMock := &tunnelMocks.Pooler{}
+			poolerMock.On("GetHTTPPort", "failing").Return(failingPort, test.firstPortError)
+			poolerMock.On("GetHTTPPort", "working").Return(workingPort, nil)
+			configuration.Config.PortPool = poolerMock
+
+			nodes := []models.Node{{ID: "failing"}, {ID: "working"}}
+			nodeRepoMock := repoMocks.NodeRepository{}
+			nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+			nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+			recordRepoMock := repoMocks.RecordRepository{}
+			recordRepoMock.On("Save", mock.Anything).Return(nil)
+			actionsMockObject := new(actionMocks.Actions)
+			actionsMockObject.On("PenalizeNode", mock.Anything, mock.Anything, mock.Anything).Return()
+
+			apiController := NewApiController(false, repositories.Repos{
+				NodeRepo:   &nodeRepoMock,
+				RecordRepo: &recordRepoMock,
+			}, actionsMockObject)
+			handler := http.HandlerFunc(apiController.RPCHandler)
+
+			req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(
+				`{"jsonrpc": "2.0", "id": 1, "method": "`+test.method+`", "params": ["0x2d02"]}`)))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var body rpc.RPCResponse
+			_ = json.Unmarshal(rr.Body.Bytes(), &body)
+			if test.wantError != "" {
+				assert.Equal(t, test.wantError, body.Error.Message)
+			} else {
+				assert.Equal(t, test.wantResult, string(*body.Result))
+			}
+			assert.Equal(t, test.wantRetried, retried)
+
+			time.Sleep(100 * time.Millisecond)
+			actionsMockObject.AssertCalled(t, "PenalizeNode", models.Node{ID: "failing"}, mock.Anything, penalty.FailedRequest)
+		})
+	}
+}
+
+func TestApiController_BroadcastRPCHandler(t *testing.T) {
+	configuration.Config.BroadcastExtrinsics = 3
+	defer func() { configuration.Config.BroadcastExtrinsics = 0 }()
+
+	poolerMock := &tunnelMocks.Pooler{}
+	var nodes []models.Node
+	for id, handle := range map[string]handleFnMock{
+		"accepted": func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+			_, _ = io.WriteString(w, `{"id": 1, "jsonrpc": "2.0", "result": "0xabcd"}`)
+		},
+		"already-imported": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{"id": 1, "jsonrpc": "2.0", "error": {"code": 1013, "message": "Transaction Already Imported"}}`)
+		},
+		"failing": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Error", http.StatusBadGateway)
+		},
+	} {
+		node := httptest.NewServer(http.HandlerFunc(handle))
+		defer node.Close()
+		nodeURL, _ := url.Parse(node.URL)
+		port, _ := strconv.Atoi(nodeURL.Port())
+		poolerMock.On("GetHTTPPort", id).Return(port, nil)
+		nodes = append(nodes, models.Node{ID: id})
+	}
+	configuration.Config.PortPool = poolerMock
+
+	nodeRepoMock := repoMocks.NodeRepository{}
+	nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+	nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+	recordRepoMock := repoMocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+	actionsMockObject := new(actionMocks.Actions)
+	actionsMockObject.On("PenalizeNode", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:   &nodeRepoMock,
+		RecordRepo: &recordRepoMock,
+	}, actionsMockObject)
+	handler := http.HandlerFunc(apiController.RPCHandler)
+
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(
+		`{"jsonrpc": "2.0", "id": 1, "method": "author_submitExtrinsic", "params": ["0x2d02"]}`)))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var body rpc.RPCResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	assert.Equal(t, `"0xabcd"`, string(*body.Result))
+
+	time.Sleep(100 * time.Millisecond)
+	actionsMockObject.AssertNumberOfCalls(t, "PenalizeNode", 1)
+	actionsMockObject.AssertCalled(t, "PenalizeNode", models.Node{ID: "failing"}, mock.Anything, penalty.FailedRequest)
+	nodeRepoMock.AssertNumberOfCalls(t, "UpdateNodeUsed", 2)
+}
@@ -117,6 +117,8 @@ func TestApiController_StatisticsHandlerAllStats(t *testing.T) {
 				test.pingRepoCalculateDowntimeReturnDuration,
 				test.pingRepoCalculateDowntimeError,
 			)
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			maintenanceRepoMock.On("FindMaintenancesInsideInterval", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
 			downtimeRepoMock := mocks.DowntimeRepository{}
 			downtimeRepoMock.On("FindDowntimesInsideInterval",
 				test.nodeId, mock.Anything, mock.Anything,
@@ -131,12 +133,13 @@ func TestApiController_StatisticsHandlerAllStats(t *testing.T) {
 			)
 			payoutRepoMock.On("Save", mock.Anything).Return(nil)
 			apiController := NewApiController(false, repositories.Repos{
-				NodeRepo:     &nodeRepoMock,
-				PingRepo:     &pingRepoMock,
-				MetricsRepo:  &metricsRepoMock,
-				RecordRepo:   &recordRepoMock,
-				DowntimeRepo: &downtimeRepoMock,
-				PayoutRepo:   &payoutRepoMock,
+				NodeRepo:        &nodeRepoMock,
+				PingRepo:        &pingRepoMock,
+				MetricsRepo:     &metricsRepoMock,
+				RecordRepo:      &recordRepoMock,
+				DowntimeRepo:    &downtimeRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
+				PayoutRepo:      &payoutRepoMock,
 			}, nil)
 			handler := http.HandlerFunc(apiController.StatisticsHandlerAllStats)
 			req, _ := http.NewRequest("GET", "/api/v1/stats", bytes.NewReader(nil))
@@ -336,6 +339,8 @@ func TestApiController_StatisticsHandlerAllStatsForLoadbalancer(t *testing.T) {
 				test.pingRepoCalculateDowntimeReturnDuration,
 				test.pingRepoCalculateDowntimeError,
 			)
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			maintenanceRepoMock.On("FindMaintenancesInsideInterval", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
 			downtimeRepoMock := mocks.DowntimeRepository{}
 			downtimeRepoMock.On("FindDowntimesInsideInterval",
 				test.nodeId, mock.Anything, mock.Anything,
@@ -352,13 +357,14 @@ func TestApiController_StatisticsHandlerAllStatsForLoadbalancer(t *testing.T) {
 			feeRepoMock := mocks.FeeRepository{}
 			feeRepoMock.On("RecordNewFee", "0xtest-address", mock.Anything).Return(nil)
 			apiController := NewApiController(false, repositories.Repos{
-				NodeRepo:     &nodeRepoMock,
-				PingRepo:     &pingRepoMock,
-				MetricsRepo:  &metricsRepoMock,
-				RecordRepo:   &recordRepoMock,
-				DowntimeRepo: &downtimeRepoMock,
-				PayoutRepo:   &payoutRepoMock,
-				FeeRepo:      &feeRepoMock,
+				NodeRepo:        &nodeRepoMock,
+				PingRepo:        &pingRepoMock,
+				MetricsRepo:     &metricsRepoMock,
+				RecordRepo:      &recordRepoMock,
+				DowntimeRepo:    &downtimeRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
+				PayoutRepo:      &payoutRepoMock,
+				FeeRepo:         &feeRepoMock,
 			}, nil)
 
 			handler := middleware.VerifySignatureMiddleware(
@@ -474,6 +480,8 @@ func TestApiController_StatisticsHandlerStatsForNode(t *testing.T) {
 				test.pingRepoCalculateDowntimeReturnDuration,
 				test.pingRepoCalculateDowntimeError,
 			)
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			maintenanceRepoMock.On("FindMaintenancesInsideInterval", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
 			downtimeRepoMock := mocks.DowntimeRepository{}
 			downtimeRepoMock.On("FindDowntimesInsideInterval",
 				test.nodeId, mock.Anything, mock.Anything,
@@ -487,11 +495,12 @@ func TestApiController_StatisticsHandlerStatsForNode(t *testing.T) {
 				test.payoutRepoFindLatestPayoutError,
 			)
 			apiController := NewApiController(false, repositories.Repos{
-				PingRepo:     &pingRepoMock,
-				MetricsRepo:  &metricsRepoMock,
-				RecordRepo:   &recordRepoMock,
-				DowntimeRepo: &downtimeRepoMock,
-				PayoutRepo:   &payoutRepoMock,
+				PingRepo:        &pingRepoMock,
+				MetricsRepo:     &metricsRepoMock,
+				RecordRepo:      &recordRepoMock,
+				DowntimeRepo:    &downtimeRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
+				PayoutRepo:      &payoutRepoMock,
 			}, nil)
 			type ContextKey string
 			req, _ := http.NewRequest("GET", "/api/v1/stats/node/1", bytes.NewReader(nil))
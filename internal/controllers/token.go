@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	log "github.com/sirupsen/logrus"
+)
+
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+// handler for `POST /api/v1/nodes/token` - returns new token of node, previous token is revoked
+func (c ApiController) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	request := r.Context().Value(auth.RequestContextKey).(*auth.RequestContext)
+
+	node, err := c.repositories.NodeRepo.FindByID(request.NodeId)
+	if err != nil {
+		log.Errorf("Unable to find node %s, error: %v", request.NodeId, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	err = c.issueToken(node)
+	if err != nil {
+		log.Errorf("Unable to issue token for node %s, error: %v", node.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	err = c.repositories.NodeRepo.Save(node)
+	if err != nil {
+		log.Errorf("Unable to save node %s, error: %v", node.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	log.Debugf("Token of node %s refreshed", node.ID)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(TokenResponse{Token: node.Token})
+}
+
+// issueToken sets new token on node and revokes previous token of node, node should be saved afterwards
+func (c ApiController) issueToken(node *models.Node) error {
+	token, err := auth.CreateNewToken(node.ID)
+	if err != nil {
+		return err
+	}
+	err = c.revokeToken(node.Token)
+	if err != nil {
+		return err
+	}
+	node.Token = token
+	return nil
+}
+
+// revokeToken saves token to revoked tokens, tokens that are not signed with auth secret
+// are already rejected and are ignored
+func (c ApiController) revokeToken(token string) error {
+	if token == "" {
+		return nil
+	}
+	claims, err := auth.ParseTokenClaims(token)
+	if err != nil || claims.Id == "" || auth.IsTokenRevoked(claims.Id) {
+		return nil
+	}
+
+	err = c.repositories.RevokedTokenRepo.Save(&models.RevokedToken{
+		ID:        claims.Id,
+		NodeId:    claims.NodeId,
+		ExpiresAt: claims.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	auth.RevokeToken(claims.Id, claims.ExpiresAt)
+	return nil
+}
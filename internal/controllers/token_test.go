@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApiController_RefreshTokenHandler(t *testing.T) {
+	_ = auth.SetAuthSecret("test-auth-secret")
+
+	tests := []struct {
+		name          string
+		findNodeError error
+		saveNodeError error
+		httpStatus    int
+		// RevokedTokenRepo.Save
+		revokeTokenNumOfCalls int
+	}{
+		{
+			name:          "unable to find node",
+			findNodeError: errors.New("db error"),
+			httpStatus:    http.StatusInternalServerError,
+		},
+		{
+			name:                  "unable to save node",
+			saveNodeError:         errors.New("db error"),
+			httpStatus:            http.StatusInternalServerError,
+			revokeTokenNumOfCalls: 1,
+		},
+		{
+			name:                  "refresh token of node",
+			httpStatus:            http.StatusOK,
+			revokeTokenNumOfCalls: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previousToken, _ := auth.CreateNewToken("1")
+			previousClaims, _ := auth.ParseTokenClaims(previousToken)
+
+			nodeRepoMock := mocks.NodeRepository{}
+			nodeRepoMock.On("FindByID", "1").Return(&models.Node{ID: "1", Token: previousToken}, test.findNodeError)
+			nodeRepoMock.On("Save", mock.Anything).Return(test.saveNodeError)
+			revokedTokenRepoMock := mocks.RevokedTokenRepository{}
+			revokedTokenRepoMock.On("Save", &models.RevokedToken{
+				ID:        previousClaims.Id,
+				NodeId:    "1",
+				ExpiresAt: previousClaims.ExpiresAt,
+			}).Return(nil)
+
+			apiController := NewApiController(false, repositories.Repos{
+				NodeRepo:         &nodeRepoMock,
+				RevokedTokenRepo: &revokedTokenRepoMock,
+			}, nil)
+
+			req, _ := http.NewRequest("POST", "/api/v1/nodes/token", nil)
+			req = req.WithContext(context.WithValue(req.Context(), auth.RequestContextKey, &auth.RequestContext{
+				NodeId:    "1",
+				Timestamp: time.Now(),
+				Claims:    previousClaims,
+			}))
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(apiController.RefreshTokenHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, test.httpStatus, rr.Code)
+			revokedTokenRepoMock.AssertNumberOfCalls(t, "Save", test.revokeTokenNumOfCalls)
+			if rr.Code == http.StatusOK {
+				var response TokenResponse
+				_ = json.Unmarshal(rr.Body.Bytes(), &response)
+				claims, err := auth.ValidateToken(response.Token)
+				assert.NoError(t, err)
+				assert.Equal(t, "1", claims.NodeId)
+				nodeRepoMock.AssertCalled(t, "Save", &models.Node{ID: "1", Token: response.Token})
+
+				_, err = auth.ValidateToken(previousToken)
+				assert.Error(t, err, "Previous token should be revoked")
+			}
+		})
+	}
+}
@@ -3,61 +3,139 @@ package controllers
 import (
 	"net/http"
 
+	"github.com/NodeFactoryIo/vedran/internal/apikey"
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/iplimit"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
 	"github.com/NodeFactoryIo/vedran/internal/ws"
 	"github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 func (c ApiController) WSHandler(w http.ResponseWriter, r *http.Request) {
-	nodes := c.repositories.NodeRepo.GetActiveNodes(configuration.Config.Selection)
-	if len(*nodes) == 0 {
+	client := ws.Client{
+		IP:     iplimit.ClientIP(r),
+		APIKey: apikey.FromContext(r.Context()),
+	}
+	if !iplimit.AcquireWSConnection(client.IP) {
+		log.Debugf("Connection from %s rejected because of connection limit", client.IP)
+		http.Error(w, "Too many connections", http.StatusTooManyRequests)
+		return
+	}
+
+	activeNodes := c.repositories.NodeRepo.GetActiveNodes(configuration.Config.Selection)
+	if len(*activeNodes) == 0 {
 		log.Error("Request failed because vedran has no available nodes")
+		iplimit.ReleaseWSConnection(client.IP)
 		http.Error(w, "No available nodes", 503)
 		return
 	}
 
+	nodes, err := nodesAtHeight(r, *activeNodes, nil)
+	if err != nil {
+		log.Debugf("Request failed because of: %v", err)
+		iplimit.ReleaseWSConnection(client.IP)
+		if err == errInvalidMinBlockHeight {
+			http.Error(w, "Invalid min block height", http.StatusBadRequest)
+		} else {
+			http.Error(w, "No nodes at requested block height", 503)
+		}
+		return
+	}
+
+	upgrader := ws.NewUpgrader()
 	connToLoadbalancer, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		// upgrader already responded to client with error
 		log.Errorf("Failed upgrading connection because of %v", err)
-		http.Error(w, "Failed upgrading connection", 500)
+		iplimit.ReleaseWSConnection(client.IP)
+		return
+	}
+	ws.PrepareClientConn(connToLoadbalancer)
+
+	if ws.IsMultiplexed() || ws.IsFailoverEnabled() {
+		c.serveMultiplexed(r, connToLoadbalancer, nodes, client)
 		return
 	}
 
 	connErr := make(chan *ws.ConnectionError)
 	messages := make(chan ws.Message)
 	wsConnection := make(chan *websocket.Conn)
-	for _, node := range *nodes {
-		go ws.EstablishNodeConn(node.ID, wsConnection, messages, connErr)
+	for _, node := range nodes {
+		pipe := ws.NewPipe()
+		go ws.EstablishNodeConn(node.ID, wsConnection, messages, connErr, pipe)
 
 		connectionError := <-connErr
 		connToNode := <-wsConnection
 		if connectionError != nil {
 			log.Errorf("Establishing connection failed because of %v", connectionError)
 			if connectionError.IsNodeError() {
-				c.actions.PenalizeNode(node, c.repositories, "establishing connection failed")
+				c.actions.PenalizeNode(node, c.repositories, penalty.WSConnection)
 			}
 			continue
 		}
 
+		// node connection was established and channels used for it are not needed anymore,
+		// messages channel is closed once pipe is stopped
+		close(connErr)
+		close(wsConnection)
 		go c.repositories.NodeRepo.UpdateNodeUsed(node)
 
-		go ws.SendRequestToNode(connToLoadbalancer, connToNode, node, c.repositories, c.actions)
-		go ws.SendResponseToClient(connToLoadbalancer, connToNode, messages, node, c.repositories)
+		tracker := ws.NewRequestTracker()
+		go func() {
+			ws.SendRequestToNode(connToLoadbalancer, connToNode, messages, node, c.repositories, c.actions, client, tracker, pipe)
+			iplimit.ReleaseWSConnection(client.IP)
+		}()
+		go ws.SendResponseToClient(connToLoadbalancer, connToNode, messages, node, c.repositories, tracker, pipe)
 		return
 	}
 
 	log.Error("Failed establishing connection with any node")
+	iplimit.ReleaseWSConnection(client.IP)
 	_ = connToLoadbalancer.Close()
 	close(connErr)
 	close(messages)
 	close(wsConnection)
 }
+
+// serveMultiplexed serves client through upstream connection to node, shared with other clients
+// if multiplexing is enabled, if connection to node is lost client is moved to other active node
+func (c ApiController) serveMultiplexed(
+	r *http.Request,
+	connToLoadbalancer *websocket.Conn,
+	nodes []models.Node,
+	client ws.Client,
+) {
+	failoverNodes := func() []models.Node {
+		activeNodes := c.repositories.NodeRepo.GetActiveNodes(configuration.Config.Selection)
+		nodes, err := nodesAtHeight(r, *activeNodes, nil)
+		if err != nil {
+			return nil
+		}
+		return nodes
+	}
+	session := ws.NewSession(connToLoadbalancer, client, c.repositories, c.actions, failoverNodes)
+	for _, node := range nodes {
+		connectionError := session.Attach(node)
+		if connectionError != nil {
+			log.Errorf("Establishing connection failed because of %v", connectionError)
+			if connectionError.IsNodeError() {
+				c.actions.PenalizeNode(node, c.repositories, penalty.WSConnection)
+			}
+			continue
+		}
+
+		go c.repositories.NodeRepo.UpdateNodeUsed(node)
+
+		go func() {
+			session.Serve()
+			iplimit.ReleaseWSConnection(client.IP)
+		}()
+		return
+	}
+
+	log.Error("Failed establishing connection with any node")
+	iplimit.ReleaseWSConnection(client.IP)
+	_ = connToLoadbalancer.Close()
+}
@@ -1,11 +1,13 @@
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,6 +19,8 @@ import (
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
 	"github.com/NodeFactoryIo/vedran/internal/models"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	wsmux "github.com/NodeFactoryIo/vedran/internal/ws"
 	actionMocks "github.com/NodeFactoryIo/vedran/mocks/actions"
 	tunnelMocks "github.com/NodeFactoryIo/vedran/mocks/http-tunnel/server"
 	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
@@ -28,6 +32,7 @@ func TestApiController_WSHandler(t *testing.T) {
 		nodeRepoGetActiveNodesReturn []models.Node
 		penalizeNodeNumOfCalls       int
 		updateNodeUsedNumOfCalls     int
+		saveRecordNumOfCalls         int
 		requestType                  string
 		expectedResponses            []string
 		forceNodeWStoFail            bool
@@ -39,6 +44,7 @@ func TestApiController_WSHandler(t *testing.T) {
 			},
 			// 1 for starting connection + 1 for successful response
 			updateNodeUsedNumOfCalls: 2,
+			saveRecordNumOfCalls:     1,
 			requestType:              SimpleRequest,
 			expectedResponses:        []string{SimpleRequest},
 		},
@@ -47,15 +53,18 @@ func TestApiController_WSHandler(t *testing.T) {
 			nodeRepoGetActiveNodesReturn: []models.Node{
 				{ID: "1", ConfigHash: "", PayoutAddress: "", Token: "", Cooldown: 0, LastUsed: 1, Active: true},
 			},
-			// 1 for starting connection + 5 for successful responses
-			updateNodeUsedNumOfCalls: 6,
-			requestType:              SubscribeRequest,
+			// 1 for starting connection + 1 for subscription response, notifications are not rewarded
+			updateNodeUsedNumOfCalls: 2,
+			// 1 successful request record + 5 notification records
+			saveRecordNumOfCalls: 6,
+			requestType:          SubscribeRequest,
 			expectedResponses: []string{
-				"subscription response 1",
-				"subscription response 2",
-				"subscription response 3",
-				"subscription response 4",
-				"subscription response 5",
+				SubscribeResponse,
+				fmt.Sprintf(SubscriptionNotification, 1),
+				fmt.Sprintf(SubscriptionNotification, 2),
+				fmt.Sprintf(SubscriptionNotification, 3),
+				fmt.Sprintf(SubscriptionNotification, 4),
+				fmt.Sprintf(SubscriptionNotification, 5),
 			},
 		},
 		{
@@ -126,6 +135,7 @@ func TestApiController_WSHandler(t *testing.T) {
 			time.Sleep(1 * time.Second)
 			actionsMockObject.AssertNumberOfCalls(t, "PenalizeNode", test.penalizeNodeNumOfCalls)
 			nodeRepoMock.AssertNumberOfCalls(t, "UpdateNodeUsed", test.updateNodeUsedNumOfCalls)
+			recordRepoMock.AssertNumberOfCalls(t, "Save", test.saveRecordNumOfCalls)
 
 			// cleanup
 			configuration.Config.PortPool = nil
@@ -141,11 +151,15 @@ func StartMockNodeWS(shouldFail bool) *httptest.Server {
 }
 
 const (
-	SimpleRequest    = "request"
-	SubscribeRequest = "subscribe"
-	FailRequest      = "fail"
+	SimpleRequest            = `{"jsonrpc":"2.0","id":1,"method":"system_health"}`
+	SubscribeRequest         = `{"jsonrpc":"2.0","id":2,"method":"chain_subscribeNewHeads"}`
+	SubscribeResponse        = `{"jsonrpc":"2.0","id":2,"result":"subscription"}`
+	SubscriptionNotification = `{"jsonrpc":"2.0","method":"chain_newHead","params":{"subscription":"subscription","result":%d}}`
+	FailRequest              = "fail"
 )
 
+var nodeUpgrader = websocket.Upgrader{}
+
 type MockNodeWs struct {
 	shouldFail bool
 }
@@ -156,7 +170,7 @@ func (n *MockNodeWs) MockEchoWsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := nodeUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		http.Error(w, "Failed upgrading connection", 500)
 		return
@@ -174,8 +188,12 @@ func (n *MockNodeWs) MockEchoWsHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		case SubscribeRequest:
 			// emulate subscription behaviour
+			err = conn.WriteMessage(msgType, []byte(SubscribeResponse))
+			if err != nil {
+				return
+			}
 			for i := 0; i < 5; i++ {
-				err = conn.WriteMessage(msgType, []byte(fmt.Sprintf("subscription response %d", i+1)))
+				err = conn.WriteMessage(msgType, []byte(fmt.Sprintf(SubscriptionNotification, i+1)))
 				if err != nil {
 					return
 				}
@@ -191,3 +209,334 @@ func (n *MockNodeWs) MockEchoWsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+func TestApiController_WSHandler_Multiplexed(t *testing.T) {
+	wsmux.InitMultiplexer(1)
+	defer wsmux.InitMultiplexer(0)
+
+	nodes := []models.Node{{ID: "1", Active: true}}
+	nodeRepoMock := mocks.NodeRepository{}
+	nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+	nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+	recordRepoMock := mocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+	actionsMockObject := new(actionMocks.Actions)
+
+	apiController := NewApiController(false, repositories.Repos{
+		NodeRepo:   &nodeRepoMock,
+		RecordRepo: &recordRepoMock,
+	}, actionsMockObject)
+	router := mm.NewRouter()
+	router.HandleFunc("/ws", apiController.WSHandler)
+	s := httptest.NewServer(router)
+	defer s.Close()
+
+	node := &MockRPCNodeWs{}
+	nodeRouter := mm.NewRouter()
+	nodeRouter.HandleFunc("/", node.Handler)
+	ns := httptest.NewServer(nodeRouter)
+	defer ns.Close()
+	strPort := strings.Split(strings.Split(ns.URL, ":")[2], "/")[0]
+	nodePort, _ := strconv.Atoi(strPort)
+	poolerMock := tunnelMocks.Pooler{}
+	poolerMock.On("GetWSPort", mock.Anything).Return(nodePort, nil)
+	configuration.Config.PortPool = &poolerMock
+	defer func() { configuration.Config.PortPool = nil }()
+
+	u := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+	var clients []*websocket.Conn
+	for i := 0; i < 2; i++ {
+		client, _, err := websocket.DefaultDialer.Dial(u, nil)
+		assert.NoError(t, err)
+		defer client.Close()
+		clients = append(clients, client)
+	}
+
+	// both clients use same request id
+	var subscriptionIDs []string
+	for _, client := range clients {
+		_ = client.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"system_health"}`))
+		response := readWSMessage(t, client)
+		assert.Equal(t, "1", rpc.IDKey(response.ID))
+		assert.Equal(t, `"system_health"`, string(response.Result))
+
+		_ = client.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"chain_subscribeNewHeads"}`))
+		response = readWSMessage(t, client)
+		assert.Equal(t, "2", rpc.IDKey(response.ID))
+		var subscriptionID string
+		_ = json.Unmarshal(response.Result, &subscriptionID)
+		subscriptionIDs = append(subscriptionIDs, subscriptionID)
+
+		notification := readWSMessage(t, client)
+		assert.Equal(t, "chain_newHead", notification.Method)
+		assert.Equal(t, `"`+subscriptionID+`"`, string(notification.Params.Subscription))
+		assert.JSONEq(t, `{"number":"0x1"}`, string(notification.Params.Result))
+	}
+	assert.NotEqual(t, subscriptionIDs[0], subscriptionIDs[1])
+	assert.Equal(t, 1, node.count("connections"))
+	assert.Equal(t, 1, node.count("chain_subscribeNewHeads"))
+
+	// subscription on node is canceled only after last client unsubscribes
+	for i, client := range clients {
+		_ = client.WriteMessage(websocket.TextMessage, []byte(
+			`{"jsonrpc":"2.0","id":3,"method":"chain_unsubscribeNewHeads","params":["`+subscriptionIDs[i]+`"]}`))
+		response := readWSMessage(t, client)
+		assert.Equal(t, "3", rpc.IDKey(response.ID))
+		assert.Equal(t, "true", string(response.Result))
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, i, node.count("chain_unsubscribeNewHeads"))
+	}
+}
+
+func TestApiController_WSHandler_Failover(t *testing.T) {
+	tests := []struct {
+		name     string
+		poolSize int
+	}{
+		{name: "dedicated node connection", poolSize: 0},
+		{name: "multiplexed node connection", poolSize: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wsmux.InitMultiplexer(test.poolSize)
+			wsmux.InitFailover(true)
+			defer wsmux.InitMultiplexer(0)
+			defer wsmux.InitFailover(false)
+
+			nodes := []models.Node{{ID: "1", Active: true}, {ID: "2", Active: true}}
+			nodeRepoMock := mocks.NodeRepository{}
+			nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+			nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+			recordRepoMock := mocks.RecordRepository{}
+			recordRepoMock.On("Save", mock.Anything).Return(nil)
+			actionsMockObject := new(actionMocks.Actions)
+
+			apiController := NewApiController(false, repositories.Repos{
+				NodeRepo:   &nodeRepoMock,
+				RecordRepo: &recordRepoMock,
+			}, actionsMockObject)
+			router := mm.NewRouter()
+			router.HandleFunc("/ws", apiController.WSHandler)
+			s := httptest.NewServer(router)
+			defer s.Close()
+
+			poolerMock := tunnelMocks.Pooler{}
+			mockNodes := map[string]*MockRPCNodeWs{}
+			for _, id := range []string{"1", "2"} {
+				node := &MockRPCNodeWs{}
+				nodeRouter := mm.NewRouter()
+				nodeRouter.HandleFunc("/", node.Handler)
+				ns := httptest.NewServer(nodeRouter)
+				defer ns.Close()
+				strPort := strings.Split(strings.Split(ns.URL, ":")[2], "/")[0]
+				nodePort, _ := strconv.Atoi(strPort)
+				poolerMock.On("GetWSPort", id).Return(nodePort, nil)
+				mockNodes[id] = node
+			}
+			configuration.Config.PortPool = &poolerMock
+			defer func() { configuration.Config.PortPool = nil }()
+
+			client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http")+"/ws", nil)
+			assert.NoError(t, err)
+			defer client.Close()
+
+			_ = client.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"chain_subscribeNewHeads"}`))
+			response := readWSMessage(t, client)
+			subscriptionID := string(response.Result)
+			notification := readWSMessage(t, client)
+			assert.Equal(t, subscriptionID, string(notification.Params.Subscription))
+
+			// node 1 drops connection and subscription is restored on node 2 under same id
+			mockNodes["1"].dropConnections()
+			notification = readWSMessage(t, client)
+			assert.Equal(t, "chain_newHead", notification.Method)
+			assert.Equal(t, subscriptionID, string(notification.Params.Subscription))
+			assert.Equal(t, 1, mockNodes["2"].count("chain_subscribeNewHeads"))
+
+			_ = client.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"system_health"}`))
+			response = readWSMessage(t, client)
+			assert.Equal(t, "2", rpc.IDKey(response.ID))
+			assert.Equal(t, 1, mockNodes["2"].count("system_health"))
+			assert.Equal(t, 0, mockNodes["1"].count("system_health"))
+		})
+	}
+}
+
+type wsTestMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Params struct {
+		Subscription json.RawMessage `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+func TestApiController_WSHandler_ConnectionLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		multiplexed bool
+	}{
+		{name: "dedicated node connection", multiplexed: false},
+		{name: "multiplexed node connection", multiplexed: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.multiplexed {
+				wsmux.InitMultiplexer(1)
+				defer wsmux.InitMultiplexer(0)
+			}
+			err := wsmux.InitConnectionOptions(128, 200*time.Millisecond, 50*time.Millisecond, []string{"https://*.example.com"}, false)
+			assert.NoError(t, err)
+			defer func() { _ = wsmux.InitConnectionOptions(0, 0, 0, nil, false) }()
+
+			nodes := []models.Node{{ID: "1", Active: true}}
+			nodeRepoMock := mocks.NodeRepository{}
+			nodeRepoMock.On("GetActiveNodes", mock.Anything).Return(&nodes)
+			nodeRepoMock.On("UpdateNodeUsed", mock.Anything).Return()
+			recordRepoMock := mocks.RecordRepository{}
+			recordRepoMock.On("Save", mock.Anything).Return(nil)
+			actionsMockObject := new(actionMocks.Actions)
+			actionsMockObject.On("PenalizeNode", mock.Anything, mock.Anything, mock.Anything).Return()
+
+			apiController := NewApiController(false, repositories.Repos{
+				NodeRepo:   &nodeRepoMock,
+				RecordRepo: &recordRepoMock,
+			}, actionsMockObject)
+			router := mm.NewRouter()
+			router.HandleFunc("/ws", apiController.WSHandler)
+			s := httptest.NewServer(router)
+			defer s.Close()
+
+			node := &MockRPCNodeWs{}
+			nodeRouter := mm.NewRouter()
+			nodeRouter.HandleFunc("/", node.Handler)
+			ns := httptest.NewServer(nodeRouter)
+			defer ns.Close()
+			strPort := strings.Split(strings.Split(ns.URL, ":")[2], "/")[0]
+			nodePort, _ := strconv.Atoi(strPort)
+			poolerMock := tunnelMocks.Pooler{}
+			poolerMock.On("GetWSPort", mock.Anything).Return(nodePort, nil)
+			configuration.Config.PortPool = &poolerMock
+			defer func() { configuration.Config.PortPool = nil }()
+
+			u := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+
+			// browsers are accepted only from allowed origins
+			_, resp, err := websocket.DefaultDialer.Dial(u, http.Header{"Origin": []string{"https://other.com"}})
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+			// connection is closed once message size limit is exceeded
+			client, _, err := websocket.DefaultDialer.Dial(u, http.Header{"Origin": []string{"https://app.example.com"}})
+			assert.NoError(t, err)
+			defer client.Close()
+			_ = client.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"system_health"}`))
+			response := readWSMessage(t, client)
+			assert.Equal(t, `"system_health"`, string(response.Result))
+			_ = client.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"system_health","params":["`+strings.Repeat("a", 128)+`"]}`))
+			_ = client.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err = client.ReadMessage()
+			assert.Error(t, err)
+
+			// client that answers pings but doesn't send messages is disconnected after idle timeout
+			idleClient, _, err := websocket.DefaultDialer.Dial(u, nil)
+			assert.NoError(t, err)
+			defer idleClient.Close()
+			pings := make(chan struct{}, 10)
+			idleClient.SetPingHandler(func(string) error {
+				pings <- struct{}{}
+				return idleClient.WriteControl(websocket.PongMessage, nil, time.Now().Add(time.Second))
+			})
+			start := time.Now()
+			_ = idleClient.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err = idleClient.ReadMessage()
+			assert.Error(t, err)
+			assert.True(t, time.Since(start) < time.Second)
+			assert.NotEmpty(t, pings)
+
+			// node connections are closed together with client connections
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, 2, node.count("connections"))
+			assert.Equal(t, 2, node.count("disconnections"))
+		})
+	}
+}
+
+func readWSMessage(t *testing.T, conn *websocket.Conn) wsTestMessage {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, msg, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	var m wsTestMessage
+	_ = json.Unmarshal(msg, &m)
+	return m
+}
+
+// MockRPCNodeWs is node that answers JSON-RPC requests with method name and
+// sends single notification for new heads subscription
+type MockRPCNodeWs struct {
+	mutex  sync.Mutex
+	counts map[string]int
+	conns  []*websocket.Conn
+}
+
+func (n *MockRPCNodeWs) dropConnections() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, conn := range n.conns {
+		_ = conn.Close()
+	}
+}
+
+func (n *MockRPCNodeWs) count(key string) int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.counts[key]
+}
+
+func (n *MockRPCNodeWs) increment(key string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.counts == nil {
+		n.counts = make(map[string]int)
+	}
+	n.counts[key]++
+}
+
+func (n *MockRPCNodeWs) Handler(w http.ResponseWriter, r *http.Request) {
+	conn, err := nodeUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	defer n.increment("disconnections")
+	n.increment("connections")
+	n.mutex.Lock()
+	n.conns = append(n.conns, conn)
+	n.mutex.Unlock()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req rpc.RPCRequest
+		_ = json.Unmarshal(msg, &req)
+		n.increment(req.Method)
+
+		var result interface{} = req.Method
+		switch req.Method {
+		case "chain_subscribeNewHeads":
+			result = "node-subscription"
+		case "chain_unsubscribeNewHeads":
+			result = true
+		}
+		response, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		_ = conn.WriteMessage(websocket.TextMessage, response)
+
+		if req.Method == "chain_subscribeNewHeads" {
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(
+				`{"jsonrpc":"2.0","method":"chain_newHead","params":{"subscription":"node-subscription","result":{"number":"0x1"}}}`))
+		}
+	}
+}
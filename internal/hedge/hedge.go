@@ -0,0 +1,101 @@
+package hedge
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWindowSize is number of most recent request latencies used for calculating hedge delay
+	DefaultWindowSize = 1000
+	// MinSamples is number of recorded latencies required before requests are hedged
+	MinSamples = 20
+)
+
+// LatencyWindow holds most recent request latencies
+type LatencyWindow struct {
+	samples []time.Duration
+	next    int
+	full    bool
+	mutex   sync.RWMutex
+}
+
+func NewLatencyWindow(size int) *LatencyWindow {
+	return &LatencyWindow{
+		samples: make([]time.Duration, size),
+	}
+}
+
+// Record adds latency to window, replacing oldest latency if window is full
+func (w *LatencyWindow) Record(latency time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// Len returns number of recorded latencies
+func (w *LatencyWindow) Len() int {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.full {
+		return len(w.samples)
+	}
+	return w.next
+}
+
+// Percentile returns latency below which provided fraction (0-1) of recorded latencies fall
+func (w *LatencyWindow) Percentile(p float64) time.Duration {
+	w.mutex.RLock()
+	n := w.next
+	if w.full {
+		n = len(w.samples)
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, w.samples[:n])
+	w.mutex.RUnlock()
+
+	if n == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(p * float64(n))
+	if index >= n {
+		index = n - 1
+	}
+	return sorted[index]
+}
+
+var (
+	percentile float64
+	latencies  = NewLatencyWindow(DefaultWindowSize)
+)
+
+// InitHedging sets percentile of recent latencies after which request is hedged,
+// setting percentile to 0 disables hedging
+func InitHedging(p float64) {
+	percentile = p
+}
+
+// IsEnabled returns true if hedging is enabled
+func IsEnabled() bool {
+	return percentile > 0
+}
+
+// RecordLatency records latency of successful request
+func RecordLatency(latency time.Duration) {
+	latencies.Record(latency)
+}
+
+// Delay returns time after which request should be sent to another node, false is returned
+// if hedging is disabled or there isn't enough recorded latencies
+func Delay() (time.Duration, bool) {
+	if !IsEnabled() || latencies.Len() < MinSamples {
+		return 0, false
+	}
+	return latencies.Percentile(percentile), true
+}
@@ -0,0 +1,45 @@
+package hedge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	w := NewLatencyWindow(4)
+	assert.Equal(t, 0, w.Len())
+	assert.Equal(t, time.Duration(0), w.Percentile(0.5))
+
+	for i := 1; i <= 6; i++ {
+		w.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	// oldest latencies are replaced when window is full
+	assert.Equal(t, 4, w.Len())
+	assert.Equal(t, 3*time.Millisecond, w.Percentile(0))
+	assert.Equal(t, 5*time.Millisecond, w.Percentile(0.5))
+	assert.Equal(t, 6*time.Millisecond, w.Percentile(0.99))
+}
+
+func TestDelay(t *testing.T) {
+	defer func() {
+		InitHedging(0)
+		latencies = NewLatencyWindow(DefaultWindowSize)
+	}()
+
+	_, ok := Delay()
+	assert.False(t, ok, "hedging disabled")
+
+	InitHedging(0.9)
+	_, ok = Delay()
+	assert.False(t, ok, "not enough samples")
+
+	for i := 1; i <= MinSamples; i++ {
+		RecordLatency(time.Duration(i) * time.Millisecond)
+	}
+	delay, ok := Delay()
+	assert.True(t, ok)
+	assert.Equal(t, 19*time.Millisecond, delay)
+}
@@ -0,0 +1,136 @@
+package iplimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/pkg/ratelimit"
+)
+
+const (
+	// DefaultBurst is number of requests client can send at once before being rate limited
+	DefaultBurst = 20
+	// CleanupInterval is interval on which rate limits of idle clients are removed
+	CleanupInterval = 5 * time.Minute
+	// ChallengeRate is number of registration challenges per second issued to single client IP
+	ChallengeRate = 0.2
+	// ChallengeBurst is number of challenges client IP can request at once
+	ChallengeBurst = 5
+)
+
+var (
+	limiter           *ratelimit.Limiter
+	challengeLimiter  *ratelimit.Limiter
+	maxWSConnections  int
+	trustForwardedFor bool
+
+	wsConnections = make(map[string]int)
+	mutex         sync.Mutex
+)
+
+// InitIPLimits sets number of requests per second and burst allowed for each client IP and
+// maximum number of concurrent WS connections per client IP, 0 disables limit. If forwardedFor
+// is true client IP is read from X-Forwarded-For header set by reverse proxy
+func InitIPLimits(rate float64, burst int, wsConnectionsPerIP int, forwardedFor bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	limiter = nil
+	if rate > 0 {
+		if burst < 1 {
+			burst = int(math.Ceil(rate))
+		}
+		limiter = ratelimit.NewLimiter(rate, burst)
+	}
+	challengeLimiter = ratelimit.NewLimiter(ChallengeRate, ChallengeBurst)
+	maxWSConnections = wsConnectionsPerIP
+	trustForwardedFor = forwardedFor
+	wsConnections = make(map[string]int)
+}
+
+// ClientIP returns IP address of client that sent request. If X-Forwarded-For is trusted
+// last address in header is used as it is address that reverse proxy saw request from
+func ClientIP(r *http.Request) string {
+	if trustForwardedFor {
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			addresses := strings.Split(forwardedFor, ",")
+			return strings.TrimSpace(addresses[len(addresses)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Allow returns false if client IP exceeded rate limit
+func Allow(ip string) bool {
+	return AllowN(ip, 1)
+}
+
+// AllowN returns false if n requests from client IP would exceed rate limit, batch
+// request is counted as one request per batch entry
+func AllowN(ip string, n int) bool {
+	mutex.Lock()
+	l := limiter
+	mutex.Unlock()
+	if l == nil {
+		return true
+	}
+	return l.AllowN(ip, n)
+}
+
+// AllowChallenge returns false if client IP requested too many registration challenges,
+// challenges are limited even if rate limit of client IP is disabled
+func AllowChallenge(ip string) bool {
+	mutex.Lock()
+	l := challengeLimiter
+	mutex.Unlock()
+	if l == nil {
+		return true
+	}
+	return l.Allow(ip)
+}
+
+// AcquireWSConnection reserves WS connection for client IP, false is returned if client
+// already has maximum number of WS connections
+func AcquireWSConnection(ip string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if maxWSConnections > 0 && wsConnections[ip] >= maxWSConnections {
+		return false
+	}
+	wsConnections[ip]++
+	return true
+}
+
+// ReleaseWSConnection releases WS connection reserved for client IP
+func ReleaseWSConnection(ip string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	wsConnections[ip]--
+	if wsConnections[ip] <= 0 {
+		delete(wsConnections, ip)
+	}
+}
+
+// StartScheduledCleanup starts task that removes rate limits of idle clients
+func StartScheduledCleanup() {
+	ticker := time.NewTicker(CleanupInterval)
+	go func() {
+		for range ticker.C {
+			mutex.Lock()
+			limiters := []*ratelimit.Limiter{limiter, challengeLimiter}
+			mutex.Unlock()
+			for _, l := range limiters {
+				if l != nil {
+					l.Cleanup(CleanupInterval)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,90 @@
+package iplimit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name              string
+		remoteAddr        string
+		forwardedFor      string
+		trustForwardedFor bool
+		want              string
+	}{
+		{
+			name:       "returns remote address without port",
+			remoteAddr: "10.0.0.1:5000",
+			want:       "10.0.0.1",
+		},
+		{
+			name:         "ignores forwarded for header if not trusted",
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: "1.1.1.1",
+			want:         "10.0.0.1",
+		},
+		{
+			name:              "returns address added by reverse proxy if forwarded for is trusted",
+			remoteAddr:        "10.0.0.1:5000",
+			forwardedFor:      "2.2.2.2, 1.1.1.1",
+			trustForwardedFor: true,
+			want:              "1.1.1.1",
+		},
+		{
+			name:              "returns remote address if forwarded for is missing",
+			remoteAddr:        "[::1]:5000",
+			trustForwardedFor: true,
+			want:              "::1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			InitIPLimits(0, 0, 0, test.trustForwardedFor)
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+			assert.Equal(t, test.want, ClientIP(r))
+		})
+	}
+}
+
+func TestAllow(t *testing.T) {
+	InitIPLimits(0, 0, 0, false)
+	for i := 0; i < 100; i++ {
+		assert.True(t, Allow("1.1.1.1"))
+	}
+
+	InitIPLimits(1, 2, 0, false)
+	assert.True(t, Allow("1.1.1.1"))
+	assert.True(t, Allow("1.1.1.1"))
+	assert.False(t, Allow("1.1.1.1"))
+	assert.True(t, Allow("2.2.2.2"))
+}
+
+func TestAcquireWSConnection(t *testing.T) {
+	InitIPLimits(0, 0, 2, false)
+
+	assert.True(t, AcquireWSConnection("1.1.1.1"))
+	assert.True(t, AcquireWSConnection("1.1.1.1"))
+	assert.False(t, AcquireWSConnection("1.1.1.1"))
+	assert.True(t, AcquireWSConnection("2.2.2.2"))
+
+	ReleaseWSConnection("1.1.1.1")
+	assert.True(t, AcquireWSConnection("1.1.1.1"))
+}
+
+func TestAllowChallenge(t *testing.T) {
+	// challenges are limited even if rate limit is disabled
+	InitIPLimits(0, 0, 0, false)
+	for i := 0; i < ChallengeBurst; i++ {
+		assert.True(t, AllowChallenge("1.1.1.1"))
+	}
+	assert.False(t, AllowChallenge("1.1.1.1"))
+	assert.True(t, AllowChallenge("2.2.2.2"))
+	assert.True(t, Allow("1.1.1.1"))
+}
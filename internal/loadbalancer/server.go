@@ -8,16 +8,29 @@ import (
 	"time"
 
 	"github.com/NodeFactoryIo/vedran/internal/actions"
+	"github.com/NodeFactoryIo/vedran/internal/apikey"
 	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/blockheight"
+	"github.com/NodeFactoryIo/vedran/internal/breaker"
+	"github.com/NodeFactoryIo/vedran/internal/cache"
+	"github.com/NodeFactoryIo/vedran/internal/capacity"
+	"github.com/NodeFactoryIo/vedran/internal/chain"
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
 	"github.com/NodeFactoryIo/vedran/internal/controllers"
+	"github.com/NodeFactoryIo/vedran/internal/hedge"
+	"github.com/NodeFactoryIo/vedran/internal/iplimit"
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
+	"github.com/NodeFactoryIo/vedran/internal/policy"
+	"github.com/NodeFactoryIo/vedran/internal/probe"
 	"github.com/NodeFactoryIo/vedran/internal/prometheus"
+	"github.com/NodeFactoryIo/vedran/internal/quorum"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	"github.com/NodeFactoryIo/vedran/internal/router"
 	"github.com/NodeFactoryIo/vedran/internal/schedule/checkactive"
 	schedulepayout "github.com/NodeFactoryIo/vedran/internal/schedule/payout"
 	"github.com/NodeFactoryIo/vedran/internal/schedule/penalize"
+	"github.com/NodeFactoryIo/vedran/internal/ws"
 	"github.com/asdine/storm/v3"
 	"github.com/gorilla/handlers"
 	log "github.com/sirupsen/logrus"
@@ -35,6 +48,45 @@ func StartLoadBalancerServer(
 		// terminate app: no auth secret provided
 		log.Fatalf("Unable to start vedran load balancer: %v", err)
 	}
+	auth.InitTokenOptions(props.AuthTokenTTL, props.PreviousAuthSecret, props.AuthGracePeriod)
+
+	// set allowed and denied rpc methods
+	err = policy.InitMethodPolicy(props.AllowedMethods, props.DeniedMethods)
+	if err != nil {
+		// terminate app: invalid method policy
+		log.Fatalf("Unable to start vedran load balancer: %v", err)
+	}
+
+	// init cache for immutable rpc results
+	cache.InitResponseCache(props.CacheSize * 1024 * 1024)
+
+	// enable hedging of slow read only requests
+	hedge.InitHedging(props.HedgePercentile)
+
+	// set up cross checking of answers between nodes
+	err = quorum.InitQuorum(props.QuorumSize, props.QuorumMethods, props.QuorumSample)
+	if err != nil {
+		// terminate app: invalid quorum configuration
+		log.Fatalf("Unable to start vedran load balancer: %v", err)
+	}
+
+	// set up rate limits and connection caps for each client ip
+	iplimit.InitIPLimits(props.IPRateLimit, props.IPRateBurst, props.IPWSConnections, props.TrustForwardedFor)
+	iplimit.StartScheduledCleanup()
+
+	ws.InitMultiplexer(props.WSPoolSize)
+	ws.InitFailover(props.WSFailover)
+	err = ws.InitConnectionOptions(
+		props.WSMaxMessageSize,
+		props.WSIdleTimeout,
+		props.WSPingInterval,
+		props.WSAllowedOrigins,
+		props.WSCompression,
+	)
+	if err != nil {
+		// terminate app: invalid ws allowed origins
+		log.Fatalf("Unable to start vedran load balancer: %v", err)
+	}
 
 	// init database
 	database, err := storm.Open(path.Join(props.RootDir, "vedran-load-balancer.db"))
@@ -51,13 +103,31 @@ func StartLoadBalancerServer(
 	repos.RecordRepo = repositories.NewRecordRepo(database)
 	repos.NodeRepo = repositories.NewNodeRepo(database)
 	repos.DowntimeRepo = repositories.NewDowntimeRepo(database)
+	repos.MaintenanceRepo = repositories.NewMaintenanceRepo(database)
 	repos.PayoutRepo = repositories.NewPayoutRepo(database)
 	repos.FeeRepo = repositories.NewFeeRepo(database)
+	repos.APIKeyRepo = repositories.NewAPIKeyRepo(database)
+	repos.RevokedTokenRepo = repositories.NewRevokedTokenRepo(database)
+	repos.ProbeRepo = repositories.NewProbeRepo(database)
+	blockheight.Load(repos.MetricsRepo)
 	err = repos.PingRepo.ResetAllPings()
 	if err != nil {
 		log.Fatalf("Failed reseting pings because of: %v", err)
 	}
 
+	// load revoked tokens that didn't expire yet
+	err = repos.RevokedTokenRepo.DeleteExpired(time.Now())
+	if err != nil {
+		log.Fatalf("Failed removing expired tokens because of: %v", err)
+	}
+	revokedTokens, err := repos.RevokedTokenRepo.GetAll()
+	if err != nil {
+		log.Fatalf("Failed fetching revoked tokens because of: %v", err)
+	}
+	for _, token := range *revokedTokens {
+		auth.RevokeToken(token.ID, token.ExpiresAt)
+	}
+
 	// save initial payout if there isn't any saved payouts
 	p, err := repos.PayoutRepo.GetAll()
 	if err != nil {
@@ -72,6 +142,17 @@ func StartLoadBalancerServer(
 		}
 	}
 
+	// give free slots to nodes on waiting list
+	capacity.InitCapacity(props.Capacity)
+	capacity.PromoteWaitingNodes(*repos)
+
+	// nodes have to serve chain load balancer is configured with
+	chain.InitChainVerification(props.ChainGenesisHash, props.ChainName, *repos)
+
+	// penalized nodes are checked with policy of reason they were penalized for
+	penalty.InitPenaltyPolicy(props.PenaltyPolicies, props.PenaltyWindow, props.PenaltyDecay)
+	// failed requests trip breakers of nodes before nodes are penalized
+	breaker.InitBreakers(props.Breaker)
 	penalizedNodes, err := repos.NodeRepo.GetPenalizedNodes()
 	if err != nil {
 		log.Fatalf("Failed fetching penalized nodes because of: %v", err)
@@ -81,6 +162,14 @@ func StartLoadBalancerServer(
 		go penalize.ScheduleCheckForPenalizedNode(node, *repos)
 	}
 
+	// set up api keys and tier for requests without api key
+	apikey.InitAPIKeys(repos.APIKeyRepo, props.AnonymousAccess, props.AnonymousRateLimit, props.AnonymousQuota)
+	apikey.StartScheduledFlush(apikey.DefaultFlushInterval)
+
+	// starts task that probes rpc of nodes through their tunnels
+	probe.InitProbing(props.ProbeInterval, props.ProbeDivergence)
+	probe.StartScheduledProbing(repos)
+
 	// starts task that checks active nodes
 	checkactive.StartScheduledTask(repos)
 
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminToken string
+		token      string
+		want       int
+	}{
+		{name: "valid token", adminToken: "secret", token: "secret", want: http.StatusOK},
+		{name: "invalid token", adminToken: "secret", token: "other", want: http.StatusUnauthorized},
+		{name: "missing token", adminToken: "secret", want: http.StatusUnauthorized},
+		{name: "admin token not set", want: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := AdminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}), test.adminToken)
+
+			req, _ := http.NewRequest("GET", "/api/v1/admin/nodes", nil)
+			if test.token != "" {
+				req.Header.Set(AdminTokenHeader, test.token)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, test.want, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/NodeFactoryIo/vedran/internal/apikey"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// APIKeyHeader is header in which consumers can send api key
+const APIKeyHeader = "X-Api-Key"
+
+// APIKeyMiddleware reads api key from header or `key` URL parameter and rejects requests with
+// invalid key or requests that exceeded rate limit or daily quota of key. If countRequest is
+// false only validity of key is checked and request is not counted in usage. Api key is passed
+// to next handler through request context
+func APIKeyMiddleware(next http.Handler, countRequest bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(APIKeyHeader)
+		if urlKey, ok := mux.Vars(r)["key"]; ok {
+			key = urlKey
+		}
+
+		var err error
+		if countRequest {
+			err = apikey.Use(key)
+		} else {
+			err = apikey.Authorize(key)
+		}
+		if err != nil {
+			status := apiKeyErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				log.Errorf("Failed checking api key because of: %v", err)
+				http.Error(w, http.StatusText(status), status)
+			} else {
+				log.Debugf("Request rejected because of: %v", err)
+				http.Error(w, err.Error(), status)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(apikey.WithKey(r.Context(), key)))
+	})
+}
+
+func apiKeyErrorStatus(err error) int {
+	switch err {
+	case apikey.ErrKeyRequired, apikey.ErrInvalidKey:
+		return http.StatusUnauthorized
+	case apikey.ErrRateLimited, apikey.ErrQuotaExceeded:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
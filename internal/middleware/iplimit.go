@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/NodeFactoryIo/vedran/internal/iplimit"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// IPRateLimitMiddleware rejects requests from client IP that exceeded rate limit with 429 status,
+// if rpcError is true request is rpc request whose batch entries are counted separately and
+// rejected request is answered with rpc error for each request id
+func IPRateLimitMiddleware(next http.Handler, rpcError bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := iplimit.ClientIP(r)
+		var reqBody []byte
+		requests := 1
+		if rpcError {
+			reqBody, _ = ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+			requests = rpc.BatchSize(reqBody)
+		}
+		if iplimit.AllowN(ip, requests) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log.Debugf("Request from %s rejected because of rate limit", ip)
+		if !rpcError {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		var reqRPCBody rpc.RPCRequest
+		var reqRPCBodies []rpc.RPCRequest
+		isBatch := rpc.IsBatch(reqBody)
+		if isBatch {
+			_ = json.Unmarshal(reqBody, &reqRPCBodies)
+		} else {
+			_ = json.Unmarshal(reqBody, &reqRPCBody)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_ = json.NewEncoder(w).Encode(
+			rpc.CreateRPCError(isBatch, reqRPCBody, reqRPCBodies, rpc.LimitExceeded, "Rate limit exceeded"),
+		)
+	})
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NodeFactoryIo/vedran/internal/iplimit"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPRateLimitMiddleware(t *testing.T) {
+	iplimit.InitIPLimits(1, 1, 0, false)
+	defer iplimit.InitIPLimits(0, 0, 0, false)
+
+	handler := IPRateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), true)
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":7,"method":"system_health"}`)))
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, send("1.1.1.1:1000").Code)
+	assert.Equal(t, http.StatusOK, send("2.2.2.2:1000").Code)
+
+	rr := send("1.1.1.1:2000")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	var response rpc.RPCResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, json.RawMessage("7"), response.ID)
+	assert.Equal(t, rpc.LimitExceeded, response.Error.Code)
+}
+
+func TestIPRateLimitMiddleware_Batch(t *testing.T) {
+	iplimit.InitIPLimits(1, 3, 0, false)
+	defer iplimit.InitIPLimits(0, 0, 0, false)
+
+	var received []byte
+	handler := IPRateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}), true)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(body)))
+		req.RemoteAddr = "1.1.1.1:1000"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// each batch entry takes one token and request body is passed to handler unchanged
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"system_health"},{"jsonrpc":"2.0","id":2,"method":"system_health"}]`
+	assert.Equal(t, http.StatusOK, send(batch).Code)
+	assert.Equal(t, batch, string(received))
+
+	rr := send(batch)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	var responses []rpc.RPCResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &responses))
+	assert.Len(t, responses, 2)
+	assert.Equal(t, http.StatusOK, send(`{"jsonrpc":"2.0","id":3,"method":"system_health"}`).Code)
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type APIKey struct {
+	Key        string `storm:"id"`
+	Name       string
+	RateLimit  float64
+	DailyQuota int64
+	CreatedAt  time.Time
+}
+
+type APIKeyUsage struct {
+	ID       string `storm:"id"`
+	Key      string `storm:"index"`
+	Date     string
+	Requests int64
+}
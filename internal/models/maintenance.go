@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Maintenance is planned maintenance announced by node, End is zero while maintenance is in progress
+type Maintenance struct {
+	ID     int `storm:"id,increment"`
+	NodeId string
+	Start  time.Time
+	End    time.Time
+}
@@ -4,8 +4,44 @@ type Node struct {
 	ID            string `storm:"id"`
 	ConfigHash    string
 	PayoutAddress string
+	Capacity      int64
 	Token         string
 	Cooldown      int
-	LastUsed      int64
-	Active        bool
+	// PenaltyReason is reason of latest penalty of node
+	PenaltyReason string
+	// PenaltyLevel is raised by every penalty and decays while node is not penalized
+	PenaltyLevel int
+	// LastPenalty is unix time when latest penalty of node started or ended, penalty level decays from then
+	LastPenalty int64
+	LastUsed    int64
+	Active      bool
+	// Banned node is never added to active nodes until operator unbans it
+	Banned bool
+	// Draining node doesn't get new requests, but its open connections are kept
+	Draining bool
+	// Maintenance is set while node is in planned maintenance it announced
+	Maintenance bool
+	// Left is set when node left pool, node rejoins pool by registering again
+	Left bool
+	// Waiting is set while node is on waiting list because capacity of load balancer is reached
+	Waiting bool
+	// WaitingSince is unix time in nanoseconds when node was put on waiting list
+	WaitingSince int64
+	// ChainError is set if node serves wrong chain or its chain is not verified yet
+	ChainError string
+}
+
+// ChainNotVerified is chain error of node whose chain is not verified yet
+const ChainNotVerified = "chain of node is not verified yet"
+
+// IsUnavailable returns true if node must not be added to active nodes even if it is healthy
+func (n Node) IsUnavailable() bool {
+	return n.Banned || n.Draining || n.Maintenance || n.Left || n.Waiting || n.ChainError != ""
+}
+
+// HoldsSlot returns true if node counts toward capacity of load balancer, node refused
+// because of chain it serves releases its slot
+func (n Node) HoldsSlot() bool {
+	return n.Active && !n.Banned && !n.Left && !n.Waiting &&
+		(n.ChainError == "" || n.ChainError == ChainNotVerified)
 }
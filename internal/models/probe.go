@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Probe is result of latest rpc calls load balancer sent to node through its tunnel
+type Probe struct {
+	NodeId               string `storm:"id"`
+	PeerCount            int32
+	IsSyncing            bool
+	BestBlockHeight      int64
+	FinalizedBlockHeight int64
+	// Latency is time node took to answer probe
+	Latency time.Duration
+	// Error is set if node didn't answer probe
+	Error     string
+	Timestamp time.Time
+}
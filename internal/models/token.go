@@ -0,0 +1,8 @@
+package models
+
+type RevokedToken struct {
+	ID     string `storm:"id"`
+	NodeId string
+	// ExpiresAt is unix time when token expires, 0 if token doesn't expire
+	ExpiresAt int64
+}
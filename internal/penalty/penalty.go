@@ -0,0 +1,203 @@
+package penalty
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+)
+
+// Reasons for which node is penalized, each reason has its own Policy
+const (
+	FailedRequest     = "failed-request"
+	InactivePing      = "inactive-ping"
+	WSConnection      = "ws-connection"
+	WrongAnswer       = "wrong-answer"
+	FailedProbe       = "failed-probe"
+	MetricsDivergence = "metrics-divergence"
+	BreakerTrips      = "breaker-trips"
+)
+
+const (
+	// DefaultFailureWindow is time in which failures are counted toward threshold of policy
+	DefaultFailureWindow = 5 * time.Minute
+	// DefaultDecayInterval is time without penalty after which penalty level of node is lowered
+	DefaultDecayInterval = time.Hour
+	// MaxCooldown is cooldown after which node is deactivated unless policy sets other maximum
+	MaxCooldown = 17 * time.Hour
+)
+
+// Policy defines how node is penalized for reason
+type Policy struct {
+	// BaseCooldown is cooldown of first penalty, in whole minutes
+	BaseCooldown time.Duration
+	// Growth multiplies cooldown each time node is still not active after cooldown and each
+	// time node is penalized again before its penalty level decays
+	Growth float64
+	// MaxCooldown is cooldown after which node is deactivated
+	MaxCooldown time.Duration
+	// Threshold is number of failures in failure window after which node is penalized
+	Threshold int
+}
+
+// DefaultPolicy is used for reasons without policy
+var DefaultPolicy = Policy{BaseCooldown: time.Minute, Growth: 2, MaxCooldown: MaxCooldown, Threshold: 1}
+
+// DefaultPolicies returns policies used if policy of reason is not configured, single failed
+// request or connection is tolerated while wrong answers are penalized longer
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		FailedRequest:     {BaseCooldown: time.Minute, Growth: 2, MaxCooldown: MaxCooldown, Threshold: 3},
+		InactivePing:      {BaseCooldown: time.Minute, Growth: 2, MaxCooldown: MaxCooldown, Threshold: 1},
+		WSConnection:      {BaseCooldown: time.Minute, Growth: 2, MaxCooldown: MaxCooldown, Threshold: 3},
+		WrongAnswer:       {BaseCooldown: 5 * time.Minute, Growth: 2, MaxCooldown: MaxCooldown, Threshold: 1},
+		FailedProbe:       {BaseCooldown: time.Minute, Growth: 2, MaxCooldown: MaxCooldown, Threshold: 2},
+		MetricsDivergence: {BaseCooldown: 5 * time.Minute, Growth: 2, MaxCooldown: MaxCooldown, Threshold: 1},
+		BreakerTrips:      {BaseCooldown: time.Minute, Growth: 2, MaxCooldown: MaxCooldown, Threshold: 1},
+	}
+}
+
+// ParsePolicies overrides default policies with policies in format
+// reason=base-cooldown:growth:max-cooldown:threshold (e.g. failed-request=1m:2:17h:3)
+func ParsePolicies(values []string) (map[string]Policy, error) {
+	policies := DefaultPolicies()
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid penalty policy %s", value)
+		}
+		reason := parts[0]
+		if _, ok := policies[reason]; !ok {
+			return nil, fmt.Errorf("unknown penalty reason %s", reason)
+		}
+		policy, err := parsePolicy(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid penalty policy for %s: %v", reason, err)
+		}
+		policies[reason] = policy
+	}
+	return policies, nil
+}
+
+func parsePolicy(value string) (Policy, error) {
+	fields := strings.Split(value, ":")
+	if len(fields) != 4 {
+		return Policy{}, fmt.Errorf("expected base-cooldown:growth:max-cooldown:threshold")
+	}
+	base, err := time.ParseDuration(fields[0])
+	if err != nil || base < time.Minute {
+		return Policy{}, fmt.Errorf("base cooldown has to be at least 1m")
+	}
+	growth, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || growth < 1 {
+		return Policy{}, fmt.Errorf("growth has to be at least 1")
+	}
+	max, err := time.ParseDuration(fields[2])
+	if err != nil || max < base {
+		return Policy{}, fmt.Errorf("max cooldown has to be at least base cooldown")
+	}
+	threshold, err := strconv.Atoi(fields[3])
+	if err != nil || threshold < 1 {
+		return Policy{}, fmt.Errorf("threshold has to be at least 1")
+	}
+	return Policy{BaseCooldown: base, Growth: growth, MaxCooldown: max, Threshold: threshold}, nil
+}
+
+var (
+	policies      = DefaultPolicies()
+	failureWindow = DefaultFailureWindow
+	decayInterval = DefaultDecayInterval
+	// failures are times of recent failures of node for each reason
+	failures = make(map[string]map[string][]time.Time)
+	mutex    sync.Mutex
+)
+
+// InitPenaltyPolicy sets policies for reasons, window in which failures are counted toward
+// threshold and time without penalty after which penalty level of node is lowered. Default
+// policies are used if reason policies are nil
+func InitPenaltyPolicy(reasonPolicies map[string]Policy, window time.Duration, decay time.Duration) {
+	if reasonPolicies == nil {
+		reasonPolicies = DefaultPolicies()
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	policies = reasonPolicies
+	failureWindow = window
+	decayInterval = decay
+	failures = make(map[string]map[string][]time.Time)
+}
+
+// PolicyFor returns policy of reason, DefaultPolicy is returned for unknown reasons
+func PolicyFor(reason string) Policy {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if policy, ok := policies[reason]; ok {
+		return policy
+	}
+	return DefaultPolicy
+}
+
+// RecordFailure records failure of node and returns true if node reached threshold of
+// reason in failure window, failures of reason are cleared once threshold is reached
+func RecordFailure(nodeID string, reason string, now time.Time) bool {
+	threshold := PolicyFor(reason).Threshold
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	nodeFailures, ok := failures[nodeID]
+	if !ok {
+		nodeFailures = make(map[string][]time.Time)
+		failures[nodeID] = nodeFailures
+	}
+
+	recent := []time.Time{now}
+	for _, failure := range nodeFailures[reason] {
+		if now.Sub(failure) < failureWindow {
+			recent = append(recent, failure)
+		}
+	}
+	if len(recent) >= threshold {
+		delete(nodeFailures, reason)
+		return true
+	}
+	nodeFailures[reason] = recent
+	return false
+}
+
+// Penalize sets penalty of node for reason. Node starts on base cooldown of policy grown once for
+// each penalty level node has, level is raised by every penalty and lowered for each decay interval
+// passed since latest penalty of node ended
+func Penalize(node *models.Node, reason string, now time.Time) {
+	policy := PolicyFor(reason)
+
+	mutex.Lock()
+	decay := decayInterval
+	mutex.Unlock()
+
+	level := node.PenaltyLevel
+	if decay > 0 && node.LastPenalty > 0 {
+		level -= int(now.Sub(time.Unix(node.LastPenalty, 0)) / decay)
+	}
+	if level < 0 {
+		level = 0
+	}
+
+	cooldown := float64(policy.BaseCooldown/time.Minute) * math.Pow(policy.Growth, float64(level))
+	maxCooldown := float64(policy.MaxCooldown / time.Minute)
+	node.Cooldown = int(math.Ceil(math.Min(cooldown, maxCooldown)))
+	if node.Cooldown < 1 {
+		node.Cooldown = 1
+	}
+	node.PenaltyReason = reason
+	node.PenaltyLevel = level + 1
+	node.LastPenalty = now.Unix()
+}
+
+// ReachedMaxCooldown checks if cooldown of node exceeds maximum cooldown of policy node is penalized with
+func ReachedMaxCooldown(node models.Node) bool {
+	return time.Duration(node.Cooldown)*time.Minute > PolicyFor(node.PenaltyReason).MaxCooldown
+}
@@ -0,0 +1,159 @@
+package penalty
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         []string
+		reason         string
+		expectedPolicy Policy
+		expectedErr    bool
+	}{
+		{
+			name:           "default policy is kept",
+			values:         nil,
+			reason:         WrongAnswer,
+			expectedPolicy: DefaultPolicies()[WrongAnswer],
+		},
+		{
+			name:           "policy of reason is overridden",
+			values:         []string{"failed-request=2m:1.5:1h:5"},
+			reason:         FailedRequest,
+			expectedPolicy: Policy{BaseCooldown: 2 * time.Minute, Growth: 1.5, MaxCooldown: time.Hour, Threshold: 5},
+		},
+		{
+			name:        "unknown reason",
+			values:      []string{"slow-request=2m:2:1h:5"},
+			expectedErr: true,
+		},
+		{
+			name:        "missing threshold",
+			values:      []string{"failed-request=2m:2:1h"},
+			expectedErr: true,
+		},
+		{
+			name:        "base cooldown shorter than minute",
+			values:      []string{"failed-request=30s:2:1h:1"},
+			expectedErr: true,
+		},
+		{
+			name:        "growth lower than 1",
+			values:      []string{"failed-request=1m:0.5:1h:1"},
+			expectedErr: true,
+		},
+		{
+			name:        "max cooldown shorter than base cooldown",
+			values:      []string{"failed-request=10m:2:5m:1"},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policies, err := ParsePolicies(test.values)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedPolicy, policies[test.reason])
+		})
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	InitPenaltyPolicy(nil, time.Minute, DefaultDecayInterval)
+	defer InitPenaltyPolicy(nil, DefaultFailureWindow, DefaultDecayInterval)
+	now := time.Now()
+
+	// failed request is penalized after third failure in window
+	assert.False(t, RecordFailure("1", FailedRequest, now))
+	assert.False(t, RecordFailure("1", FailedRequest, now.Add(10*time.Second)))
+	assert.True(t, RecordFailure("1", FailedRequest, now.Add(20*time.Second)))
+	// failures are cleared once node is penalized
+	assert.False(t, RecordFailure("1", FailedRequest, now.Add(30*time.Second)))
+
+	// failures outside window are not counted
+	assert.False(t, RecordFailure("2", FailedRequest, now))
+	assert.False(t, RecordFailure("2", FailedRequest, now.Add(2*time.Minute)))
+	assert.False(t, RecordFailure("2", FailedRequest, now.Add(3*time.Minute)))
+
+	// inactive ping is penalized on first failure
+	assert.True(t, RecordFailure("2", InactivePing, now))
+}
+
+func TestPenalize(t *testing.T) {
+	InitPenaltyPolicy(nil, DefaultFailureWindow, time.Hour)
+	defer InitPenaltyPolicy(nil, DefaultFailureWindow, DefaultDecayInterval)
+	now := time.Now()
+
+	tests := []struct {
+		name             string
+		node             models.Node
+		reason           string
+		expectedCooldown int
+		expectedLevel    int
+	}{
+		{
+			name:             "first penalty starts on base cooldown",
+			node:             models.Node{ID: "1"},
+			reason:           WrongAnswer,
+			expectedCooldown: 5,
+			expectedLevel:    1,
+		},
+		{
+			name:             "repeated penalty grows cooldown",
+			node:             models.Node{ID: "1", PenaltyLevel: 2, LastPenalty: now.Add(-10 * time.Minute).Unix()},
+			reason:           FailedRequest,
+			expectedCooldown: 4,
+			expectedLevel:    3,
+		},
+		{
+			name:             "penalty level decays after good behavior",
+			node:             models.Node{ID: "1", PenaltyLevel: 3, LastPenalty: now.Add(-2 * time.Hour).Unix()},
+			reason:           FailedRequest,
+			expectedCooldown: 2,
+			expectedLevel:    2,
+		},
+		{
+			name:             "starting cooldown is capped with max cooldown",
+			node:             models.Node{ID: "1", PenaltyLevel: 30, LastPenalty: now.Unix()},
+			reason:           InactivePing,
+			expectedCooldown: 17 * 60,
+			expectedLevel:    31,
+		},
+		{
+			name:             "unknown reason uses default policy",
+			node:             models.Node{ID: "1"},
+			reason:           "unknown",
+			expectedCooldown: 1,
+			expectedLevel:    1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := test.node
+			Penalize(&node, test.reason, now)
+			assert.Equal(t, test.expectedCooldown, node.Cooldown)
+			assert.Equal(t, test.expectedLevel, node.PenaltyLevel)
+			assert.Equal(t, test.reason, node.PenaltyReason)
+			assert.Equal(t, now.Unix(), node.LastPenalty)
+		})
+	}
+}
+
+func TestReachedMaxCooldown(t *testing.T) {
+	policies, _ := ParsePolicies([]string{"failed-request=1m:2:1h:3"})
+	InitPenaltyPolicy(policies, DefaultFailureWindow, DefaultDecayInterval)
+	defer InitPenaltyPolicy(nil, DefaultFailureWindow, DefaultDecayInterval)
+
+	assert.False(t, ReachedMaxCooldown(models.Node{Cooldown: 60, PenaltyReason: FailedRequest}))
+	assert.True(t, ReachedMaxCooldown(models.Node{Cooldown: 64, PenaltyReason: FailedRequest}))
+	assert.False(t, ReachedMaxCooldown(models.Node{Cooldown: 64, PenaltyReason: InactivePing}))
+}
@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+)
+
+// DefaultDeniedMethods are unsafe methods that should never be exposed to public through load balancer
+var DefaultDeniedMethods = []string{
+	"author_insertKey",
+	"author_rotateKeys",
+	"author_hasKey",
+	"author_hasSessionKeys",
+	"author_removeExtrinsic",
+	"system_addReservedPeer",
+	"system_removeReservedPeer",
+	"system_reservedPeers",
+	"system_networkState",
+	"system_addLogFilter",
+	"system_resetLogFilter",
+	"offchain_*",
+}
+
+var (
+	allowedMethods []string
+	deniedMethods  []string
+)
+
+// InitMethodPolicy sets allowed and denied method patterns. If allowed patterns are provided
+// only matching methods are forwarded to nodes, methods matching denied patterns are never
+// forwarded. Patterns are matched using path.Match syntax (e.g. "state_*")
+func InitMethodPolicy(allowed []string, denied []string) error {
+	for _, pattern := range append(append([]string{}, allowed...), denied...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid method pattern %s", pattern)
+		}
+	}
+	allowedMethods = allowed
+	deniedMethods = denied
+	return nil
+}
+
+// IsMethodAllowed checks if method can be forwarded to nodes
+func IsMethodAllowed(method string) bool {
+	if matchesAny(method, deniedMethods) {
+		return false
+	}
+	if len(allowedMethods) == 0 {
+		return true
+	}
+	return matchesAny(method, allowedMethods)
+}
+
+// MethodNotAllowedError returns rpc error response for request with method that is not allowed
+func MethodNotAllowedError(req rpc.RPCRequest) rpc.RPCResponse {
+	return rpc.CreateRPCError(false, req, nil, rpc.MethodNotFound, "Method not found").(rpc.RPCResponse)
+}
+
+// FilterBatch splits batch requests on requests that are allowed and error responses
+// for requests that are not allowed
+func FilterBatch(reqs []rpc.RPCRequest) ([]rpc.RPCRequest, []rpc.RPCResponse) {
+	allowed := make([]rpc.RPCRequest, 0, len(reqs))
+	var blocked []rpc.RPCResponse
+	for _, req := range reqs {
+		if IsMethodAllowed(req.Method) {
+			allowed = append(allowed, req)
+		} else {
+			blocked = append(blocked, MethodNotAllowedError(req))
+		}
+	}
+	return allowed, blocked
+}
+
+func matchesAny(method string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, method); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitMethodPolicy(t *testing.T) {
+	err := InitMethodPolicy(nil, []string{"state_["})
+	assert.Error(t, err)
+
+	err = InitMethodPolicy([]string{"state_*"}, DefaultDeniedMethods)
+	assert.NoError(t, err)
+
+	_ = InitMethodPolicy(nil, nil)
+}
+
+func TestIsMethodAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		denied  []string
+		method  string
+		want    bool
+	}{
+		{name: "no policy", method: "author_rotateKeys", want: true},
+		{name: "denied method", denied: DefaultDeniedMethods, method: "author_rotateKeys", want: false},
+		{name: "denied pattern", denied: DefaultDeniedMethods, method: "offchain_localStorageSet", want: false},
+		{name: "not denied method", denied: DefaultDeniedMethods, method: "chain_getBlock", want: true},
+		{name: "allowed pattern", allowed: []string{"chain_*", "state_getStorage"}, method: "chain_getBlock", want: true},
+		{name: "not in allowed", allowed: []string{"chain_*", "state_getStorage"}, method: "state_call", want: false},
+		{name: "deny has priority", allowed: []string{"author_*"}, denied: []string{"author_rotateKeys"}, method: "author_rotateKeys", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_ = InitMethodPolicy(test.allowed, test.denied)
+			assert.Equal(t, test.want, IsMethodAllowed(test.method))
+		})
+	}
+	_ = InitMethodPolicy(nil, nil)
+}
+
+func TestFilterBatch(t *testing.T) {
+	_ = InitMethodPolicy(nil, []string{"author_rotateKeys"})
+	defer func() { _ = InitMethodPolicy(nil, nil) }()
+
+	allowed, blocked := FilterBatch([]rpc.RPCRequest{
+		{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "chain_getBlock"},
+		{JSONRPC: "2.0", ID: json.RawMessage("2"), Method: "author_rotateKeys"},
+	})
+
+	assert.Equal(t, []rpc.RPCRequest{{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "chain_getBlock"}}, allowed)
+	assert.Equal(t, []rpc.RPCResponse{{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage("2"),
+		Error:   &rpc.RPCError{Code: rpc.MethodNotFound, Message: "Method not found"},
+	}}, blocked)
+}
@@ -0,0 +1,221 @@
+package probe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/blockheight"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultProbeInterval = 30 * time.Second
+	// DefaultDivergenceTolerance is number of blocks heights reported by node can differ
+	// from probed heights, reported metrics are sent periodically so they can lag behind
+	DefaultDivergenceTolerance = 20
+)
+
+var (
+	interval  time.Duration
+	tolerance int64
+)
+
+// InitProbing sets interval on which nodes are probed and number of blocks reported heights
+// can differ from probed heights, interval 0 disables probing
+func InitProbing(probeInterval time.Duration, divergenceTolerance int64) {
+	interval = probeInterval
+	tolerance = divergenceTolerance
+}
+
+// IsEnabled returns true if nodes are probed
+func IsEnabled() bool {
+	return interval > 0
+}
+
+// Diverges checks if best or finalized block height reported by node differs from probed
+// height by more than divergence tolerance
+func Diverges(metrics models.Metrics, probe models.Probe) bool {
+	return abs(metrics.BestBlockHeight-probe.BestBlockHeight) > tolerance ||
+		abs(metrics.FinalizedBlockHeight-probe.FinalizedBlockHeight) > tolerance
+}
+
+// IsStale returns true if probe is older than three probe intervals, e.g. because node
+// was banned or in maintenance while other nodes were probed
+func IsStale(probe models.Probe) bool {
+	return time.Since(probe.Timestamp) > 3*interval
+}
+
+// StartScheduledProbing probes all nodes that are in pool or penalized on probe interval
+func StartScheduledProbing(repos *repositories.Repos) {
+	if !IsEnabled() {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			probeNodes(repos)
+		}
+	}()
+}
+
+func probeNodes(repos *repositories.Repos) {
+	nodes, err := repos.NodeRepo.GetAll()
+	if err != nil {
+		log.Errorf("Unable to fetch nodes for probing because of: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, node := range *nodes {
+		if !node.Active || node.IsUnavailable() {
+			continue
+		}
+		wg.Add(1)
+		go func(nodeID string) {
+			defer wg.Done()
+			probe := ProbeNode(nodeID)
+			if probe.Error != "" {
+				log.Debugf("Probe of node %s failed because of: %s", nodeID, probe.Error)
+			} else {
+				blockheight.UpdateProbedNode(nodeID, probe.BestBlockHeight, probe.FinalizedBlockHeight)
+			}
+			err := repos.ProbeRepo.Save(probe)
+			if err != nil {
+				log.Errorf("Unable to save probe of node %s because of: %v", nodeID, err)
+			}
+		}(node.ID)
+	}
+	wg.Wait()
+}
+
+type health struct {
+	Peers     int32 `json:"peers"`
+	IsSyncing bool  `json:"isSyncing"`
+}
+
+type header struct {
+	Number string `json:"number"`
+}
+
+// ProbeNode sends system_health, chain_getHeader and chain_getFinalizedHead through tunnel of node
+// and returns probe with node heights and latency, Error of probe is set if node didn't answer
+func ProbeNode(nodeID string) *models.Probe {
+	probe := &models.Probe{NodeId: nodeID, Timestamp: time.Now()}
+
+	results, err := call(nodeID, []rpc.RPCRequest{
+		{Method: "system_health", Params: []string{}},
+		{Method: "chain_getHeader", Params: []string{}},
+		{Method: "chain_getFinalizedHead", Params: []string{}},
+	})
+	probe.Latency = time.Since(probe.Timestamp)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+
+	var nodeHealth health
+	var bestHeader header
+	var finalizedHash string
+	err = unmarshalResults(results, &nodeHealth, &bestHeader, &finalizedHash)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	probe.PeerCount = nodeHealth.Peers
+	probe.IsSyncing = nodeHealth.IsSyncing
+	probe.BestBlockHeight, err = parseBlockNumber(bestHeader.Number)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+
+	results, err = call(nodeID, []rpc.RPCRequest{
+		{Method: "chain_getHeader", Params: []string{finalizedHash}},
+	})
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	var finalizedHeader header
+	err = unmarshalResults(results, &finalizedHeader)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	probe.FinalizedBlockHeight, err = parseBlockNumber(finalizedHeader.Number)
+	if err != nil {
+		probe.Error = err.Error()
+	}
+	return probe
+}
+
+// call sends requests to node in single batch and returns results in order of requests
+func call(nodeID string, reqs []rpc.RPCRequest) ([]rpc.RPCResponse, error) {
+	for i := range reqs {
+		reqs[i].JSONRPC = "2.0"
+		reqs[i].ID = json.RawMessage(strconv.Itoa(i + 1))
+	}
+	reqBody, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := rpc.SendRequestToNode(true, nodeID, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	responses, err := rpc.CheckBatchRPCResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]rpc.RPCResponse, len(reqs))
+	for _, response := range responses {
+		id, err := strconv.Atoi(string(response.ID))
+		if err != nil || id < 1 || id > len(reqs) {
+			return nil, fmt.Errorf("unexpected response id %s", response.ID)
+		}
+		results[id-1] = response
+	}
+	return results, nil
+}
+
+func unmarshalResults(responses []rpc.RPCResponse, results ...interface{}) error {
+	for i, response := range responses {
+		if response.Error != nil {
+			return fmt.Errorf("rpc error %d: %s", response.Error.Code, response.Error.Message)
+		}
+		if response.Result == nil {
+			return errors.New("empty result")
+		}
+		err := json.Unmarshal(*response.Result, results[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseBlockNumber parses hex encoded block number from header
+func parseBlockNumber(number string) (int64, error) {
+	height, err := strconv.ParseInt(strings.TrimPrefix(number, "0x"), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number %s", number)
+	}
+	return height, nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,133 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	tunnelMocks "github.com/NodeFactoryIo/vedran/mocks/http-tunnel/server"
+	"github.com/stretchr/testify/assert"
+)
+
+const finalizedHash = "0x4d7a3c8e5f1b2a6d9c0e8f7a1b3c5d7e9f0a2b4c6d8e0f1a3b5c7d9e1f3a5b7c"
+
+func TestProbeNode(t *testing.T) {
+	tests := []struct {
+		name                 string
+		results              map[string]string
+		bestBlockHeight      int64
+		finalizedBlockHeight int64
+		peerCount            int32
+		error                string
+	}{
+		{
+			name: "node answers probe",
+			results: map[string]string{
+				"system_health":                    `{"peers":12,"isSyncing":false,"shouldHavePeers":true}`,
+				"chain_getHeader":                  `{"number":"0x3e8"}`,
+				"chain_getFinalizedHead":           `"` + finalizedHash + `"`,
+				"chain_getHeader:" + finalizedHash: `{"number":"0x3e3"}`,
+			},
+			bestBlockHeight:      1000,
+			finalizedBlockHeight: 995,
+			peerCount:            12,
+		},
+		{
+			name: "node rpc returns error",
+			results: map[string]string{
+				"chain_getHeader":        `{"number":"0x3e8"}`,
+				"chain_getFinalizedHead": `"` + finalizedHash + `"`,
+			},
+			error: "rpc error -32601: Method not found",
+		},
+		{
+			name: "node returns invalid header",
+			results: map[string]string{
+				"system_health":          `{"peers":12,"isSyncing":false,"shouldHavePeers":true}`,
+				"chain_getHeader":        `{"number":"latest"}`,
+				"chain_getFinalizedHead": `"` + finalizedHash + `"`,
+			},
+			peerCount: 12,
+			error:     "invalid block number latest",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var reqs []rpc.RPCRequest
+				_ = json.NewDecoder(r.Body).Decode(&reqs)
+				responses := make([]string, len(reqs))
+				for i, req := range reqs {
+					key := req.Method
+					if params, ok := req.Params.([]interface{}); ok && len(params) > 0 {
+						key = fmt.Sprintf("%s:%v", req.Method, params[0])
+					}
+					if result, ok := test.results[key]; ok {
+						responses[i] = fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+					} else {
+						responses[i] = fmt.Sprintf(
+							`{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"Method not found"}}`, req.ID,
+						)
+					}
+				}
+				_, _ = fmt.Fprintf(w, "[%s]", strings.Join(responses, ","))
+			}))
+			defer s.Close()
+			u, _ := url.Parse(s.URL)
+			port, _ := strconv.Atoi(u.Port())
+			poolerMock := tunnelMocks.Pooler{}
+			poolerMock.On("GetHTTPPort", "1").Return(port, nil)
+			configuration.Config.PortPool = &poolerMock
+			defer func() { configuration.Config.PortPool = nil }()
+
+			probe := ProbeNode("1")
+
+			assert.Equal(t, "1", probe.NodeId)
+			assert.Equal(t, test.error, probe.Error)
+			assert.Equal(t, test.bestBlockHeight, probe.BestBlockHeight)
+			assert.Equal(t, test.finalizedBlockHeight, probe.FinalizedBlockHeight)
+			assert.Equal(t, test.peerCount, probe.PeerCount)
+			assert.False(t, probe.Timestamp.IsZero())
+		})
+	}
+}
+
+func TestDiverges(t *testing.T) {
+	InitProbing(DefaultProbeInterval, DefaultDivergenceTolerance)
+	defer InitProbing(0, 0)
+
+	metrics := models.Metrics{BestBlockHeight: 1000, FinalizedBlockHeight: 995}
+	tests := []struct {
+		name     string
+		probe    models.Probe
+		diverges bool
+	}{
+		{
+			name:  "reported metrics lag behind probe within tolerance",
+			probe: models.Probe{BestBlockHeight: 1020, FinalizedBlockHeight: 1015},
+		},
+		{
+			name:     "reported best block is higher than probed",
+			probe:    models.Probe{BestBlockHeight: 900, FinalizedBlockHeight: 990},
+			diverges: true,
+		},
+		{
+			name:     "reported finalized block lags behind probe",
+			probe:    models.Probe{BestBlockHeight: 1000, FinalizedBlockHeight: 1100},
+			diverges: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.diverges, Diverges(metrics, test.probe))
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/NodeFactoryIo/vedran/internal/apikey"
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
 	"github.com/NodeFactoryIo/vedran/internal/payout"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
@@ -20,6 +21,8 @@ import (
 
 const (
 	nextPayoutDateLayout = "Mon, Jan 2 2006."
+	// activeChangesBuffer is number of changes of active nodes buffered for active node count
+	activeChangesBuffer = 100
 
 	FeeStatsIntervalEnv                  = "PROM_FEE_STATS_INTERVAL"
 	DefaultFeeStatsCollectionInterval    = 12 * time.Hour
@@ -49,6 +52,10 @@ var (
 		Name: "vedran_number_of_successful_requests",
 		Help: "The total number of successful requests served via vedran",
 	})
+	subscriptionNotifications = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "vedran_number_of_subscription_notifications",
+		Help: "The total number of subscription notifications sent by nodes",
+	})
 	failedRequests = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "vedran_number_of_failed_requests",
 		Help: "The total number of successful requests served via vedran",
@@ -86,6 +93,13 @@ var (
 			Help: "Payout fee for each last payout",
 		},
 		[]string{"node"})
+
+	apiKeyRequests = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "vedran_api_key_requests_today",
+			Help: "The number of requests made today per api key",
+		},
+		[]string{"name"})
 )
 
 // RecordMetrics starts goroutines for recording metrics
@@ -123,9 +137,21 @@ func RecordMetrics(repos repositories.Repos) {
 	go recordPenalizedNodeCount(repos.NodeRepo)
 	go recordSuccessfulRequestCount(repos.RecordRepo)
 	go recordFailedRequestCount(repos.RecordRepo)
+	go recordNotificationCount(repos.RecordRepo)
 	go recordPayoutDate(repos)
 	go recordLbFeeAmount(repos.PayoutRepo)
 	go recordNodeFees(repos.FeeRepo)
+	go recordAPIKeyUsage()
+}
+
+func recordAPIKeyUsage() {
+	for {
+		apiKeyRequests.Reset()
+		for _, usage := range apikey.CurrentUsage() {
+			apiKeyRequests.With(prometheus.Labels{"name": usage.Name}).Add(float64(usage.Requests))
+		}
+		time.Sleep(requestStatsCollectionInterval)
+	}
 }
 
 func recordNodeFees(repos repositories.FeeRepository) {
@@ -209,10 +235,12 @@ func recordPayoutDate(repos repositories.Repos) {
 	}
 }
 
+// recordActiveNodeCount updates active node count whenever node is added to or removed from active nodes
 func recordActiveNodeCount(nodeRepo repositories.NodeRepository) {
-	for {
-		activeNodes.Set(float64(len(*nodeRepo.GetAllActiveNodes())))
-		time.Sleep(nodeStatsCollectionInterval)
+	changes, _ := nodeRepo.SubscribeToActiveNodes(activeChangesBuffer)
+	activeNodes.Set(float64(len(*nodeRepo.GetAllActiveNodes())))
+	for change := range changes {
+		activeNodes.Set(float64(change.Count))
 	}
 }
 
@@ -270,3 +298,11 @@ func setUpCollectionIntervals() {
 		payoutStatsCollectionInterval = DefaultPayoutStatsCollectionInterval
 	}
 }
+
+func recordNotificationCount(recordRepo repositories.RecordRepository) {
+	for {
+		count, _ := recordRepo.CountNotifications()
+		subscriptionNotifications.Set(float64(count))
+		time.Sleep(requestStatsCollectionInterval)
+	}
+}
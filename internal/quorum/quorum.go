@@ -0,0 +1,146 @@
+package quorum
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path"
+
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+)
+
+// DefaultQuorumSize is number of nodes that serve request in quorum mode
+const DefaultQuorumSize = 3
+
+var (
+	size    = DefaultQuorumSize
+	methods []string
+	sample  float64
+)
+
+// nodeLocalMethods are known methods whose answer differs between honest nodes, patterns
+// matching any of them are rejected
+var nodeLocalMethods = []string{
+	"system_health",
+	"system_peers",
+	"system_name",
+	"system_version",
+	"system_chain",
+	"system_properties",
+	"system_syncState",
+	"system_localPeerId",
+	"system_nodeRoles",
+	"author_pendingExtrinsics",
+	"offchain_localStorageGet",
+	"rpc_methods",
+}
+
+// InitQuorum sets number of nodes used in quorum mode, methods (or glob patterns) that are always
+// cross checked and fraction (0-1) of other read only requests that are randomly cross checked.
+// Patterns that match node local methods are rejected, as honest nodes answer them differently
+func InitQuorum(quorumSize int, quorumMethods []string, quorumSample float64) error {
+	if quorumSize < 2 {
+		return fmt.Errorf("invalid quorum size %d", quorumSize)
+	}
+	for _, pattern := range quorumMethods {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid method pattern %s", pattern)
+		}
+		if rpc.IsNodeLocalMethod(pattern) {
+			return fmt.Errorf("method pattern %s matches node local methods", pattern)
+		}
+		for _, method := range nodeLocalMethods {
+			if matched, _ := path.Match(pattern, method); matched {
+				return fmt.Errorf("method pattern %s matches node local method %s", pattern, method)
+			}
+		}
+	}
+	size = quorumSize
+	methods = quorumMethods
+	sample = quorumSample
+	return nil
+}
+
+// Size returns number of nodes used in quorum mode
+func Size() int {
+	return size
+}
+
+// IsRequired checks if request should be cross checked between nodes. Only requests whose answer
+// is same on every honest node are cross checked, that is requests pinned to block hash and
+// block hash requests for blocks at or below finalized height
+func IsRequired(req rpc.RPCRequest, finalizedHeight int64) bool {
+	if !hasFixedAnswer(req, finalizedHeight) {
+		return false
+	}
+	for _, pattern := range methods {
+		if matched, _ := path.Match(pattern, req.Method); matched {
+			return true
+		}
+	}
+	return sample > 0 && rand.Float64() < sample
+}
+
+// hasFixedAnswer checks if answer to request can't differ between honest nodes at different heights
+func hasFixedAnswer(req rpc.RPCRequest, finalizedHeight int64) bool {
+	if rpc.IsNodeLocalMethod(req.Method) || !rpc.IsReadOnlyMethod(req.Method) {
+		return false
+	}
+	if number, ok := rpc.BlockNumberParam(req); ok {
+		return number <= finalizedHeight
+	}
+	_, ok := rpc.BlockHashParam(req)
+	return ok
+}
+
+// Normalize returns canonical representation of rpc response used for comparing
+// answers from different nodes, ignoring request id and formatting
+func Normalize(body []byte) (string, error) {
+	var response rpc.RPCResponse
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if response.Error != nil {
+		return fmt.Sprintf("error:%d", response.Error.Code), nil
+	}
+	if response.Result == nil {
+		return "result:null", nil
+	}
+
+	var result interface{}
+	err = json.Unmarshal(*response.Result, &result)
+	if err != nil {
+		return "", err
+	}
+	// marshaling decoded value sorts object keys and removes whitespace
+	canonical, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return "result:" + string(canonical), nil
+}
+
+// Majority returns index of answer that is majority between answers and true if more than half
+// of answers agree. If there is no majority, index of most common answer is returned
+func Majority(answers []string) (int, bool) {
+	counts := make(map[string]int, len(answers))
+	best := -1
+	for i, answer := range answers {
+		counts[answer]++
+		if best == -1 || counts[answer] > counts[answers[best]] {
+			best = i
+		}
+	}
+	if best == -1 {
+		return -1, false
+	}
+	// return index of first occurrence of most common answer
+	for i, answer := range answers {
+		if answer == answers[best] {
+			return i, counts[answer]*2 > len(answers)
+		}
+	}
+	return best, false
+}
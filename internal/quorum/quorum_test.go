@@ -0,0 +1,94 @@
+package quorum
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitQuorum(t *testing.T) {
+	defer func() { _ = InitQuorum(DefaultQuorumSize, nil, 0) }()
+
+	assert.Error(t, InitQuorum(1, nil, 0))
+	assert.Error(t, InitQuorum(3, []string{"state_["}, 0))
+	// patterns matching node local methods are rejected
+	assert.Error(t, InitQuorum(3, []string{"*"}, 0))
+	assert.Error(t, InitQuorum(3, []string{"system_*"}, 0))
+	assert.Error(t, InitQuorum(3, []string{"system_unknownMethod"}, 0))
+	assert.Error(t, InitQuorum(3, []string{"*_health"}, 0))
+	assert.NoError(t, InitQuorum(3, []string{"state_*", "chain_getBlockHash"}, 0))
+}
+
+func TestIsRequired(t *testing.T) {
+	defer func() { _ = InitQuorum(DefaultQuorumSize, nil, 0) }()
+	hash := "0x" + strings.Repeat("ab", 32)
+
+	assert.NoError(t, InitQuorum(3, []string{"state_getStorage*", "chain_getBlockHash"}, 0))
+	tests := []struct {
+		name string
+		req  rpc.RPCRequest
+		want bool
+	}{
+		{name: "storage at block hash", req: rpc.RPCRequest{Method: "state_getStorage", Params: []interface{}{"0x26aa", hash}}, want: true},
+		{name: "storage at latest block", req: rpc.RPCRequest{Method: "state_getStorage", Params: []interface{}{"0x26aa"}}, want: false},
+		{name: "finalized block hash", req: rpc.RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(100)}}, want: true},
+		{name: "non finalized block hash", req: rpc.RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(101)}}, want: false},
+		{name: "method that is not listed", req: rpc.RPCRequest{Method: "chain_getBlock", Params: []interface{}{hash}}, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, IsRequired(test.req, 100))
+		})
+	}
+
+	// sampled requests are cross checked only if their answer is fixed
+	assert.NoError(t, InitQuorum(3, nil, 1))
+	assert.True(t, IsRequired(rpc.RPCRequest{Method: "chain_getBlock", Params: []interface{}{hash}}, 100))
+	assert.False(t, IsRequired(rpc.RPCRequest{Method: "chain_getHeader"}, 100))
+	assert.False(t, IsRequired(rpc.RPCRequest{Method: "chain_getFinalizedHead"}, 100))
+	assert.False(t, IsRequired(rpc.RPCRequest{Method: "system_health"}, 100))
+	assert.False(t, IsRequired(rpc.RPCRequest{Method: "author_submitExtrinsic", Params: []interface{}{"0x00"}}, 100))
+}
+
+func TestNormalize(t *testing.T) {
+	a, err := Normalize([]byte(`{"jsonrpc":"2.0","id":1,"result":{"b":1,"a":"0x1"}}`))
+	assert.NoError(t, err)
+	b, err := Normalize([]byte(`{"id":2,"jsonrpc":"2.0","result":{ "a": "0x1", "b": 1 }}`))
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	c, err := Normalize([]byte(`{"jsonrpc":"2.0","id":1,"result":{"a":"0x2","b":1}}`))
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, c)
+
+	e, err := Normalize([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "error:-32602", e)
+
+	_, err = Normalize([]byte(`invalid`))
+	assert.Error(t, err)
+}
+
+func TestMajority(t *testing.T) {
+	tests := []struct {
+		name        string
+		answers     []string
+		wantIndex   int
+		wantQuorate bool
+	}{
+		{name: "no answers", answers: nil, wantIndex: -1, wantQuorate: false},
+		{name: "single answer", answers: []string{"a"}, wantIndex: 0, wantQuorate: true},
+		{name: "majority", answers: []string{"b", "a", "a"}, wantIndex: 1, wantQuorate: true},
+		{name: "no majority", answers: []string{"a", "b"}, wantIndex: 0, wantQuorate: false},
+		{name: "plurality without majority", answers: []string{"a", "b", "b", "c"}, wantIndex: 1, wantQuorate: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index, quorate := Majority(test.answers)
+			assert.Equal(t, test.wantIndex, index)
+			assert.Equal(t, test.wantQuorate, quorate)
+		})
+	}
+}
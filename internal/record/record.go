@@ -2,16 +2,23 @@ package record
 
 import (
 	"github.com/NodeFactoryIo/vedran/internal/actions"
+	"github.com/NodeFactoryIo/vedran/internal/breaker"
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
 
-// FailedRequest should be called when rpc response is invalid to penalize node.
+// FailedRequest should be called when rpc response is invalid to penalize node. If breakers are enabled
+// failure is recorded to breaker of node and node is penalized only if its breaker trips repeatedly.
 // It does not return value as it should be called in separate goroutine
 func FailedRequest(node models.Node, repositories repositories.Repos, actions actions.Actions) {
-	actions.PenalizeNode(node, repositories, "failed request")
+	if !breaker.IsEnabled() {
+		actions.PenalizeNode(node, repositories, penalty.FailedRequest)
+	} else if breaker.RecordFailure(node.ID, time.Now()) {
+		actions.PenalizeNode(node, repositories, penalty.BreakerTrips)
+	}
 
 	err := repositories.RecordRepo.Save(&models.Record{
 		NodeId:    node.ID,
@@ -25,10 +32,42 @@ func FailedRequest(node models.Node, repositories repositories.Repos, actions ac
 	log.Debugf("Node %s failed to serve successful request", node.ID)
 }
 
+// WrongAnswer should be called when node answer differs from answer of majority of nodes
+// to penalize node. It does not return value as it should be called in separate goroutine
+func WrongAnswer(node models.Node, repositories repositories.Repos, actions actions.Actions) {
+	actions.PenalizeNode(node, repositories, penalty.WrongAnswer)
+
+	err := repositories.RecordRepo.Save(&models.Record{
+		NodeId:    node.ID,
+		Timestamp: time.Now(),
+		Status:    "failed",
+	})
+	if err != nil {
+		log.Errorf("Failed saving wrong answer because of: %v", err)
+	}
+
+	log.Debugf("Node %s answer differs from majority of nodes", node.ID)
+}
+
+// SubscriptionNotification should be called when node sends subscription notification. Notifications
+// are counted separately from requests and are not rewarded, so node doesn't get larger share of payout
+// only because it serves chatty subscription. It does not return value as it should be called in separate goroutine
+func SubscriptionNotification(node models.Node, repositories repositories.Repos) {
+	err := repositories.RecordRepo.Save(&models.Record{
+		NodeId:    node.ID,
+		Timestamp: time.Now(),
+		Status:    "notification",
+	})
+	if err != nil {
+		log.Errorf("Failed saving subscription notification because of: %v", err)
+	}
+}
+
 // SuccessfulRequest should be called when rpc response is valid to reward node.
 // It does not return value as it should be called in separate goroutine
 func SuccessfulRequest(node models.Node, repositories repositories.Repos) {
 	repositories.NodeRepo.UpdateNodeUsed(node)
+	breaker.RecordSuccess(node.ID, time.Now())
 
 	err := repositories.RecordRepo.Save(&models.Record{
 		NodeId:    node.ID,
@@ -2,12 +2,16 @@ package record
 
 import (
 	"fmt"
+	"github.com/NodeFactoryIo/vedran/internal/breaker"
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	aMock "github.com/NodeFactoryIo/vedran/mocks/actions"
 	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 func TestFailedRequest(t *testing.T) {
@@ -52,6 +56,82 @@ func TestFailedRequest(t *testing.T) {
 
 	}
 }
+
+func TestFailedRequestWithBreaker(t *testing.T) {
+	opts := breaker.DefaultOptions()
+	opts.RetryBudget = 0
+	opts.Trips = 2
+	opts.OpenDuration = 0
+	breaker.InitBreakers(opts)
+	defer breaker.InitBreakers(breaker.Options{})
+
+	node := models.Node{
+		ID: "test-id",
+	}
+
+	recordRepoMock := mocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.Anything).Return(nil)
+
+	actionsMock := aMock.Actions{}
+	actionsMock.On("PenalizeNode", node, mock.Anything, penalty.BreakerTrips).Return()
+
+	repos := repositories.Repos{
+		RecordRepo: &recordRepoMock,
+	}
+
+	// first trip of breaker only keeps node out of selection
+	FailedRequest(node, repos, &actionsMock)
+	actionsMock.AssertNotCalled(t, "PenalizeNode", node, mock.Anything, mock.Anything)
+	assert.Equal(t, breaker.Open, breaker.StateOf("test-id"))
+
+	// node is penalized once its breaker trips again
+	breaker.Allow("test-id", time.Now())
+	FailedRequest(node, repos, &actionsMock)
+	actionsMock.AssertNumberOfCalls(t, "PenalizeNode", 1)
+	recordRepoMock.AssertNumberOfCalls(t, "Save", 2)
+}
+
+func TestWrongAnswer(t *testing.T) {
+	node := models.Node{
+		ID: "test-id",
+	}
+
+	recordRepoMock := mocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.MatchedBy(func(r *models.Record) bool {
+		return r.NodeId == "test-id" && r.Status == "failed"
+	})).Once().Return(nil)
+
+	actionsMock := aMock.Actions{}
+	actionsMock.On("PenalizeNode", node, mock.Anything, penalty.WrongAnswer).Return()
+
+	WrongAnswer(node, repositories.Repos{
+		RecordRepo: &recordRepoMock,
+	}, &actionsMock)
+
+	actionsMock.AssertNumberOfCalls(t, "PenalizeNode", 1)
+	recordRepoMock.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestSubscriptionNotification(t *testing.T) {
+	node := models.Node{
+		ID: "test-id",
+	}
+
+	recordRepoMock := mocks.RecordRepository{}
+	recordRepoMock.On("Save", mock.MatchedBy(func(r *models.Record) bool {
+		return r.NodeId == "test-id" && r.Status == "notification"
+	})).Once().Return(nil)
+	nodeRepoMock := mocks.NodeRepository{}
+
+	SubscriptionNotification(node, repositories.Repos{
+		RecordRepo: &recordRepoMock,
+		NodeRepo:   &nodeRepoMock,
+	})
+
+	recordRepoMock.AssertNumberOfCalls(t, "Save", 1)
+	nodeRepoMock.AssertNotCalled(t, "UpdateNodeUsed", mock.Anything)
+}
+
 func TestSuccessfulRequest(t *testing.T) {
 	tests := []struct {
 		name                    string
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// ActiveChangeKind is kind of change of active nodes
+type ActiveChangeKind int
+
+const (
+	NodeAdded ActiveChangeKind = iota
+	NodeRemoved
+)
+
+// ActiveChange is sent to subscribers when node is added to or removed from active nodes
+type ActiveChange struct {
+	Kind ActiveChangeKind
+	Node models.Node
+	// Count is number of active nodes after change
+	Count int
+}
+
+// ActiveRegistry holds nodes that currently serve requests. Readers get immutable snapshots
+// without locking, while mutations are serialized and publish new snapshot atomically
+type ActiveRegistry struct {
+	mutex       sync.Mutex
+	snapshot    atomic.Value
+	subscribers map[chan ActiveChange]struct{}
+}
+
+func NewActiveRegistry() *ActiveRegistry {
+	r := &ActiveRegistry{
+		subscribers: make(map[chan ActiveChange]struct{}),
+	}
+	r.snapshot.Store(make([]models.Node, 0))
+	return r
+}
+
+// Snapshot returns active nodes at time of call, returned slice is shared
+// between readers and must not be modified
+func (r *ActiveRegistry) Snapshot() []models.Node {
+	return r.snapshot.Load().([]models.Node)
+}
+
+// Contains checks if node is active
+func (r *ActiveRegistry) Contains(ID string) bool {
+	return indexOf(r.Snapshot(), ID) >= 0
+}
+
+// Add adds node to active nodes, error is returned if node is already active
+func (r *ActiveRegistry) Add(node models.Node) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current := r.Snapshot()
+	if indexOf(current, node.ID) >= 0 {
+		return fmt.Errorf("node %s already set as active", node.ID)
+	}
+	next := make([]models.Node, len(current), len(current)+1)
+	copy(next, current)
+	next = append(next, node)
+	r.snapshot.Store(next)
+
+	r.notify(ActiveChange{Kind: NodeAdded, Node: node, Count: len(next)})
+	return nil
+}
+
+// Remove removes node from active nodes, error is returned if node is not active
+func (r *ActiveRegistry) Remove(ID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current := r.Snapshot()
+	i := indexOf(current, ID)
+	if i < 0 {
+		return fmt.Errorf("no target node %s in memory", ID)
+	}
+	next := make([]models.Node, 0, len(current)-1)
+	next = append(next, current[:i]...)
+	next = append(next, current[i+1:]...)
+	r.snapshot.Store(next)
+
+	r.notify(ActiveChange{Kind: NodeRemoved, Node: current[i], Count: len(next)})
+	return nil
+}
+
+// Update applies update to copy of active node and publishes it, false is returned if node
+// is not active. Subscribers are not notified about updates
+func (r *ActiveRegistry) Update(ID string, update func(node *models.Node)) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current := r.Snapshot()
+	i := indexOf(current, ID)
+	if i < 0 {
+		return false
+	}
+	next := make([]models.Node, len(current))
+	copy(next, current)
+	update(&next[i])
+	r.snapshot.Store(next)
+	return true
+}
+
+// Subscribe returns channel on which changes of active nodes are sent and function that
+// cancels subscription and closes channel. Changes are dropped for subscribers whose buffer
+// is full, so subscribers should rely on Count of latest change rather than on every change
+func (r *ActiveRegistry) Subscribe(buffer int) (<-chan ActiveChange, func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	changes := make(chan ActiveChange, buffer)
+	r.subscribers[changes] = struct{}{}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			delete(r.subscribers, changes)
+			close(changes)
+		})
+	}
+	return changes, cancel
+}
+
+// notify sends change to subscribers without blocking, has to be called while holding lock
+func (r *ActiveRegistry) notify(change ActiveChange) {
+	for subscriber := range r.subscribers {
+		select {
+		case subscriber <- change:
+		default:
+			log.Warnf("Dropped change of active node %s because subscriber is not keeping up", change.Node.ID)
+		}
+	}
+}
+
+func indexOf(nodes []models.Node, ID string) int {
+	for i, node := range nodes {
+		if node.ID == ID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/breaker"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/asdine/storm/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveRegistry(t *testing.T) {
+	registry := NewActiveRegistry()
+	changes, cancel := registry.Subscribe(10)
+
+	assert.NoError(t, registry.Add(models.Node{ID: "1"}))
+	assert.NoError(t, registry.Add(models.Node{ID: "2"}))
+	assert.Error(t, registry.Add(models.Node{ID: "1"}))
+	snapshot := registry.Snapshot()
+	assert.Equal(t, []models.Node{{ID: "1"}, {ID: "2"}}, snapshot)
+
+	// updates and removals don't change snapshots readers already hold
+	assert.True(t, registry.Update("1", func(node *models.Node) { node.LastUsed = 100 }))
+	assert.False(t, registry.Update("3", func(node *models.Node) { node.LastUsed = 100 }))
+	assert.NoError(t, registry.Remove("2"))
+	assert.Error(t, registry.Remove("2"))
+	assert.Equal(t, []models.Node{{ID: "1"}, {ID: "2"}}, snapshot)
+	assert.Equal(t, []models.Node{{ID: "1", LastUsed: 100}}, registry.Snapshot())
+	assert.True(t, registry.Contains("1"))
+	assert.False(t, registry.Contains("2"))
+
+	// only additions and removals are notified
+	cancel()
+	var received []ActiveChange
+	for change := range changes {
+		received = append(received, change)
+	}
+	assert.Equal(t, []ActiveChange{
+		{Kind: NodeAdded, Node: models.Node{ID: "1"}, Count: 1},
+		{Kind: NodeAdded, Node: models.Node{ID: "2"}, Count: 2},
+		{Kind: NodeRemoved, Node: models.Node{ID: "2"}, Count: 1},
+	}, received)
+
+	// cancelled subscriber is not notified and cancel can be called again
+	assert.NoError(t, registry.Add(models.Node{ID: "2"}))
+	cancel()
+}
+
+func TestActiveRegistry_Concurrent(t *testing.T) {
+	const nodes = 20
+	const rounds = 200
+	registry := NewActiveRegistry()
+	// buffer fits every change, each writer adds and removes node at most once per round
+	changes, cancel := registry.Subscribe(nodes*rounds*4 + nodes)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	readerErrors := make(chan error, 1)
+
+	// readers check that every snapshot holds each node at most once
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				seen := make(map[string]bool)
+				for _, node := range registry.Snapshot() {
+					if seen[node.ID] {
+						select {
+						case readerErrors <- fmt.Errorf("node %s is duplicated", node.ID):
+						default:
+						}
+						return
+					}
+					seen[node.ID] = true
+				}
+			}
+		}()
+	}
+
+	// two writers per node race on adding, updating and removing it
+	var writers sync.WaitGroup
+	for n := 0; n < nodes; n++ {
+		for w := 0; w < 2; w++ {
+			writers.Add(1)
+			go func(ID string) {
+				defer writers.Done()
+				for i := 0; i < rounds; i++ {
+					_ = registry.Add(models.Node{ID: ID})
+					registry.Update(ID, func(node *models.Node) { node.LastUsed++ })
+					_ = registry.Remove(ID)
+				}
+			}(fmt.Sprintf("node-%d", n))
+		}
+	}
+	writers.Wait()
+
+	// every node is added once more, so none of them can be dropped
+	for n := 0; n < nodes; n++ {
+		wg.Add(1)
+		go func(ID string) {
+			defer wg.Done()
+			assert.NoError(t, registry.Add(models.Node{ID: ID}))
+		}(fmt.Sprintf("node-%d", n))
+	}
+	close(done)
+	wg.Wait()
+
+	select {
+	case err := <-readerErrors:
+		t.Fatal(err)
+	default:
+	}
+	assert.Len(t, registry.Snapshot(), nodes)
+	for n := 0; n < nodes; n++ {
+		assert.True(t, registry.Contains(fmt.Sprintf("node-%d", n)))
+	}
+
+	// notified changes add up to final number of active nodes
+	count := 0
+	for len(changes) > 0 {
+		change := <-changes
+		if change.Kind == NodeAdded {
+			count++
+		} else {
+			count--
+		}
+	}
+	assert.Equal(t, nodes, count)
+}
+
+func TestNodeRepo_ActiveNodesConcurrent(t *testing.T) {
+	db, err := storm.Open(filepath.Join(t.TempDir(), "vedran.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	repo := NewNodeRepo(db)
+
+	const nodes = 10
+	for n := 0; n < nodes; n++ {
+		assert.NoError(t, repo.Save(&models.Node{ID: fmt.Sprintf("node-%d", n), Active: true}))
+	}
+
+	// handlers, checkactive task and penalize timers add and remove nodes while requests are served
+	var wg sync.WaitGroup
+	for n := 0; n < nodes; n++ {
+		ID := fmt.Sprintf("node-%d", n)
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				_ = repo.AddNodeToActive(ID)
+				_ = repo.RemoveNodeFromActive(ID)
+			}
+			_ = repo.AddNodeToActive(ID)
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				_ = repo.AddNodeToActive(ID)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				for _, node := range *repo.GetActiveNodes("round-robin") {
+					repo.UpdateNodeUsed(node)
+				}
+				repo.IsNodeActive(ID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	active := *repo.GetAllActiveNodes()
+	assert.Len(t, active, nodes)
+	for n := 0; n < nodes; n++ {
+		assert.True(t, repo.IsNodeActive(fmt.Sprintf("node-%d", n)))
+	}
+}
+
+func TestAllowedNodes(t *testing.T) {
+	opts := breaker.DefaultOptions()
+	opts.RetryBudget = 0
+	opts.Trickle = 0
+	breaker.InitBreakers(opts)
+	defer breaker.InitBreakers(breaker.Options{})
+
+	// breakers of node 1 and 2 are half open and breaker of node 3 is still open
+	past := time.Now().Add(-time.Hour)
+	breaker.RecordFailure("1", past)
+	breaker.RecordFailure("2", past)
+	breaker.RecordFailure("3", time.Now())
+
+	// half open nodes get requests only if there is no other node
+	halfOpen := []models.Node{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+	assert.Equal(t, []models.Node{{ID: "1"}, {ID: "2"}}, allowedNodes(halfOpen))
+	assert.Equal(t, []models.Node{{ID: "4"}}, allowedNodes(append(halfOpen, models.Node{ID: "4"})))
+	assert.Empty(t, allowedNodes([]models.Node{{ID: "3"}}))
+}
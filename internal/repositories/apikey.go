@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/asdine/storm/v3"
+)
+
+type APIKeyRepository interface {
+	FindByKey(key string) (*models.APIKey, error)
+	Save(apiKey *models.APIKey) error
+	GetAll() (*[]models.APIKey, error)
+	Delete(key string) error
+	// SaveUsage saves number of requests made with key on date
+	SaveUsage(key string, date string, requests int64) error
+	// FindUsage returns number of requests made with key on date
+	FindUsage(key string, date string) (int64, error)
+	// GetUsage returns daily usage of key
+	GetUsage(key string) ([]models.APIKeyUsage, error)
+}
+
+type apiKeyRepo struct {
+	db *storm.DB
+}
+
+func NewAPIKeyRepo(db *storm.DB) APIKeyRepository {
+	return &apiKeyRepo{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepo) FindByKey(key string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	err := r.db.One("Key", key, &apiKey)
+	return &apiKey, err
+}
+
+func (r *apiKeyRepo) Save(apiKey *models.APIKey) error {
+	return r.db.Save(apiKey)
+}
+
+func (r *apiKeyRepo) GetAll() (*[]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	err := r.db.All(&apiKeys)
+	return &apiKeys, err
+}
+
+func (r *apiKeyRepo) Delete(key string) error {
+	return r.db.DeleteStruct(&models.APIKey{Key: key})
+}
+
+func (r *apiKeyRepo) SaveUsage(key string, date string, requests int64) error {
+	return r.db.Save(&models.APIKeyUsage{
+		ID:       usageID(key, date),
+		Key:      key,
+		Date:     date,
+		Requests: requests,
+	})
+}
+
+func (r *apiKeyRepo) FindUsage(key string, date string) (int64, error) {
+	var usage models.APIKeyUsage
+	err := r.db.One("ID", usageID(key, date), &usage)
+	if err != nil {
+		if err.Error() == "not found" {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return usage.Requests, nil
+}
+
+func (r *apiKeyRepo) GetUsage(key string) ([]models.APIKeyUsage, error) {
+	var usage []models.APIKeyUsage
+	err := r.db.Find("Key", key, &usage)
+	if err != nil && err.Error() == "not found" {
+		return []models.APIKeyUsage{}, nil
+	}
+	return usage, err
+}
+
+func usageID(key string, date string) string {
+	return fmt.Sprintf("%s:%s", key, date)
+}
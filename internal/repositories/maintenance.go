@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+)
+
+type MaintenanceRepository interface {
+	Save(maintenance *models.Maintenance) error
+	// FindOngoing returns maintenance of node that is still in progress
+	FindOngoing(nodeID string) (*models.Maintenance, error)
+	// FindMaintenancesInsideInterval returns all models.Maintenance that overlap with interval
+	// defined with arguments from and to, including maintenance still in progress
+	FindMaintenancesInsideInterval(nodeID string, from time.Time, to time.Time) ([]models.Maintenance, error)
+}
+
+type maintenanceRepo struct {
+	db *storm.DB
+}
+
+func NewMaintenanceRepo(db *storm.DB) MaintenanceRepository {
+	return &maintenanceRepo{
+		db: db,
+	}
+}
+
+func (r *maintenanceRepo) Save(maintenance *models.Maintenance) error {
+	return r.db.Save(maintenance)
+}
+
+func (r *maintenanceRepo) FindOngoing(nodeID string) (*models.Maintenance, error) {
+	var maintenance models.Maintenance
+	err := r.db.Select(q.Eq("NodeId", nodeID), q.Eq("End", time.Time{})).First(&maintenance)
+	return &maintenance, err
+}
+
+func (r *maintenanceRepo) FindMaintenancesInsideInterval(nodeID string, from time.Time, to time.Time) ([]models.Maintenance, error) {
+	var maintenances []models.Maintenance
+	err := r.db.Select(q.And(
+		q.Eq("NodeId", nodeID),
+		q.Lte("Start", to),
+		q.Or(
+			q.Gte("End", from),
+			q.Eq("End", time.Time{}),
+		),
+	)).Find(&maintenances)
+	return maintenances, err
+}
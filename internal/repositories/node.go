@@ -2,45 +2,49 @@ package repositories
 
 import (
 	"fmt"
-	"math/rand"
-	"sort"
-	"sync"
+	"math"
 	"time"
 
+	"github.com/NodeFactoryIo/vedran/internal/breaker"
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/selection"
 	"github.com/asdine/storm/v3"
 	"github.com/asdine/storm/v3/q"
 	log "github.com/sirupsen/logrus"
 )
 
-var activeNodes []models.Node
-var mutex = &sync.Mutex{}
-
 type NodeRepository interface {
 	FindByID(ID string) (*models.Node, error)
 	Save(node *models.Node) error
 	GetAll() (*[]models.Node, error)
-	GetActiveNodes(selection string) *[]models.Node
+	// GetActiveNodes returns active nodes whose circuit breaker lets requests through
+	// ordered by provided selection strategy
+	GetActiveNodes(strategy string) *[]models.Node
 	GetPenalizedNodes() (*[]models.Node, error)
+	// GetAllActiveNodes returns snapshot of active nodes, returned nodes must not be modified
 	GetAllActiveNodes() *[]models.Node
 	IsNodeActive(ID string) bool
 	RemoveNodeFromActive(ID string) error
 	AddNodeToActive(ID string) error
 	UpdateNodeUsed(node models.Node)
-	IncreaseNodeCooldown(ID string) (*models.Node, error)
+	// IncreaseNodeCooldown multiplies node cooldown by growth and saves it to db
+	IncreaseNodeCooldown(ID string, growth float64) (*models.Node, error)
 	ResetNodeCooldown(ID string) (*models.Node, error)
 	IsNodeOnCooldown(ID string) (bool, error)
+	// SubscribeToActiveNodes returns channel on which changes of active nodes are sent
+	// and function that cancels subscription
+	SubscribeToActiveNodes(buffer int) (<-chan ActiveChange, func())
 }
 
 type nodeRepo struct {
-	db *storm.DB
+	db     *storm.DB
+	active *ActiveRegistry
 }
 
 func NewNodeRepo(db *storm.DB) NodeRepository {
-	activeNodes = make([]models.Node, 0)
-
 	return &nodeRepo{
-		db: db,
+		db:     db,
+		active: NewActiveRegistry(),
 	}
 }
 
@@ -64,41 +68,36 @@ func (r *nodeRepo) GetAll() (*[]models.Node, error) {
 	return &nodes, err
 }
 
-func (r *nodeRepo) getRandomNodes() *[]models.Node {
-	nodes := make([]models.Node, len(activeNodes))
-
-	_ = copy(nodes[:], activeNodes)
-
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(nodes), func(i, j int) {
-		nodes[i], nodes[j] = nodes[j], nodes[i]
-	})
-
+func (r *nodeRepo) GetActiveNodes(strategy string) *[]models.Node {
+	nodes := selection.For(strategy).Select(allowedNodes(r.active.Snapshot()))
 	return &nodes
 }
 
-func (r *nodeRepo) getRoundRobinNodes() *[]models.Node {
-	nodes := make([]models.Node, len(activeNodes))
-
-	_ = copy(nodes[:], activeNodes)
-
-	sort.Slice(nodes[:], func(i, j int) bool {
-		return nodes[i].LastUsed < nodes[j].LastUsed
-	})
-
-	return &nodes
-}
-
-func (r *nodeRepo) GetActiveNodes(selection string) *[]models.Node {
-	if selection == "round-robin" {
-		return r.getRoundRobinNodes()
+// allowedNodes returns nodes whose circuit breaker lets requests through, if breakers
+// reject every node half open nodes are returned so request isn't failed while they recover
+func allowedNodes(nodes []models.Node) []models.Node {
+	if !breaker.IsEnabled() {
+		return nodes
 	}
-
-	return r.getRandomNodes()
+	now := time.Now()
+	allowed := make([]models.Node, 0, len(nodes))
+	var halfOpen []models.Node
+	for _, node := range nodes {
+		if breaker.Allow(node.ID, now) {
+			allowed = append(allowed, node)
+		} else if breaker.StateOf(node.ID) == breaker.HalfOpen {
+			halfOpen = append(halfOpen, node)
+		}
+	}
+	if len(allowed) == 0 {
+		return halfOpen
+	}
+	return allowed
 }
 
 func (r *nodeRepo) GetAllActiveNodes() *[]models.Node {
-	return &activeNodes
+	nodes := r.active.Snapshot()
+	return &nodes
 }
 
 func (r *nodeRepo) GetPenalizedNodes() (*[]models.Node, error) {
@@ -114,29 +113,8 @@ func (r *nodeRepo) GetPenalizedNodes() (*[]models.Node, error) {
 	return &nodes, err
 }
 
-func (r *nodeRepo) updateMemoryLastUsedTime(targetNode models.Node) {
-	// protect updating in memory activeNodes from concurrency problems
-	mutex.Lock()
-	for i, node := range activeNodes {
-		if targetNode.ID == node.ID {
-			tempNode := &activeNodes[i]
-			tempNode.LastUsed = time.Now().Unix()
-			break
-		}
-	}
-	mutex.Unlock()
-}
-
 func (r *nodeRepo) RemoveNodeFromActive(ID string) error {
-	for i, node := range activeNodes {
-		if ID == node.ID {
-			activeNodes[i] = activeNodes[len(activeNodes)-1]
-			activeNodes = activeNodes[:len(activeNodes)-1]
-			return nil
-		}
-	}
-
-	return fmt.Errorf("no target node %s in memory", ID)
+	return r.active.Remove(ID)
 }
 
 func (r *nodeRepo) AddNodeToActive(ID string) error {
@@ -145,43 +123,41 @@ func (r *nodeRepo) AddNodeToActive(ID string) error {
 	if err != nil {
 		return err
 	}
-	// check if already active
-	for _, activeNode := range activeNodes {
-		if activeNode.ID == ID {
-			return fmt.Errorf("node %s already set as active", ID)
-		}
+	if node.IsUnavailable() {
+		return fmt.Errorf("node %s is banned, draining or in maintenance", ID)
 	}
-
-	activeNodes = append(activeNodes, *node)
-	return nil
+	return r.active.Add(*node)
 }
 
 func (r *nodeRepo) UpdateNodeUsed(node models.Node) {
-	r.updateMemoryLastUsedTime(node)
+	now := time.Now().Unix()
+	r.active.Update(node.ID, func(activeNode *models.Node) {
+		activeNode.LastUsed = now
+	})
 
-	node.LastUsed = time.Now().Unix()
+	node.LastUsed = now
 	err := r.db.Update(&node)
 	if err != nil {
 		log.Errorf("Failed updating node last used time because of: %v", err)
 	}
 }
 
-// IncreaseNodeCooldown doubles node cooldown and saves it to db
-func (r *nodeRepo) IncreaseNodeCooldown(ID string) (*models.Node, error) {
+// IncreaseNodeCooldown multiplies node cooldown by growth and saves it to db
+func (r *nodeRepo) IncreaseNodeCooldown(ID string, growth float64) (*models.Node, error) {
 	var node models.Node
 	err := r.db.One("ID", ID, &node)
 	if err != nil {
 		return nil, err
 	}
 
-	newCooldown := 2 * node.Cooldown
+	newCooldown := int(math.Ceil(float64(node.Cooldown) * growth))
 	node.Cooldown = newCooldown
 
 	err = r.db.Save(&node)
 	return &node, err
 }
 
-// ResetNodeCooldown resets node cooldown to 0 and saves it to db
+// ResetNodeCooldown resets node cooldown to 0, ending its penalty, and saves it to db
 func (r *nodeRepo) ResetNodeCooldown(ID string) (*models.Node, error) {
 	var node models.Node
 	err := r.db.One("ID", ID, &node)
@@ -189,6 +165,10 @@ func (r *nodeRepo) ResetNodeCooldown(ID string) (*models.Node, error) {
 		return nil, err
 	}
 
+	if node.Cooldown != 0 {
+		// penalty level of node decays from end of penalty
+		node.LastPenalty = time.Now().Unix()
+	}
 	node.Cooldown = 0
 
 	err = r.db.Save(&node)
@@ -203,7 +183,7 @@ func (r *nodeRepo) IsNodeOnCooldown(ID string) (bool, error) {
 		return false, err
 	}
 
-	if !node.Active {
+	if !node.Active || node.IsUnavailable() {
 		return true, err
 	}
 
@@ -211,10 +191,9 @@ func (r *nodeRepo) IsNodeOnCooldown(ID string) (bool, error) {
 }
 
 func (r *nodeRepo) IsNodeActive(ID string) bool {
-	for _, node := range *r.GetAllActiveNodes() {
-		if node.ID == ID {
-			return true
-		}
-	}
-	return false
+	return r.active.Contains(ID)
+}
+
+func (r *nodeRepo) SubscribeToActiveNodes(buffer int) (<-chan ActiveChange, func()) {
+	return r.active.Subscribe(buffer)
 }
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/asdine/storm/v3"
+)
+
+type ProbeRepository interface {
+	// FindByNodeID returns latest probe of node
+	FindByNodeID(nodeID string) (*models.Probe, error)
+	Save(probe *models.Probe) error
+}
+
+type probeRepo struct {
+	db *storm.DB
+}
+
+func NewProbeRepo(db *storm.DB) ProbeRepository {
+	return &probeRepo{
+		db: db,
+	}
+}
+
+func (r *probeRepo) FindByNodeID(nodeID string) (*models.Probe, error) {
+	var probe models.Probe
+	err := r.db.One("NodeId", nodeID, &probe)
+	return &probe, err
+}
+
+func (r *probeRepo) Save(probe *models.Probe) error {
+	return r.db.Save(probe)
+}
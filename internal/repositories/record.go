@@ -15,6 +15,8 @@ type RecordRepository interface {
 	FindSuccessfulRecordsInsideInterval(nodeID string, from time.Time, to time.Time) ([]models.Record, error)
 	CountSuccessfulRequests() (int, error)
 	CountFailedRequests() (int, error)
+	// CountNotifications returns number of subscription notifications sent by nodes
+	CountNotifications() (int, error)
 }
 
 type recordRepo struct {
@@ -63,3 +65,14 @@ func (r *recordRepo) CountFailedRequests() (int, error) {
 
 	return len(records), err
 }
+
+func (r *recordRepo) CountNotifications() (int, error) {
+	var records []models.Record
+	q := r.db.Select(q.Eq("Status", "notification"))
+	err := q.Find(&records)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(records), err
+}
@@ -2,11 +2,15 @@ package repositories
 
 // Repos structure holds all available repositories
 type Repos struct {
-	NodeRepo     NodeRepository
-	PingRepo     PingRepository
-	MetricsRepo  MetricsRepository
-	RecordRepo   RecordRepository
-	DowntimeRepo DowntimeRepository
-	PayoutRepo   PayoutRepository
-	FeeRepo      FeeRepository
+	NodeRepo         NodeRepository
+	PingRepo         PingRepository
+	MetricsRepo      MetricsRepository
+	RecordRepo       RecordRepository
+	DowntimeRepo     DowntimeRepository
+	MaintenanceRepo  MaintenanceRepository
+	PayoutRepo       PayoutRepository
+	FeeRepo          FeeRepository
+	APIKeyRepo       APIKeyRepository
+	RevokedTokenRepo RevokedTokenRepository
+	ProbeRepo        ProbeRepository
 }
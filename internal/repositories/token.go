@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+)
+
+type RevokedTokenRepository interface {
+	Save(token *models.RevokedToken) error
+	GetAll() (*[]models.RevokedToken, error)
+	// DeleteExpired removes revoked tokens that expired before time
+	DeleteExpired(before time.Time) error
+}
+
+type revokedTokenRepo struct {
+	db *storm.DB
+}
+
+func NewRevokedTokenRepo(db *storm.DB) RevokedTokenRepository {
+	return &revokedTokenRepo{
+		db: db,
+	}
+}
+
+func (r *revokedTokenRepo) Save(token *models.RevokedToken) error {
+	return r.db.Save(token)
+}
+
+func (r *revokedTokenRepo) GetAll() (*[]models.RevokedToken, error) {
+	var tokens []models.RevokedToken
+	err := r.db.All(&tokens)
+	return &tokens, err
+}
+
+func (r *revokedTokenRepo) DeleteExpired(before time.Time) error {
+	err := r.db.Select(
+		q.Gt("ExpiresAt", int64(0)),
+		q.Lt("ExpiresAt", before.Unix()),
+	).Delete(&models.RevokedToken{})
+	if err != nil && err.Error() == "not found" {
+		return nil
+	}
+	return err
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/slok/go-http-metrics/middleware/std"
 
 	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
 	"github.com/NodeFactoryIo/vedran/internal/controllers"
 	"github.com/gorilla/mux"
 )
@@ -21,19 +22,41 @@ func createRoutes(apiController *controllers.ApiController, router *mux.Router,
 		Recorder: metrics.NewRecorder(metrics.Config{}),
 	})
 
-	createTrackedRoute("/", "POST", std.Handler("/", mdlw, http.HandlerFunc(apiController.RPCHandler)), router)
-	createTrackedRoute("/ws", "GET", std.Handler("/ws", mdlw, http.HandlerFunc(apiController.WSHandler)), router)
+	rpcHandler := customMiddleware.IPRateLimitMiddleware(
+		customMiddleware.APIKeyMiddleware(http.HandlerFunc(apiController.RPCHandler), true), true)
+	wsHandler := customMiddleware.IPRateLimitMiddleware(
+		customMiddleware.APIKeyMiddleware(http.HandlerFunc(apiController.WSHandler), false), false)
+	createTrackedRoute("/", "POST", std.Handler("/", mdlw, rpcHandler), router)
+	createTrackedRoute("/{key}", "POST", std.Handler("/", mdlw, rpcHandler), router)
+	createTrackedRoute("/ws", "GET", std.Handler("/ws", mdlw, wsHandler), router)
+	createTrackedRoute("/ws/{key}", "GET", std.Handler("/ws", mdlw, wsHandler), router)
 
 	createSignatureVerificationRoute("/api/v1/stats", "POST", apiController.StatisticsHandlerAllStatsForLoadbalancer, router, privateKey)
+	createSignatureVerificationRoute("/api/v1/keys", "POST", apiController.CreateAPIKeyHandler, router, privateKey)
+	createSignatureVerificationRoute("/api/v1/keys", "GET", apiController.GetAPIKeysHandler, router, privateKey)
+	createSignatureVerificationRoute("/api/v1/keys/{key}", "DELETE", apiController.RevokeAPIKeyHandler, router, privateKey)
+
+	// admin api is available only if admin token is set
+	if adminToken := configuration.Config.AdminToken; adminToken != "" {
+		createAdminRoute("/api/v1/admin/nodes", "GET", apiController.AdminGetNodesHandler, router, adminToken)
+		createAdminRoute("/api/v1/admin/nodes/{id}", "GET", apiController.AdminGetNodeHandler, router, adminToken)
+		createAdminRoute("/api/v1/admin/nodes/{id}/{action}", "POST", apiController.AdminNodeActionHandler, router, adminToken)
+	}
 
 	// authorized
 	createRoute("/api/v1/nodes/pings", "POST", apiController.PingHandler, router, true)
 	createRoute("/api/v1/nodes/metrics", "PUT", apiController.SaveMetricsHandler, router, true)
+	createRoute("/api/v1/nodes/maintenance", "POST", apiController.StartMaintenanceHandler, router, true)
+	createRoute("/api/v1/nodes/maintenance", "DELETE", apiController.EndMaintenanceHandler, router, true)
+	createRoute("/api/v1/nodes", "DELETE", apiController.DeregisterHandler, router, true)
+	createRoute("/api/v1/nodes/token", "POST", apiController.RefreshTokenHandler, router, true)
 	// unauthorized
+	createRoute("/api/v1/nodes/challenge", "POST", apiController.ChallengeHandler, router, false)
 	createRoute("/api/v1/nodes", "POST", apiController.RegisterHandler, router, false)
 	createRoute("/api/v1/stats", "GET", apiController.StatisticsHandlerAllStats, router, false)
 	createRoute("/api/v1/stats/node/{id}", "GET", apiController.StatisticsHandlerStatsForNode, router, false)
 	createRoute("/api/v1/stats/lb", "GET", apiController.StatisticsHandlerStatsForLoadBalancer, router, false)
+	createRoute("/api/v1/stats/keys/{key}", "GET", apiController.StatisticsHandlerStatsForAPIKey, router, false)
 	createRoute("/metrics", "GET", promhttp.Handler().ServeHTTP, router, false)
 }
 
@@ -63,6 +86,13 @@ func createSignatureVerificationRoute(
 	setUpRoute(route, method, r)
 }
 
+func createAdminRoute(
+	route string, method string, handler http.HandlerFunc, router *mux.Router, adminToken string,
+) {
+	r := router.Handle(route, customMiddleware.AdminMiddleware(handler, adminToken))
+	setUpRoute(route, method, r)
+}
+
 func setUpRoute(route string, method string, r *mux.Route) {
 	r.Methods(method)
 	r.Name(route)
@@ -5,6 +5,7 @@ import (
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	"testing"
 
+	"github.com/NodeFactoryIo/vedran/internal/configuration"
 	"github.com/NodeFactoryIo/vedran/internal/controllers"
 	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
 	"github.com/gorilla/mux"
@@ -33,10 +34,21 @@ func TestApiRouter(t *testing.T) {
 		methods []string
 	}{
 		{name: "Test register route", url: "/api/v1/nodes", methods: []string{"POST"}},
+		{name: "Test challenge route", url: "/api/v1/nodes/challenge", methods: []string{"POST"}},
 		{name: "Test ping route", url: "/api/v1/nodes/pings", methods: []string{"POST"}},
 		{name: "Test metrics route", url: "/api/v1/nodes/metrics", methods: []string{"PUT"}},
+		{name: "Test end maintenance route", url: "/api/v1/nodes/maintenance", methods: []string{"DELETE"}},
+		{name: "Test refresh token route", url: "/api/v1/nodes/token", methods: []string{"POST"}},
+		{name: "Test rpc route with api key", url: "/{key}", methods: []string{"POST"}},
+		{name: "Test ws route with api key", url: "/ws/{key}", methods: []string{"GET"}},
+		{name: "Test revoke api key route", url: "/api/v1/keys/{key}", methods: []string{"DELETE"}},
+		{name: "Test api key stats route", url: "/api/v1/stats/keys/{key}", methods: []string{"GET"}},
+		{name: "Test admin nodes route", url: "/api/v1/admin/nodes", methods: []string{"GET"}},
+		{name: "Test admin node action route", url: "/api/v1/admin/nodes/{id}/{action}", methods: []string{"POST"}},
 	}
 
+	configuration.Config.AdminToken = "admin-token"
+	defer func() { configuration.Config.AdminToken = "" }()
 	router := mux.NewRouter()
 	createRoutes(apiController, router, "")
 
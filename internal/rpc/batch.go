@@ -0,0 +1,125 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// BatchEntryResponse is single response from batch rpc response with raw
+// response preserved so it can be returned to client unchanged
+type BatchEntryResponse struct {
+	Raw   json.RawMessage
+	ID    json.RawMessage
+	Error *RPCError
+}
+
+// IDKey returns key used for pairing requests with responses, ids are compared
+// in compact form so formatting differences are ignored
+func IDKey(id json.RawMessage) string {
+	if id == nil {
+		return "null"
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, id); err != nil {
+		return string(id)
+	}
+	return compact.String()
+}
+
+// BatchSize returns number of entries in batch request, single request and
+// request that can't be parsed count as one entry
+func BatchSize(body []byte) int {
+	if !IsBatch(body) {
+		return 1
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil || len(raws) == 0 {
+		return 1
+	}
+	return len(raws)
+}
+
+// HasDuplicateIDs returns true if multiple requests in batch use same id, notifications
+// are not checked as they don't have response
+func HasDuplicateIDs(reqs []RPCRequest) bool {
+	ids := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		if req.IsNotification() {
+			continue
+		}
+		key := IDKey(req.ID)
+		if ids[key] {
+			return true
+		}
+		ids[key] = true
+	}
+	return false
+}
+
+// SplitBatch splits batch requests into chunks containing at most size requests
+func SplitBatch(reqs []RPCRequest, size int) [][]RPCRequest {
+	if size <= 0 || len(reqs) <= size {
+		return [][]RPCRequest{reqs}
+	}
+
+	chunks := make([][]RPCRequest, 0, (len(reqs)+size-1)/size)
+	for start := 0; start < len(reqs); start += size {
+		end := start + size
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		chunks = append(chunks, reqs[start:end])
+	}
+	return chunks
+}
+
+// ParseBatchResponse parses batch rpc response into responses mapped by request id key
+func ParseBatchResponse(body []byte) (map[string]BatchEntryResponse, error) {
+	var raws []json.RawMessage
+	// body is empty if all requests in batch were notifications
+	if len(bytes.TrimSpace(body)) != 0 {
+		err := json.Unmarshal(body, &raws)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make(map[string]BatchEntryResponse, len(raws))
+	for _, raw := range raws {
+		var response RPCResponse
+		err := json.Unmarshal(raw, &response)
+		if err != nil {
+			return nil, err
+		}
+		responses[IDKey(response.ID)] = BatchEntryResponse{
+			Raw:   raw,
+			ID:    response.ID,
+			Error: response.Error,
+		}
+	}
+	return responses, nil
+}
+
+// MergeBatchResponses creates batch rpc response with responses ordered as requests,
+// requests without response get internal server error response and notifications are skipped
+func MergeBatchResponses(reqs []RPCRequest, responses map[string]BatchEntryResponse) ([]byte, error) {
+	merged := make([]json.RawMessage, 0, len(reqs))
+	for _, req := range reqs {
+		if req.IsNotification() {
+			continue
+		}
+		if response, ok := responses[IDKey(req.ID)]; ok {
+			merged = append(merged, response.Raw)
+			continue
+		}
+		rpcError, err := json.Marshal(createSingleRPCError(req.ID, InternalServerError, "Internal Server Error"))
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, rpcError)
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(merged)
+}
@@ -0,0 +1,84 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitBatch(t *testing.T) {
+	reqs := []RPCRequest{{ID: json.RawMessage("1")}, {ID: json.RawMessage("2")}, {ID: json.RawMessage("3")}, {ID: json.RawMessage("4")}, {ID: json.RawMessage("5")}}
+
+	tests := []struct {
+		name string
+		size int
+		want [][]RPCRequest
+	}{
+		{name: "splitting disabled", size: 0, want: [][]RPCRequest{reqs}},
+		{name: "batch smaller than chunk", size: 10, want: [][]RPCRequest{reqs}},
+		{name: "uneven chunks", size: 2, want: [][]RPCRequest{{{ID: json.RawMessage("1")}, {ID: json.RawMessage("2")}}, {{ID: json.RawMessage("3")}, {ID: json.RawMessage("4")}}, {{ID: json.RawMessage("5")}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, SplitBatch(reqs, test.size))
+		})
+	}
+}
+
+func TestBatchSize(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "single request", body: `{"jsonrpc":"2.0","id":1,"method":"system_health"}`, want: 1},
+		{name: "batch request", body: `[{"jsonrpc":"2.0","id":1,"method":"system_health"},{"jsonrpc":"2.0","id":2,"method":"system_health"}]`, want: 2},
+		{name: "empty batch", body: `[]`, want: 1},
+		{name: "invalid batch", body: `[{"jsonrpc":`, want: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, BatchSize([]byte(test.body)))
+		})
+	}
+}
+
+func TestHasDuplicateIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		reqs []RPCRequest
+		want bool
+	}{
+		{name: "unique ids", reqs: []RPCRequest{{ID: json.RawMessage("1")}, {ID: json.RawMessage(`"1"`)}}, want: false},
+		{name: "duplicate ids", reqs: []RPCRequest{{ID: json.RawMessage("1")}, {ID: json.RawMessage("2")}, {ID: json.RawMessage(" 1")}}, want: true},
+		{name: "notifications", reqs: []RPCRequest{{}, {}}, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, HasDuplicateIDs(test.reqs))
+		})
+	}
+}
+
+func TestParseAndMergeBatchResponses(t *testing.T) {
+	responses, err := ParseBatchResponse([]byte(
+		`[{"jsonrpc":"2.0","id":3,"result":"0x3"},{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"err"}}]`))
+	assert.NoError(t, err)
+	assert.Len(t, responses, 2)
+	assert.Equal(t, InternalServerError, responses["1"].Error.Code)
+	assert.Nil(t, responses["3"].Error)
+
+	_, err = ParseBatchResponse([]byte(`{}`))
+	assert.Error(t, err)
+
+	merged, err := MergeBatchResponses([]RPCRequest{{ID: json.RawMessage("1")}, {ID: json.RawMessage("2")}, {ID: json.RawMessage("3")}}, responses)
+	assert.NoError(t, err)
+
+	var result []RPCResponse
+	_ = json.Unmarshal(merged, &result)
+	assert.Equal(t, []string{"1", "2", "3"}, []string{IDKey(result[0].ID), IDKey(result[1].ID), IDKey(result[2].ID)})
+	assert.Equal(t, "err", result[0].Error.Message)
+	assert.Equal(t, InternalServerError, result[1].Error.Code)
+	assert.Equal(t, `"0x3"`, string(*result[2].Result))
+}
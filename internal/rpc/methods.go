@@ -0,0 +1,95 @@
+package rpc
+
+import "strings"
+
+// readOnlyPrefixes are namespaces of methods that only read chain or node state
+var readOnlyPrefixes = []string{
+	"chain_",
+	"state_",
+	"childstate_",
+	"system_",
+	"payment_",
+	"rpc_",
+	"grandpa_",
+	"babe_",
+	"beefy_",
+	"mmr_",
+}
+
+// mutatingMethods are methods from read only namespaces that change node state
+var mutatingMethods = map[string]bool{
+	"system_addReservedPeer":    true,
+	"system_removeReservedPeer": true,
+	"system_addLogFilter":       true,
+	"system_resetLogFilter":     true,
+}
+
+// IsReadOnlyMethod checks if method only reads state and can be safely sent to
+// multiple nodes, subscriptions are not considered read only
+func IsReadOnlyMethod(method string) bool {
+	if mutatingMethods[method] || strings.Contains(strings.ToLower(method), "subscribe") {
+		return false
+	}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeLocalPrefixes are namespaces of methods whose answer depends on node that serves
+// request, such as its peers, name or pending extrinsics, rather than on chain state
+var nodeLocalPrefixes = []string{
+	"system_",
+	"author_",
+	"offchain_",
+	"rpc_",
+}
+
+// IsNodeLocalMethod checks if answer to method differs between honest nodes
+func IsNodeLocalMethod(method string) bool {
+	for _, prefix := range nodeLocalPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastMethods are methods that can be sent to multiple nodes at once when broadcast is enabled
+var broadcastMethods = map[string]bool{
+	"author_submitExtrinsic": true,
+}
+
+// IsBroadcastMethod checks if request submits extrinsic that can be broadcast to multiple nodes
+func IsBroadcastMethod(method string) bool {
+	return broadcastMethods[method]
+}
+
+// nonIdempotentMethods are methods that change node or chain state, so repeating them
+// on other node can change outcome (e.g. submitting same extrinsic twice)
+var nonIdempotentMethods = map[string]bool{
+	"author_submitExtrinsic":         true,
+	"author_submitAndWatchExtrinsic": true,
+	"author_insertKey":               true,
+	"author_rotateKeys":              true,
+	"author_removeExtrinsic":         true,
+	"offchain_localStorageSet":       true,
+}
+
+// IsIdempotentMethod checks if request can be repeated on other node without changing outcome
+// if node fails after it possibly processed request
+func IsIdempotentMethod(method string) bool {
+	return !nonIdempotentMethods[method] && !mutatingMethods[method]
+}
+
+// IsIdempotentBatch checks if all requests in batch are idempotent
+func IsIdempotentBatch(reqs []RPCRequest) bool {
+	for _, req := range reqs {
+		if !IsIdempotentMethod(req.Method) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIdempotentMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{method: "chain_getBlock", want: true},
+		{method: "author_pendingExtrinsics", want: true},
+		{method: "unknown_method", want: true},
+		{method: "author_submitExtrinsic", want: false},
+		{method: "author_submitAndWatchExtrinsic", want: false},
+		{method: "system_addReservedPeer", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			assert.Equal(t, test.want, IsIdempotentMethod(test.method))
+		})
+	}
+
+	assert.True(t, IsIdempotentBatch([]RPCRequest{{ID: json.RawMessage("1"), Method: "chain_getBlock"}}))
+	assert.False(t, IsIdempotentBatch([]RPCRequest{
+		{ID: json.RawMessage("1"), Method: "chain_getBlock"},
+		{ID: json.RawMessage("2"), Method: "author_submitExtrinsic"},
+	}))
+}
+
+func TestIsNodeLocalMethod(t *testing.T) {
+	assert.True(t, IsNodeLocalMethod("system_health"))
+	assert.True(t, IsNodeLocalMethod("author_pendingExtrinsics"))
+	assert.False(t, IsNodeLocalMethod("chain_getBlockHash"))
+	assert.False(t, IsNodeLocalMethod("state_getStorage"))
+}
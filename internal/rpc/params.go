@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// blockHashParamIndex maps methods that accept block hash to position of block hash inside params
+var blockHashParamIndex = map[string]int{
+	"chain_getBlock":            0,
+	"chain_getHeader":           0,
+	"state_getMetadata":         0,
+	"state_getRuntimeVersion":   0,
+	"chain_getRuntimeVersion":   0,
+	"state_getStorage":          1,
+	"state_getStorageAt":        1,
+	"state_getStorageHash":      1,
+	"state_getStorageHashAt":    1,
+	"state_getStorageSize":      1,
+	"state_getStorageSizeAt":    1,
+	"state_getKeys":             1,
+	"state_getPairs":            1,
+	"state_queryStorageAt":      1,
+	"state_getReadProof":        1,
+	"payment_queryInfo":         1,
+	"state_call":                2,
+	"state_callAt":              2,
+	"childstate_getStorage":     2,
+	"childstate_getStorageHash": 2,
+	"childstate_getStorageSize": 2,
+	"childstate_getKeys":        2,
+}
+
+// BlockHashParam returns block hash explicitly referenced by request
+func BlockHashParam(req RPCRequest) (string, bool) {
+	index, ok := blockHashParamIndex[req.Method]
+	if !ok {
+		return "", false
+	}
+	params, ok := req.Params.([]interface{})
+	if !ok || len(params) <= index || !isBlockHash(params[index]) {
+		return "", false
+	}
+	return params[index].(string), true
+}
+
+// BlockNumberParam returns block number requested by chain_getBlockHash request
+func BlockNumberParam(req RPCRequest) (int64, bool) {
+	if req.Method != "chain_getBlockHash" {
+		return 0, false
+	}
+	p, ok := req.Params.([]interface{})
+	if !ok || len(p) == 0 {
+		return 0, false
+	}
+
+	switch n := p[0].(type) {
+	case float64:
+		if n < 0 || n != float64(int64(n)) {
+			return 0, false
+		}
+		return int64(n), true
+	case string:
+		if strings.HasPrefix(n, "0x") {
+			v, err := strconv.ParseInt(strings.TrimPrefix(n, "0x"), 16, 64)
+			return v, err == nil && v >= 0
+		}
+		v, err := strconv.ParseInt(n, 10, 64)
+		return v, err == nil && v >= 0
+	default:
+		return 0, false
+	}
+}
+
+// isBlockHash checks if param is 32 byte hex encoded hash
+func isBlockHash(param interface{}) bool {
+	hash, ok := param.(string)
+	if !ok || len(hash) != 66 || !strings.HasPrefix(hash, "0x") {
+		return false
+	}
+	for _, c := range hash[2:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockNumberParam(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    RPCRequest
+		want   int64
+		wantOk bool
+	}{
+		{name: "number", req: RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(100)}}, want: 100, wantOk: true},
+		{name: "hex", req: RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{"0x64"}}, want: 100, wantOk: true},
+		{name: "decimal string", req: RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{"100"}}, want: 100, wantOk: true},
+		{name: "negative number", req: RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{float64(-1)}}},
+		{name: "negative hex", req: RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{"0x-1"}}},
+		{name: "negative decimal string", req: RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{"-1"}}},
+		{name: "latest block", req: RPCRequest{Method: "chain_getBlockHash", Params: []interface{}{}}},
+		{name: "other method", req: RPCRequest{Method: "chain_getBlock", Params: []interface{}{float64(100)}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			number, ok := BlockNumberParam(test.req)
+			assert.Equal(t, test.wantOk, ok)
+			if test.wantOk {
+				assert.Equal(t, test.want, number)
+			}
+		})
+	}
+}
@@ -2,9 +2,12 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -19,22 +22,63 @@ type RPCError struct {
 
 type RPCResponse struct {
 	JSONRPC string           `json:"jsonrpc"`
-	ID      uint64           `json:"id"`
+	ID      json.RawMessage  `json:"id"`
 	Result  *json.RawMessage `json:"result,omitempty"`
 	Error   *RPCError        `json:"error,omitempty"`
 }
 
+// RPCRequest is JSON-RPC 2.0 request, id is kept as raw message so it is returned
+// to client exactly as received. Id is nil for notifications
 type RPCRequest struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      uint64      `json:"id"`
-	Method  string      `json:"method,omitempty"`
-	Params  interface{} `json:"params,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+}
+
+// IsNotification returns true if request has no id and client doesn't expect response
+func (r RPCRequest) IsNotification() bool {
+	return r.ID == nil
+}
+
+// Validate checks if request is valid JSON-RPC 2.0 request
+func (r RPCRequest) Validate() error {
+	if r.JSONRPC != "2.0" {
+		return errors.New("invalid jsonrpc version")
+	}
+	if r.Method == "" {
+		return errors.New("missing method")
+	}
+	if !isValidID(r.ID) {
+		return errors.New("invalid id")
+	}
+	return nil
+}
+
+// isValidID checks if id is string, number or null as required by JSON-RPC 2.0
+func isValidID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	var value interface{}
+	if err := json.Unmarshal(id, &value); err != nil {
+		return false
+	}
+	switch value.(type) {
+	case nil, string, float64:
+		return true
+	default:
+		return false
+	}
 }
 
 const (
 	InternalServerError = -32603
 	ParseError          = -32700
 	InvalidRequest      = -32600
+	MethodNotFound      = -32601
+	LimitExceeded       = -32005
+	AlreadyImported     = 1013
 
 	RequestTimeout = 3 * time.Second
 )
@@ -49,7 +93,7 @@ func IsBatch(reqBody []byte) bool {
 	return false
 }
 
-func createSingleRPCError(id uint64, code int, message string) RPCResponse {
+func createSingleRPCError(id json.RawMessage, code int, message string) RPCResponse {
 	return RPCResponse{
 		ID: id,
 		Error: &RPCError{
@@ -60,19 +104,102 @@ func createSingleRPCError(id uint64, code int, message string) RPCResponse {
 	}
 }
 
+// CreateInvalidRequestError returns invalid request error, id of request is used only if it is valid
+func CreateInvalidRequestError(req RPCRequest) RPCResponse {
+	id := req.ID
+	if !isValidID(id) {
+		id = nil
+	}
+	return createSingleRPCError(id, InvalidRequest, "Invalid Request")
+}
+
+// ParseRequest parses and validates single rpc request, error response is returned if request
+// is not valid json or valid rpc request
+func ParseRequest(body []byte) (RPCRequest, *RPCResponse) {
+	var req RPCRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		var object map[string]json.RawMessage
+		if json.Unmarshal(body, &object) != nil {
+			response := createSingleRPCError(nil, ParseError, "Parse error")
+			return RPCRequest{}, &response
+		}
+		// valid json object with fields of invalid type
+		response := CreateInvalidRequestError(RPCRequest{ID: object["id"]})
+		return RPCRequest{}, &response
+	}
+	if err := req.Validate(); err != nil {
+		response := CreateInvalidRequestError(req)
+		return RPCRequest{}, &response
+	}
+	return req, nil
+}
+
+// ParseBatchRequest parses and validates batch rpc request, for batch entries that are not valid
+// rpc requests error responses are returned. If body is not valid json or batch is empty single
+// error response is returned
+func ParseBatchRequest(body []byte) ([]RPCRequest, []RPCResponse, *RPCResponse) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil {
+		response := createSingleRPCError(nil, ParseError, "Parse error")
+		return nil, nil, &response
+	}
+	if len(raws) == 0 {
+		response := createSingleRPCError(nil, InvalidRequest, "Invalid Request")
+		return nil, nil, &response
+	}
+
+	reqs := make([]RPCRequest, 0, len(raws))
+	var invalid []RPCResponse
+	for _, raw := range raws {
+		req, errResponse := ParseRequest(raw)
+		if errResponse != nil {
+			// entries are valid json so parse error means entry is not an object
+			errResponse.Error = &RPCError{Code: InvalidRequest, Message: "Invalid Request"}
+			invalid = append(invalid, *errResponse)
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, invalid, nil
+}
+
 // CreateRPCError returns rpc errors for appropriate request ids
 func CreateRPCError(isBatch bool, reqRPCBody RPCRequest, reqRPCBodies []RPCRequest, code int, message string) interface{} {
 	if !isBatch {
 		return createSingleRPCError(reqRPCBody.ID, code, message)
 	}
 
-	rpcResponses := make([]RPCResponse, len(reqRPCBodies))
-	for i, body := range reqRPCBodies {
-		rpcResponses[i] = createSingleRPCError(body.ID, code, message)
+	rpcResponses := make([]RPCResponse, 0, len(reqRPCBodies))
+	for _, body := range reqRPCBodies {
+		if !body.IsNotification() {
+			rpcResponses = append(rpcResponses, createSingleRPCError(body.ID, code, message))
+		}
 	}
 	return rpcResponses
 }
 
+// AppendBatchResponses appends responses to body of batch rpc response
+func AppendBatchResponses(body []byte, responses []RPCResponse) ([]byte, error) {
+	var batch []json.RawMessage
+	// body is empty if all forwarded requests were notifications
+	if len(bytes.TrimSpace(body)) != 0 {
+		err := json.Unmarshal(body, &batch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, response := range responses {
+		r, err := json.Marshal(response)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, r)
+	}
+
+	return json.Marshal(batch)
+}
+
 // CheckSingleRPCResponse checks for errors in non batch rpc response
 func CheckSingleRPCResponse(body []byte) (RPCResponse, error) {
 	var rpcResponse RPCResponse
@@ -101,23 +228,59 @@ func CheckBatchRPCResponse(body []byte) ([]RPCResponse, error) {
 	return rpcResponses, nil
 }
 
+// NotSentError is returned if request failed before it was sent to node, so request
+// can be safely sent to other node regardless of method
+type NotSentError struct {
+	Err error
+}
+
+func (e *NotSentError) Error() string {
+	return fmt.Sprintf("request not sent to node: %v", e.Err)
+}
+
+func (e *NotSentError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotSent checks if error means that request didn't reach node
+func IsNotSent(err error) bool {
+	var notSentError *NotSentError
+	return errors.As(err, &notSentError)
+}
+
 // SendRequestToNode routes request to node and checks response
 func SendRequestToNode(isBatch bool, nodeID string, reqBody []byte) ([]byte, error) {
+	return SendRequestToNodeWithContext(context.Background(), isBatch, nodeID, reqBody)
+}
+
+// SendRequestToNodeWithContext routes request to node and checks response, request
+// is aborted if provided context is canceled
+func SendRequestToNodeWithContext(ctx context.Context, isBatch bool, nodeID string, reqBody []byte) ([]byte, error) {
 	port, err := configuration.Config.PortPool.GetHTTPPort(nodeID)
 	if err != nil {
-		return nil, err
+		return nil, &NotSentError{Err: err}
 	}
 
 	client := http.Client{
 		Timeout: RequestTimeout,
 	}
-	resp, err := client.Post(
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
 		"http://127.0.0.1:"+strconv.Itoa(port)+"/",
-		"application/json",
 		bytes.NewBuffer(reqBody),
 	)
 	if err != nil {
 		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		var opError *net.OpError
+		if errors.As(err, &opError) && opError.Op == "dial" {
+			return nil, &NotSentError{Err: err}
+		}
+		return nil, err
 	} else if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("Status code is not 200")
 	}
@@ -128,15 +291,66 @@ func SendRequestToNode(isBatch bool, nodeID string, reqBody []byte) ([]byte, err
 		return nil, err
 	}
 
+	err = checkResponse(isBatch, reqBody, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// checkResponse checks node response and verifies that ids of responses match ids of requests.
+// Node doesn't have to respond if all requests are notifications
+func checkResponse(isBatch bool, reqBody []byte, body []byte) error {
+	var reqs []RPCRequest
 	if isBatch {
-		_, err = CheckBatchRPCResponse(body)
+		_ = json.Unmarshal(reqBody, &reqs)
 	} else {
-		_, err = CheckSingleRPCResponse(body)
+		var req RPCRequest
+		_ = json.Unmarshal(reqBody, &req)
+		reqs = []RPCRequest{req}
 	}
 
-	if err != nil {
-		return nil, err
+	expected := make(map[string]int)
+	for _, req := range reqs {
+		if !req.IsNotification() {
+			expected[IDKey(req.ID)]++
+		}
+	}
+	if len(expected) == 0 && len(bytes.TrimSpace(body)) == 0 {
+		return nil
 	}
 
-	return body, nil
+	var responses []RPCResponse
+	if isBatch {
+		batch, err := CheckBatchRPCResponse(body)
+		if err != nil {
+			return err
+		}
+		responses = batch
+	} else {
+		response, err := CheckSingleRPCResponse(body)
+		if err != nil {
+			return err
+		}
+		if len(expected) == 0 {
+			// response to notification is not returned to client
+			return nil
+		}
+		responses = []RPCResponse{response}
+	}
+
+	for _, response := range responses {
+		key := IDKey(response.ID)
+		if expected[key] == 0 {
+			return fmt.Errorf("response id %s doesn't match any request id", key)
+		}
+		expected[key]--
+	}
+	for key, count := range expected {
+		if count != 0 {
+			return fmt.Errorf("missing response for request id %s", key)
+		}
+	}
+	return nil
 }
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -193,12 +194,12 @@ func TestCreateRPCError(t *testing.T) {
 	}{
 		{
 			name: "Returns single error if it is not batch",
-			args: args{false, RPCRequest{ID: 3}, []RPCRequest{}, -32300, "Error"},
-			want: RPCResponse{JSONRPC: "2.0", ID: 3, Error: &RPCError{Code: -32300, Message: "Error"}}},
+			args: args{false, RPCRequest{ID: json.RawMessage("3")}, []RPCRequest{}, -32300, "Error"},
+			want: RPCResponse{JSONRPC: "2.0", ID: json.RawMessage("3"), Error: &RPCError{Code: -32300, Message: "Error"}}},
 		{
 			name: "Returns array of errors if they are batch",
-			args: args{true, RPCRequest{}, []RPCRequest{{ID: 3}}, -32300, "Error"},
-			want: []RPCResponse{{JSONRPC: "2.0", ID: 3, Error: &RPCError{Code: -32300, Message: "Error"}}}},
+			args: args{true, RPCRequest{}, []RPCRequest{{ID: json.RawMessage("3")}}, -32300, "Error"},
+			want: []RPCResponse{{JSONRPC: "2.0", ID: json.RawMessage("3"), Error: &RPCError{Code: -32300, Message: "Error"}}}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -234,7 +235,7 @@ func TestCheckSingleRPCResponse(t *testing.T) {
 		{
 			name:    "Returns rpc response if valid",
 			args:    args{[]byte(`{"id": 1}`)},
-			want:    RPCResponse{ID: 1},
+			want:    RPCResponse{ID: json.RawMessage("1")},
 			wantErr: false},
 	}
 	for _, tt := range tests {
@@ -269,7 +270,7 @@ func TestCheckBatchRPCResponse(t *testing.T) {
 		{
 			name:    "Returns rpc response if valid",
 			args:    args{[]byte(`[{"id": 1}]`)},
-			want:    []RPCResponse{{ID: 1}},
+			want:    []RPCResponse{{ID: json.RawMessage("1")}},
 			wantErr: false},
 	}
 	for _, tt := range tests {
@@ -285,3 +286,125 @@ func TestCheckBatchRPCResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantID    json.RawMessage
+		wantError *RPCResponse
+	}{
+		{
+			name:   "Preserves numeric id",
+			body:   `{"jsonrpc": "2.0", "id": 1, "method": "system_health"}`,
+			wantID: json.RawMessage("1"),
+		},
+		{
+			name:   "Preserves string id",
+			body:   `{"jsonrpc": "2.0", "id": "abc-1", "method": "system_health"}`,
+			wantID: json.RawMessage(`"abc-1"`),
+		},
+		{
+			name:   "Preserves null id",
+			body:   `{"jsonrpc": "2.0", "id": null, "method": "system_health"}`,
+			wantID: json.RawMessage("null"),
+		},
+		{
+			name:   "Request without id is notification",
+			body:   `{"jsonrpc": "2.0", "method": "system_health"}`,
+			wantID: nil,
+		},
+		{
+			name:      "Returns parse error for invalid json",
+			body:      `{"jsonrpc": "2.0", "method"`,
+			wantError: &RPCResponse{JSONRPC: "2.0", Error: &RPCError{Code: ParseError, Message: "Parse error"}},
+		},
+		{
+			name:      "Returns invalid request error for invalid version",
+			body:      `{"jsonrpc": "1.0", "id": "a", "method": "system_health"}`,
+			wantError: &RPCResponse{JSONRPC: "2.0", ID: json.RawMessage(`"a"`), Error: &RPCError{Code: InvalidRequest, Message: "Invalid Request"}},
+		},
+		{
+			name:      "Returns invalid request error for missing method",
+			body:      `{"jsonrpc": "2.0", "id": 2}`,
+			wantError: &RPCResponse{JSONRPC: "2.0", ID: json.RawMessage("2"), Error: &RPCError{Code: InvalidRequest, Message: "Invalid Request"}},
+		},
+		{
+			name:      "Returns invalid request error with null id for invalid id",
+			body:      `{"jsonrpc": "2.0", "id": {"a": 1}, "method": "system_health"}`,
+			wantError: &RPCResponse{JSONRPC: "2.0", Error: &RPCError{Code: InvalidRequest, Message: "Invalid Request"}},
+		},
+		{
+			name:      "Returns invalid request error for method of invalid type",
+			body:      `{"jsonrpc": "2.0", "id": 3, "method": 1}`,
+			wantError: &RPCResponse{JSONRPC: "2.0", ID: json.RawMessage("3"), Error: &RPCError{Code: InvalidRequest, Message: "Invalid Request"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, errResponse := ParseRequest([]byte(tt.body))
+			if !reflect.DeepEqual(errResponse, tt.wantError) {
+				t.Errorf("ParseRequest() error = %v, want %v", errResponse, tt.wantError)
+				return
+			}
+			if tt.wantError == nil && !reflect.DeepEqual(req.ID, tt.wantID) {
+				t.Errorf("ParseRequest() id = %s, want %s", req.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestParseBatchRequest(t *testing.T) {
+	reqs, invalid, errResponse := ParseBatchRequest([]byte(
+		`[{"jsonrpc": "2.0", "id": "a", "method": "system_health"}, 1, {"jsonrpc": "2.0", "method": "system_health"}, {"id": 4}]`))
+	if errResponse != nil {
+		t.Fatalf("ParseBatchRequest() unexpected error %v", errResponse)
+	}
+	if len(reqs) != 2 || !reqs[1].IsNotification() {
+		t.Errorf("ParseBatchRequest() requests = %v", reqs)
+	}
+	wantInvalid := []RPCResponse{
+		{JSONRPC: "2.0", Error: &RPCError{Code: InvalidRequest, Message: "Invalid Request"}},
+		{JSONRPC: "2.0", ID: json.RawMessage("4"), Error: &RPCError{Code: InvalidRequest, Message: "Invalid Request"}},
+	}
+	if !reflect.DeepEqual(invalid, wantInvalid) {
+		t.Errorf("ParseBatchRequest() invalid = %v, want %v", invalid, wantInvalid)
+	}
+
+	_, _, errResponse = ParseBatchRequest([]byte(`[]`))
+	if errResponse == nil || errResponse.Error.Code != InvalidRequest {
+		t.Errorf("ParseBatchRequest() empty batch error = %v", errResponse)
+	}
+	_, _, errResponse = ParseBatchRequest([]byte(`[{"jsonrpc": "2.0"`))
+	if errResponse == nil || errResponse.Error.Code != ParseError {
+		t.Errorf("ParseBatchRequest() invalid json error = %v", errResponse)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		isBatch bool
+		reqBody string
+		body    string
+		wantErr bool
+	}{
+		{name: "matching string id", reqBody: `{"id": "a"}`, body: `{"id": "a"}`},
+		{name: "mismatched id", reqBody: `{"id": "a"}`, body: `{"id": "b"}`, wantErr: true},
+		{name: "numeric id doesn't match string id", reqBody: `{"id": 1}`, body: `{"id": "1"}`, wantErr: true},
+		{name: "empty response to notification", reqBody: `{"method": "a"}`, body: ``},
+		{name: "batch with matching ids", isBatch: true, reqBody: `[{"id": 1}, {"id": "b"}]`, body: `[{"id": "b"}, {"id": 1}]`},
+		{name: "batch with missing response", isBatch: true, reqBody: `[{"id": 1}, {"id": 2}]`, body: `[{"id": 1}]`, wantErr: true},
+		{name: "batch with unknown id", isBatch: true, reqBody: `[{"id": 1}]`, body: `[{"id": 1}, {"id": 3}]`, wantErr: true},
+		{name: "batch with notification", isBatch: true, reqBody: `[{"id": 1}, {"method": "a"}]`, body: `[{"id": 1}]`},
+		{name: "batch of notifications", isBatch: true, reqBody: `[{"method": "a"}]`, body: ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkResponse(tt.isBatch, []byte(tt.reqBody), []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/NodeFactoryIo/vedran/internal/actions"
 	"github.com/NodeFactoryIo/vedran/internal/active"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	log "github.com/sirupsen/logrus"
 )
@@ -44,7 +45,29 @@ func scheduledTask(repos *repositories.Repos, actions actions.Actions) {
 		}
 
 		if !pingActive {
-			actions.PenalizeNode(node, *repos, "inactive ping")
+			actions.PenalizeNode(node, *repos, penalty.InactivePing)
+			continue
+		}
+
+		probeSuccessful, err := active.CheckIfProbeSuccessful(node.ID, repos)
+		if err != nil {
+			log.Errorf("Unable to check if node %s active because of %v", node.ID, err)
+			continue
+		}
+
+		if !probeSuccessful {
+			actions.PenalizeNode(node, *repos, penalty.FailedProbe)
+			continue
+		}
+
+		metricsMatchingProbe, err := active.CheckIfMetricsMatchProbe(node.ID, repos)
+		if err != nil {
+			log.Errorf("Unable to check if node %s active because of %v", node.ID, err)
+			continue
+		}
+
+		if !metricsMatchingProbe {
+			actions.PenalizeNode(node, *repos, penalty.MetricsDivergence)
 			continue
 		}
 
@@ -1,7 +1,10 @@
 package checkactive
 
 import (
+	"errors"
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
+	"github.com/NodeFactoryIo/vedran/internal/probe"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	actionMocks "github.com/NodeFactoryIo/vedran/mocks/actions"
 	repoMocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
@@ -303,3 +306,76 @@ func Test_scheduledTask(t *testing.T) {
 		})
 	}
 }
+
+func Test_scheduledTaskWithProbing(t *testing.T) {
+	probe.InitProbing(probe.DefaultProbeInterval, probe.DefaultDivergenceTolerance)
+	defer probe.InitProbing(0, 0)
+
+	tests := []struct {
+		name          string
+		probe         *models.Probe
+		probeError    error
+		penaltyReason string
+	}{
+		{
+			name:  "probe matches metrics",
+			probe: &models.Probe{NodeId: "1", BestBlockHeight: 1002, FinalizedBlockHeight: 998, Timestamp: time.Now()},
+		},
+		{
+			name:       "node not probed yet",
+			probeError: errors.New("not found"),
+		},
+		{
+			name:  "stale probe is ignored",
+			probe: &models.Probe{NodeId: "1", Error: "request not sent to node", Timestamp: time.Now().Add(-time.Hour)},
+		},
+		{
+			name:          "probe failed",
+			probe:         &models.Probe{NodeId: "1", Error: "request not sent to node", Timestamp: time.Now()},
+			penaltyReason: penalty.FailedProbe,
+		},
+		{
+			name:          "reported metrics diverge from probe",
+			probe:         &models.Probe{NodeId: "1", BestBlockHeight: 500, FinalizedBlockHeight: 495, Timestamp: time.Now()},
+			penaltyReason: penalty.MetricsDivergence,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := models.Node{ID: "1"}
+			nodeRepoMock := repoMocks.NodeRepository{}
+			nodeRepoMock.On("GetAllActiveNodes").Return(&[]models.Node{node})
+			pingRepoMock := repoMocks.PingRepository{}
+			pingRepoMock.On("FindByNodeID", "1").Return(&models.Ping{NodeId: "1", Timestamp: time.Now()}, nil)
+			metricsRepoMock := repoMocks.MetricsRepository{}
+			metricsRepoMock.On("FindByID", "1").Return(&models.Metrics{
+				NodeId:               "1",
+				BestBlockHeight:      1000,
+				FinalizedBlockHeight: 995,
+				TargetBlockHeight:    1000,
+				Timestamp:            time.Now(),
+			}, nil)
+			metricsRepoMock.On("GetLatestBlockMetrics").Return(&models.LatestBlockMetrics{
+				BestBlockHeight:      1000,
+				FinalizedBlockHeight: 995,
+			}, nil)
+			probeRepoMock := repoMocks.ProbeRepository{}
+			probeRepoMock.On("FindByNodeID", "1").Return(test.probe, test.probeError)
+			actionsMockObject := new(actionMocks.Actions)
+			actionsMockObject.On("PenalizeNode", node, mock.Anything, test.penaltyReason).Return()
+
+			scheduledTask(&repositories.Repos{
+				NodeRepo:    &nodeRepoMock,
+				PingRepo:    &pingRepoMock,
+				MetricsRepo: &metricsRepoMock,
+				ProbeRepo:   &probeRepoMock,
+			}, actionsMockObject)
+
+			if test.penaltyReason != "" {
+				actionsMockObject.AssertCalled(t, "PenalizeNode", node, mock.Anything, test.penaltyReason)
+			} else {
+				actionsMockObject.AssertNotCalled(t, "PenalizeNode", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
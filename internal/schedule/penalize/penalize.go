@@ -4,14 +4,15 @@ import (
 	"time"
 
 	"github.com/NodeFactoryIo/vedran/internal/active"
+	"github.com/NodeFactoryIo/vedran/internal/blockheight"
+	"github.com/NodeFactoryIo/vedran/internal/capacity"
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	"github.com/NodeFactoryIo/vedran/internal/whitelist"
 	log "github.com/sirupsen/logrus"
 )
 
-const MaxCooldownForPenalizedNode = 17 * time.Hour
-
 var afterFunc = time.AfterFunc
 
 func ScheduleCheckForPenalizedNode(node models.Node, repositories repositories.Repos) {
@@ -38,12 +39,20 @@ func ScheduleCheckForPenalizedNode(node models.Node, repositories repositories.R
 			return
 		}
 
-		nodeWithNewCooldown, err := repositories.NodeRepo.IncreaseNodeCooldown(node.ID)
+		growth := penalty.PolicyFor(node.PenaltyReason).Growth
+		nodeWithNewCooldown, err := repositories.NodeRepo.IncreaseNodeCooldown(node.ID, growth)
 		if err != nil {
 			log.Errorf("Unable to save new cooldown for node %s, because of %v", node.ID, err)
+			return
+		}
+
+		// cooldown was reset by operator while node was penalized
+		if nodeWithNewCooldown.Cooldown == 0 {
+			log.Debugf("Node %s cooldown was reset, stopped checking penalized node", node.ID)
+			return
 		}
 
-		if (time.Duration(nodeWithNewCooldown.Cooldown) * time.Minute) > MaxCooldownForPenalizedNode {
+		if penalty.ReachedMaxCooldown(*nodeWithNewCooldown) {
 			log.Debugf("Node %s reached maximum cooldown", node.ID)
 
 			node.Active = false
@@ -57,6 +66,10 @@ func ScheduleCheckForPenalizedNode(node models.Node, repositories repositories.R
 				log.Errorf("Unable to remove node %s from whitelisted nodes, because of %v", node.ID, err)
 			}
 
+			blockheight.RemoveNode(node.ID)
+			// deactivated node frees its slot for node on waiting list
+			capacity.PromoteWaitingNodes(repositories)
+
 			return
 		}
 
@@ -238,6 +238,46 @@ func TestScheduleCheckForPenalizedNode(t *testing.T) {
 			},
 			increaseNodeCooldownNumberOfCalls: 2,
 		},
+		{
+			name:   "penalized node cooldown reset by operator",
+			nodeID: "1",
+			node: models.Node{
+				ID:       "1",
+				Cooldown: 4,
+			},
+			addToActiveNode:                nil,
+			addToActiveNodesNumberOfCalls:  0,
+			setNodeAsInactiveNumberOfCalls: 0,
+			resetNodeCooldownNumberOfCalls: 0,
+			nodePing: []*models.Ping{
+				{
+					NodeId:    "1",
+					Timestamp: time.Now(),
+				},
+			},
+			nodeMetrics: []*models.Metrics{
+				{
+					NodeId:               "1",
+					BestBlockHeight:      900,
+					FinalizedBlockHeight: 898,
+					TargetBlockHeight:    900,
+					Timestamp:            time.Now(),
+				},
+			},
+			latestMetrics: []*models.LatestBlockMetrics{
+				{
+					BestBlockHeight:      1001,
+					FinalizedBlockHeight: 998,
+				},
+			},
+			increaseNodeCooldown: []*models.Node{
+				{
+					ID:       "1",
+					Cooldown: 0,
+				},
+			},
+			increaseNodeCooldownNumberOfCalls: 1,
+		},
 	}
 
 	for _, test := range tests {
@@ -268,10 +308,11 @@ func TestScheduleCheckForPenalizedNode(t *testing.T) {
 					nodeRepoMock.On(
 						"IncreaseNodeCooldown",
 						test.nodeID,
+						mock.Anything,
 					).Return(test.increaseNodeCooldown[0], nil)
 				} else {
 					for _, node := range test.increaseNodeCooldown {
-						nodeRepoMock.On("IncreaseNodeCooldown", test.nodeID).Return(node, nil).Once()
+						nodeRepoMock.On("IncreaseNodeCooldown", test.nodeID, mock.Anything).Return(node, nil).Once()
 					}
 				}
 			}
@@ -0,0 +1,39 @@
+package selection
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+)
+
+// roundRobinSelector orders nodes by time they were last used
+type roundRobinSelector struct{}
+
+func (s *roundRobinSelector) Select(nodes []models.Node) []models.Node {
+	ordered := copyNodes(nodes)
+	sortByLastUsed(ordered)
+	return ordered
+}
+
+func (s *roundRobinSelector) RequestStarted(string) {}
+
+func (s *roundRobinSelector) RequestFinished(string, time.Duration, error) {}
+
+// randomSelector shuffles nodes
+type randomSelector struct{}
+
+func (s *randomSelector) Select(nodes []models.Node) []models.Node {
+	ordered := copyNodes(nodes)
+
+	rand.Seed(time.Now().UnixNano())
+	rand.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+
+	return ordered
+}
+
+func (s *randomSelector) RequestStarted(string) {}
+
+func (s *randomSelector) RequestFinished(string, time.Duration, error) {}
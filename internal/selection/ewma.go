@@ -0,0 +1,73 @@
+package selection
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+)
+
+const (
+	// DefaultEWMADecay is weight given to newest latency sample
+	DefaultEWMADecay = 0.3
+	// DefaultEWMAFailurePenalty is latency recorded for node when request to it fails
+	DefaultEWMAFailurePenalty = 3 * time.Second
+)
+
+// ewmaSelector orders nodes by exponentially weighted moving average of observed
+// request latencies, nodes without recorded latency are tried first so their
+// latency can be learned
+type ewmaSelector struct {
+	decay          float64
+	failurePenalty time.Duration
+	latencies      map[string]float64
+	mutex          sync.RWMutex
+}
+
+func newEWMASelector(decay float64, failurePenalty time.Duration) *ewmaSelector {
+	return &ewmaSelector{
+		decay:          decay,
+		failurePenalty: failurePenalty,
+		latencies:      make(map[string]float64),
+	}
+}
+
+func (s *ewmaSelector) Select(nodes []models.Node) []models.Node {
+	ordered := copyNodes(nodes)
+	sortByLastUsed(ordered)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return s.latencies[ordered[i].ID] < s.latencies[ordered[j].ID]
+	})
+
+	return ordered
+}
+
+func (s *ewmaSelector) RequestStarted(string) {}
+
+func (s *ewmaSelector) RequestFinished(nodeID string, latency time.Duration, err error) {
+	if err != nil && latency < s.failurePenalty {
+		latency = s.failurePenalty
+	}
+	sample := float64(latency.Milliseconds())
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	current, ok := s.latencies[nodeID]
+	if !ok {
+		s.latencies[nodeID] = sample
+		return
+	}
+	s.latencies[nodeID] = s.decay*sample + (1-s.decay)*current
+}
+
+// Latency returns current moving average of latency for node and if any latency was recorded
+func (s *ewmaSelector) Latency(nodeID string) (time.Duration, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	l, ok := s.latencies[nodeID]
+	return time.Duration(l) * time.Millisecond, ok
+}
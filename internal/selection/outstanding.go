@@ -0,0 +1,51 @@
+package selection
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+)
+
+// leastOutstandingSelector orders nodes by number of requests currently
+// in flight towards each node
+type leastOutstandingSelector struct {
+	outstanding map[string]int
+	mutex       sync.RWMutex
+}
+
+func newLeastOutstandingSelector() *leastOutstandingSelector {
+	return &leastOutstandingSelector{
+		outstanding: make(map[string]int),
+	}
+}
+
+func (s *leastOutstandingSelector) Select(nodes []models.Node) []models.Node {
+	ordered := copyNodes(nodes)
+	sortByLastUsed(ordered)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return s.outstanding[ordered[i].ID] < s.outstanding[ordered[j].ID]
+	})
+
+	return ordered
+}
+
+func (s *leastOutstandingSelector) RequestStarted(nodeID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.outstanding[nodeID]++
+}
+
+func (s *leastOutstandingSelector) RequestFinished(nodeID string, _ time.Duration, _ error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.outstanding[nodeID] <= 1 {
+		delete(s.outstanding, nodeID)
+		return
+	}
+	s.outstanding[nodeID]--
+}
@@ -0,0 +1,69 @@
+package selection
+
+import (
+	"sort"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+)
+
+const (
+	RoundRobin       = "round-robin"
+	Random           = "random"
+	EWMA             = "ewma"
+	LeastOutstanding = "least-outstanding"
+	WeightedRandom   = "weighted-random"
+)
+
+// Selector decides in which order active nodes are tried when serving request
+// and learns from outcomes of requests sent to nodes
+type Selector interface {
+	// Select returns provided nodes ordered by preference, provided slice is not modified
+	Select(nodes []models.Node) []models.Node
+	// RequestStarted should be called before request is sent to node
+	RequestStarted(nodeID string)
+	// RequestFinished should be called after request to node finished, with observed latency
+	// and error if request failed
+	RequestFinished(nodeID string, latency time.Duration, err error)
+}
+
+var selectors = map[string]Selector{
+	RoundRobin:       &roundRobinSelector{},
+	Random:           &randomSelector{},
+	EWMA:             newEWMASelector(DefaultEWMADecay, DefaultEWMAFailurePenalty),
+	LeastOutstanding: newLeastOutstandingSelector(),
+	WeightedRandom:   &weightedRandomSelector{},
+}
+
+// Strategies returns names of all available selection strategies
+func Strategies() []string {
+	return []string{RoundRobin, Random, EWMA, LeastOutstanding, WeightedRandom}
+}
+
+// IsValidStrategy checks if provided strategy is one of available selection strategies
+func IsValidStrategy(strategy string) bool {
+	_, ok := selectors[strategy]
+	return ok
+}
+
+// For returns selector for provided strategy, falling back to round-robin
+// selection if strategy is unknown
+func For(strategy string) Selector {
+	if s, ok := selectors[strategy]; ok {
+		return s
+	}
+	return selectors[RoundRobin]
+}
+
+func copyNodes(nodes []models.Node) []models.Node {
+	c := make([]models.Node, len(nodes))
+	_ = copy(c, nodes)
+	return c
+}
+
+// sortByLastUsed sorts nodes so that least recently used nodes come first
+func sortByLastUsed(nodes []models.Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].LastUsed < nodes[j].LastUsed
+	})
+}
@@ -0,0 +1,97 @@
+package selection
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func nodeIDs(nodes []models.Node) []string {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	return ids
+}
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		valid    bool
+		want     Selector
+	}{
+		{name: "round robin", strategy: RoundRobin, valid: true, want: selectors[RoundRobin]},
+		{name: "ewma", strategy: EWMA, valid: true, want: selectors[EWMA]},
+		{name: "unknown falls back to round robin", strategy: "unknown", valid: false, want: selectors[RoundRobin]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.valid, IsValidStrategy(test.strategy))
+			assert.Same(t, test.want, For(test.strategy))
+		})
+	}
+}
+
+func TestRoundRobinSelector_Select(t *testing.T) {
+	nodes := []models.Node{{ID: "1", LastUsed: 3}, {ID: "2", LastUsed: 1}, {ID: "3", LastUsed: 2}}
+	s := &roundRobinSelector{}
+
+	assert.Equal(t, []string{"2", "3", "1"}, nodeIDs(s.Select(nodes)))
+	// provided slice is not modified
+	assert.Equal(t, []string{"1", "2", "3"}, nodeIDs(nodes))
+}
+
+func TestRandomSelector_Select(t *testing.T) {
+	nodes := []models.Node{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+	s := &randomSelector{}
+
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, nodeIDs(s.Select(nodes)))
+}
+
+func TestEWMASelector(t *testing.T) {
+	nodes := []models.Node{{ID: "1", LastUsed: 1}, {ID: "2", LastUsed: 2}, {ID: "3", LastUsed: 3}}
+	s := newEWMASelector(0.5, 3*time.Second)
+
+	s.RequestFinished("1", 400*time.Millisecond, nil)
+	s.RequestFinished("2", 100*time.Millisecond, nil)
+	// node 3 has no recorded latency and should be tried first
+	assert.Equal(t, []string{"3", "2", "1"}, nodeIDs(s.Select(nodes)))
+
+	s.RequestFinished("3", 200*time.Millisecond, nil)
+	s.RequestFinished("2", 0, errors.New("failed"))
+	latency, ok := s.Latency("2")
+	assert.True(t, ok)
+	assert.Equal(t, 1550*time.Millisecond, latency)
+	assert.Equal(t, []string{"3", "1", "2"}, nodeIDs(s.Select(nodes)))
+}
+
+func TestLeastOutstandingSelector(t *testing.T) {
+	nodes := []models.Node{{ID: "1", LastUsed: 1}, {ID: "2", LastUsed: 2}, {ID: "3", LastUsed: 3}}
+	s := newLeastOutstandingSelector()
+
+	s.RequestStarted("1")
+	s.RequestStarted("1")
+	s.RequestStarted("2")
+	assert.Equal(t, []string{"3", "2", "1"}, nodeIDs(s.Select(nodes)))
+
+	s.RequestFinished("1", time.Second, nil)
+	s.RequestFinished("1", time.Second, nil)
+	assert.Equal(t, []string{"1", "3", "2"}, nodeIDs(s.Select(nodes)))
+}
+
+func TestWeightedRandomSelector_Select(t *testing.T) {
+	nodes := []models.Node{{ID: "1", Capacity: 1000}, {ID: "2", Capacity: 1}, {ID: "3"}}
+	s := &weightedRandomSelector{}
+
+	firstCount := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		ordered := s.Select(nodes)
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, nodeIDs(ordered))
+		firstCount[ordered[0].ID]++
+	}
+	assert.Greater(t, firstCount["1"], firstCount["2"]+firstCount["3"])
+}
@@ -0,0 +1,42 @@
+package selection
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+)
+
+// DefaultNodeCapacity is weight used for nodes that did not declare capacity
+const DefaultNodeCapacity = 1
+
+// weightedRandomSelector shuffles nodes so that nodes with higher declared
+// capacity are more likely to be ordered first
+type weightedRandomSelector struct{}
+
+func (s *weightedRandomSelector) Select(nodes []models.Node) []models.Node {
+	ordered := copyNodes(nodes)
+
+	// weighted random sampling without replacement, each node gets key u^(1/w)
+	// and nodes are ordered by descending keys
+	keys := make(map[string]float64, len(ordered))
+	for _, node := range ordered {
+		weight := float64(node.Capacity)
+		if weight <= 0 {
+			weight = DefaultNodeCapacity
+		}
+		keys[node.ID] = math.Pow(rand.Float64(), 1/weight)
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return keys[ordered[i].ID] > keys[ordered[j].ID]
+	})
+
+	return ordered
+}
+
+func (s *weightedRandomSelector) RequestStarted(string) {}
+
+func (s *weightedRandomSelector) RequestFinished(string, time.Duration, error) {}
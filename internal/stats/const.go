@@ -1,6 +1,10 @@
 package stats
 
+import "time"
+
 const (
 	// PingIntervalInSeconds
 	PingIntervalInSeconds = 5
+	// MaxMaintenanceDuration is longest part of single maintenance that is not counted as downtime
+	MaxMaintenanceDuration = 24 * time.Hour
 )
@@ -22,6 +22,16 @@ func CalculateTotalPingsForNode(
 		}
 	}
 
+	// time inside planned maintenance of node is not counted as downtime
+	maintenances, err := repos.MaintenanceRepo.FindMaintenancesInsideInterval(nodeId, intervalStart, intervalEnd)
+	if err != nil {
+		if err.Error() == "not found" {
+			maintenances = []models.Maintenance{}
+		} else {
+			return 0, err
+		}
+	}
+
 	totalTime := intervalEnd.Sub(intervalStart)
 	leftTime := totalTime
 	for _, downtime := range downtimesInInterval {
@@ -29,10 +39,12 @@ func CalculateTotalPingsForNode(
 		// case 1: entire downtime inside interval
 		if downtime.Start.After(intervalStart) && downtime.End.Before(intervalEnd) {
 			downtimeLength = downtime.End.Sub(downtime.Start)
+			downtimeLength -= maintenanceOverlap(maintenances, downtime.Start, downtime.End, intervalEnd)
 		}
 		// case 2: downtime started before interval
 		if downtime.Start.Before(intervalStart) {
 			downtimeLength = downtime.End.Sub(intervalStart)
+			downtimeLength -= maintenanceOverlap(maintenances, intervalStart, downtime.End, intervalEnd)
 		}
 		leftTime -= downtimeLength
 	}
@@ -41,6 +53,9 @@ func CalculateTotalPingsForNode(
 	if err != nil {
 		return 0, err
 	}
+	if duration > 0 {
+		duration -= maintenanceOverlap(maintenances, intervalEnd.Add(-duration), intervalEnd, intervalEnd)
+	}
 	if duration.Seconds() > leftTime.Seconds() {
 		// if node was down for entire observed interval
 		return 0, nil
@@ -52,3 +67,30 @@ func CalculateTotalPingsForNode(
 	totalPings := leftTime.Seconds() / PingIntervalInSeconds
 	return totalPings, nil
 }
+
+// maintenanceOverlap returns part of period between start and end that is inside maintenance windows,
+// maintenance still in progress lasts until now and each maintenance is limited to MaxMaintenanceDuration
+func maintenanceOverlap(maintenances []models.Maintenance, start time.Time, end time.Time, now time.Time) time.Duration {
+	var overlap time.Duration
+	for _, maintenance := range maintenances {
+		maintenanceEnd := maintenance.End
+		if maintenanceEnd.IsZero() {
+			maintenanceEnd = now
+		}
+		if maxEnd := maintenance.Start.Add(MaxMaintenanceDuration); maintenanceEnd.After(maxEnd) {
+			maintenanceEnd = maxEnd
+		}
+
+		overlapStart, overlapEnd := start, end
+		if maintenance.Start.After(overlapStart) {
+			overlapStart = maintenance.Start
+		}
+		if maintenanceEnd.Before(overlapEnd) {
+			overlapEnd = maintenanceEnd
+		}
+		if overlapEnd.After(overlapStart) {
+			overlap += overlapEnd.Sub(overlapStart)
+		}
+	}
+	return overlap
+}
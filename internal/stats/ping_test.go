@@ -6,6 +6,7 @@ import (
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"testing"
 	"time"
 )
@@ -22,6 +23,8 @@ func Test_CalculateTotalPingsForNode(t *testing.T) {
 		downtimeRepoFindDowntimesInsideIntervalReturns    []models.Downtime
 		downtimeRepoFindDowntimesInsideIntervalError      error
 		downtimeRepoFindDowntimesInsideIntervalNumOfCalls int
+		// MaintenanceRepo.FindMaintenancesInsideInterval
+		maintenanceRepoFindMaintenancesInsideIntervalReturns []models.Maintenance
 		// PingRepo.CalculateDowntime
 		pingRepoCalculateDowntimeReturnDuration time.Duration
 		pingRepoCalculateDowntimeError          error
@@ -214,10 +217,58 @@ func Test_CalculateTotalPingsForNode(t *testing.T) {
 			calculateTotalPingsForNodeReturns: float64(0),
 			calculateTotalPingsForNodeError:   nil,
 		},
+		{
+			name:   "downtime during maintenance",
+			nodeID: "1",
+			// interval of 24 hours
+			intervalStart: now.Add(-24 * time.Hour),
+			intervalEnd:   now,
+			// DowntimeRepo.FindByNodeID
+			downtimeRepoFindDowntimesInsideIntervalReturns: []models.Downtime{
+				{ // downtime 1h, 20min inside maintenance
+					ID:     1,
+					NodeId: "1",
+					Start:  now.Add(-11 * time.Hour),
+					End:    now.Add(-10 * time.Hour),
+				},
+			},
+			downtimeRepoFindDowntimesInsideIntervalError:      nil,
+			downtimeRepoFindDowntimesInsideIntervalNumOfCalls: 1,
+			// MaintenanceRepo.FindMaintenancesInsideInterval
+			maintenanceRepoFindMaintenancesInsideIntervalReturns: []models.Maintenance{
+				{
+					ID:     1,
+					NodeId: "1",
+					Start:  now.Add(-620 * time.Minute),
+					End:    now.Add(-9 * time.Hour),
+				},
+				{ // maintenance still in progress
+					ID:     2,
+					NodeId: "1",
+					Start:  now.Add(-20 * time.Minute),
+				},
+			},
+			// PingRepo.CalculateDowntime, 10min of downtime still active is outside maintenance
+			pingRepoCalculateDowntimeReturnDuration: 30 * time.Minute,
+			pingRepoCalculateDowntimeError:          nil,
+			pingRepoCalculateDowntimeNumOfCalls:     1,
+			// [total_interval - total_downtime]   / ping_interval = num_of_pings
+			// [24h (86400s) - 40min + 10min (3000s)] / 5          = 16680
+			calculateTotalPingsForNodeReturns: float64(83400 / PingIntervalInSeconds),
+			calculateTotalPingsForNodeError:   nil,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			if test.maintenanceRepoFindMaintenancesInsideIntervalReturns != nil {
+				maintenanceRepoMock.On("FindMaintenancesInsideInterval",
+					test.nodeID, test.intervalStart, test.intervalEnd,
+				).Return(test.maintenanceRepoFindMaintenancesInsideIntervalReturns, nil)
+			} else {
+				maintenanceRepoMock.On("FindMaintenancesInsideInterval", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
+			}
 			downtimeRepoMock := mocks.DowntimeRepository{}
 			downtimeRepoMock.On("FindDowntimesInsideInterval",
 				test.nodeID, test.intervalStart, test.intervalEnd,
@@ -236,8 +287,9 @@ func Test_CalculateTotalPingsForNode(t *testing.T) {
 			)
 
 			repos := repositories.Repos{
-				PingRepo:     &pingRepoMock,
-				DowntimeRepo: &downtimeRepoMock,
+				PingRepo:        &pingRepoMock,
+				DowntimeRepo:    &downtimeRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
 			}
 
 			totalPings, err := CalculateTotalPingsForNode(repos, test.nodeID, test.intervalStart, test.intervalEnd)
@@ -6,6 +6,7 @@ import (
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
 	mocks "github.com/NodeFactoryIo/vedran/mocks/repositories"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"testing"
 	"time"
 )
@@ -93,6 +94,8 @@ func Test_CalculateNodeStatisticsFromLastPayout(t *testing.T) {
 				test.recordRepoFindSuccessfulRecordsInsideIntervalReturns,
 				test.recordRepoFindSuccessfulRecordsInsideIntervalError,
 			)
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			maintenanceRepoMock.On("FindMaintenancesInsideInterval", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
 			downtimeRepoMock := mocks.DowntimeRepository{}
 			downtimeRepoMock.On("FindDowntimesInsideInterval",
 				test.nodeID, test.intervalStart, test.intervalEnd,
@@ -114,10 +117,11 @@ func Test_CalculateNodeStatisticsFromLastPayout(t *testing.T) {
 				test.payoutRepoFindLatestPayoutError,
 			)
 			repos := repositories.Repos{
-				PingRepo:     &pingRepoMock,
-				RecordRepo:   &recordRepoMock,
-				DowntimeRepo: &downtimeRepoMock,
-				PayoutRepo:   &payoutRepoMock,
+				PingRepo:        &pingRepoMock,
+				RecordRepo:      &recordRepoMock,
+				DowntimeRepo:    &downtimeRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
+				PayoutRepo:      &payoutRepoMock,
 			}
 
 			statisticsForPayout, err := CalculateNodeStatisticsFromLastPayout(repos, test.nodeID, test.intervalEnd)
@@ -249,6 +253,8 @@ func Test_CalculateStatisticsFromLastPayout(t *testing.T) {
 				test.recordRepoFindSuccessfulRecordsInsideIntervalReturns,
 				test.recordRepoFindSuccessfulRecordsInsideIntervalError,
 			)
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			maintenanceRepoMock.On("FindMaintenancesInsideInterval", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
 			downtimeRepoMock := mocks.DowntimeRepository{}
 			downtimeRepoMock.On("FindDowntimesInsideInterval",
 				testNode.ID, test.intervalStart, test.intervalEnd,
@@ -270,11 +276,12 @@ func Test_CalculateStatisticsFromLastPayout(t *testing.T) {
 				test.payoutRepoFindLatestPayoutError,
 			)
 			repos := repositories.Repos{
-				PingRepo:     &pingRepoMock,
-				RecordRepo:   &recordRepoMock,
-				DowntimeRepo: &downtimeRepoMock,
-				NodeRepo:     &nodeRepoMock,
-				PayoutRepo:   &payoutRepoMock,
+				PingRepo:        &pingRepoMock,
+				RecordRepo:      &recordRepoMock,
+				DowntimeRepo:    &downtimeRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
+				NodeRepo:        &nodeRepoMock,
+				PayoutRepo:      &payoutRepoMock,
 			}
 
 			statisticsForPayout, err := CalculateStatisticsFromLastPayout(repos, test.intervalEnd)
@@ -422,6 +429,8 @@ func Test_CalculateNodeStatisticsForInterval(t *testing.T) {
 				test.recordRepoFindSuccessfulRecordsInsideIntervalReturns,
 				test.recordRepoFindSuccessfulRecordsInsideIntervalError,
 			)
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			maintenanceRepoMock.On("FindMaintenancesInsideInterval", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
 			downtimeRepoMock := mocks.DowntimeRepository{}
 			downtimeRepoMock.On("FindDowntimesInsideInterval",
 				test.nodeID, test.intervalStart, test.intervalEnd,
@@ -438,9 +447,10 @@ func Test_CalculateNodeStatisticsForInterval(t *testing.T) {
 				test.pingRepoCalculateDowntimeError,
 			)
 			repos := repositories.Repos{
-				PingRepo:     &pingRepoMock,
-				RecordRepo:   &recordRepoMock,
-				DowntimeRepo: &downtimeRepoMock,
+				PingRepo:        &pingRepoMock,
+				RecordRepo:      &recordRepoMock,
+				DowntimeRepo:    &downtimeRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
 			}
 
 			statisticsForPayout, err := CalculateNodeStatisticsForInterval(repos, test.nodeID, test.intervalStart, test.intervalEnd)
@@ -582,6 +592,8 @@ func Test_CalculateStatisticsForInterval(t *testing.T) {
 				test.recordRepoFindSuccessfulRecordsInsideIntervalReturns,
 				test.recordRepoFindSuccessfulRecordsInsideIntervalError,
 			)
+			maintenanceRepoMock := mocks.MaintenanceRepository{}
+			maintenanceRepoMock.On("FindMaintenancesInsideInterval", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
 			downtimeRepoMock := mocks.DowntimeRepository{}
 			downtimeRepoMock.On("FindDowntimesInsideInterval",
 				testNode.ID, test.intervalStart, test.intervalEnd,
@@ -598,10 +610,11 @@ func Test_CalculateStatisticsForInterval(t *testing.T) {
 				test.pingRepoCalculateDowntimeError,
 			)
 			repos := repositories.Repos{
-				PingRepo:     &pingRepoMock,
-				RecordRepo:   &recordRepoMock,
-				DowntimeRepo: &downtimeRepoMock,
-				NodeRepo:     &nodeRepoMock,
+				PingRepo:        &pingRepoMock,
+				RecordRepo:      &recordRepoMock,
+				DowntimeRepo:    &downtimeRepoMock,
+				MaintenanceRepo: &maintenanceRepoMock,
+				NodeRepo:        &nodeRepoMock,
 			}
 
 			statisticsForPayout, err := CalculateStatisticsForInterval(repos, test.intervalStart, test.intervalEnd)
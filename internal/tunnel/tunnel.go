@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/NodeFactoryIo/vedran/internal/auth"
+	"github.com/NodeFactoryIo/vedran/internal/chain"
 	"github.com/NodeFactoryIo/vedran/pkg/http-tunnel/server"
 	log "github.com/sirupsen/logrus"
 )
@@ -14,15 +15,11 @@ func StartHttpTunnelServer(serverPort string, portPool *server.AddrPool) {
 		Address:  fmt.Sprintf(":%s", serverPort),
 		PortPool: portPool,
 		AuthHandler: func(rawToken string) bool {
-			token, err := auth.ParseJwtTokenWithCustomClaims(rawToken)
-			if err == nil {
-				if _, ok := token.Claims.(*auth.CustomClaims); ok && token.Valid {
-					return true
-				}
-			}
-			return false
+			_, err := auth.ValidateToken(rawToken)
+			return err == nil
 		},
-		Logger: logger,
+		ConnectHandler: chain.CheckNodeChain,
+		Logger:         logger,
 	})
 	if err != nil {
 		log.Fatalf("failed to create http tunnel server: %s", err)
@@ -0,0 +1,152 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/record"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+)
+
+type messageKind int
+
+const (
+	unknownMessage messageKind = iota
+	responseMessage
+	notificationMessage
+)
+
+// RequestTracker pairs requests sent to node with node responses by request id, so node
+// is rewarded only for responses to client requests and subscription notifications are
+// counted separately
+type RequestTracker struct {
+	mutex   sync.Mutex
+	pending map[string]int
+	// blocked are error responses for requests rejected by method policy mapped by id of first
+	// request of batch forwarded to node, they are added to batch response of node
+	blocked map[string][][]rpc.RPCResponse
+}
+
+func NewRequestTracker() *RequestTracker {
+	return &RequestTracker{
+		pending: make(map[string]int),
+		blocked: make(map[string][][]rpc.RPCResponse),
+	}
+}
+
+// Hold keeps error responses of blocked batch requests until node answers rest of batch,
+// false is returned if node won't answer batch because it contains only notifications
+func (t *RequestTracker) Hold(batch []byte, blocked []rpc.RPCResponse) bool {
+	var reqs []rpc.RPCRequest
+	_ = json.Unmarshal(batch, &reqs)
+	for _, req := range reqs {
+		if req.IsNotification() {
+			continue
+		}
+		key := rpc.IDKey(req.ID)
+		t.mutex.Lock()
+		t.blocked[key] = append(t.blocked[key], blocked)
+		t.mutex.Unlock()
+		return true
+	}
+	return false
+}
+
+// merge adds error responses of blocked requests to batch response of node they were held for
+func (t *RequestTracker) merge(msg []byte) []byte {
+	if !rpc.IsBatch(msg) {
+		return msg
+	}
+	var messages []upstreamMessage
+	if err := json.Unmarshal(msg, &messages); err != nil {
+		return msg
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, m := range messages {
+		key := rpc.IDKey(m.ID)
+		held := t.blocked[key]
+		if len(held) == 0 {
+			continue
+		}
+		if len(held) == 1 {
+			delete(t.blocked, key)
+		} else {
+			t.blocked[key] = held[1:]
+		}
+		merged, err := rpc.AppendBatchResponses(msg, held[0])
+		if err != nil {
+			return msg
+		}
+		return merged
+	}
+	return msg
+}
+
+// Track registers ids of requests sent to node
+func (t *RequestTracker) Track(msg []byte) {
+	var reqs []rpc.RPCRequest
+	if rpc.IsBatch(msg) {
+		_ = json.Unmarshal(msg, &reqs)
+	} else {
+		var req rpc.RPCRequest
+		if err := json.Unmarshal(msg, &req); err == nil {
+			reqs = append(reqs, req)
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, req := range reqs {
+		if !req.IsNotification() {
+			t.pending[rpc.IDKey(req.ID)]++
+		}
+	}
+}
+
+// classify returns kind of message received from node, ids of responses are removed from
+// pending requests. Batch response is single response if it contains any pending id
+func (t *RequestTracker) classify(msg []byte) messageKind {
+	var messages []upstreamMessage
+	if rpc.IsBatch(msg) {
+		_ = json.Unmarshal(msg, &messages)
+	} else {
+		var m upstreamMessage
+		if err := json.Unmarshal(msg, &m); err == nil {
+			messages = append(messages, m)
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	kind := unknownMessage
+	for _, m := range messages {
+		if m.ID == nil && m.Params != nil {
+			kind = notificationMessage
+			continue
+		}
+		key := rpc.IDKey(m.ID)
+		if t.pending[key] == 0 {
+			continue
+		}
+		t.pending[key]--
+		if t.pending[key] == 0 {
+			delete(t.pending, key)
+		}
+		kind = responseMessage
+	}
+	return kind
+}
+
+// recordMessage rewards node for response and counts notification without rewarding node
+func recordMessage(kind messageKind, node models.Node, repos repositories.Repos) {
+	switch kind {
+	case responseMessage:
+		go record.SuccessfulRequest(node, repos)
+	case notificationMessage:
+		go record.SubscriptionNotification(node, repos)
+	}
+}
@@ -0,0 +1,332 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/NodeFactoryIo/vedran/internal/actions"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+)
+
+const (
+	submitAndWatchMethod  = "author_submitAndWatchExtrinsic"
+	connectionLostMessage = "Connection to node lost"
+	// subscriptionFailedMessage is sent to client whose subscription couldn't be restored after failover
+	subscriptionFailedMessage = "Subscription could not be restored"
+	// droppedStatus is status of watched extrinsic whose watch was lost
+	droppedStatus = `"dropped"`
+)
+
+// sharedSubscriptionMethods are subscriptions whose notifications are same for every client,
+// so clients subscribed with same params on same node share single upstream subscription
+var sharedSubscriptionMethods = map[string]bool{
+	"chain_subscribeNewHead":        true,
+	"chain_subscribeNewHeads":       true,
+	"chain_subscribeAllHeads":       true,
+	"chain_subscribeFinalizedHeads": true,
+	"chain_subscribeFinalisedHeads": true,
+	"state_subscribeRuntimeVersion": true,
+}
+
+var (
+	poolSize   int
+	failover   bool
+	pools      = make(map[string]*nodePool)
+	poolsMutex sync.Mutex
+)
+
+// InitMultiplexer sets maximum number of upstream connections per node that are shared
+// between clients, 0 disables multiplexing and every client gets dedicated connection to node
+func InitMultiplexer(size int) {
+	poolsMutex.Lock()
+	defer poolsMutex.Unlock()
+	poolSize = size
+	pools = make(map[string]*nodePool)
+}
+
+// IsMultiplexed returns true if clients are served through shared upstream connections
+func IsMultiplexed() bool {
+	poolsMutex.Lock()
+	defer poolsMutex.Unlock()
+	return poolSize > 0
+}
+
+// InitFailover enables moving clients to other node once connection to their node is lost,
+// without multiplexing every client is served through its own connection to node
+func InitFailover(enabled bool) {
+	poolsMutex.Lock()
+	defer poolsMutex.Unlock()
+	failover = enabled
+}
+
+// IsFailoverEnabled returns true if clients are moved to other node once connection to node is lost
+func IsFailoverEnabled() bool {
+	poolsMutex.Lock()
+	defer poolsMutex.Unlock()
+	return failover
+}
+
+// nodePool holds upstream connections to single node and subscriptions shared between
+// clients of node. All upstream, subscription and session routing state of node is guarded
+// by pool mutex
+type nodePool struct {
+	mutex     sync.Mutex
+	size      int
+	upstreams []*upstream
+	// dialing is number of connections to node that are being opened, dialed
+	// is signaled once dialing finishes
+	dialing int
+	dialed  *sync.Cond
+	shared  map[string]*subscription
+}
+
+func newNodePool(size int) *nodePool {
+	p := &nodePool{size: size, shared: make(map[string]*subscription)}
+	p.dialed = sync.NewCond(&p.mutex)
+	return p
+}
+
+// getPool returns pool of node shared between sessions, if multiplexing is disabled session
+// gets its own pool so its connection to node is not shared
+func getPool(nodeID string) *nodePool {
+	poolsMutex.Lock()
+	defer poolsMutex.Unlock()
+	if poolSize == 0 {
+		return newNodePool(1)
+	}
+	pool, ok := pools[nodeID]
+	if !ok {
+		pool = newNodePool(poolSize)
+		pools[nodeID] = pool
+	}
+	return pool
+}
+
+// attach assigns session to upstream connection with least sessions, new connection
+// to node is opened if all connections are used and pool is not full. Node is dialed
+// without holding pool mutex so other sessions of node are not blocked by slow node
+func (p *nodePool) attach(
+	s *Session,
+	node models.Node,
+	repos repositories.Repos,
+	act actions.Actions,
+) *ConnectionError {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	selected := p.leastUsed()
+	canDial := len(p.upstreams)+p.dialing < p.size
+	for selected == nil && !canDial {
+		// wait for connection other session is opening
+		p.dialed.Wait()
+		selected = p.leastUsed()
+		canDial = len(p.upstreams)+p.dialing < p.size
+	}
+
+	if canDial && (selected == nil || len(selected.sessions) > 0) {
+		p.dialing++
+		p.mutex.Unlock()
+		conn, connectionError := dialNode(node.ID)
+		p.mutex.Lock()
+		p.dialing--
+		p.dialed.Broadcast()
+
+		if connectionError == nil {
+			selected = newUpstream(p, node, conn, repos, act)
+			p.upstreams = append(p.upstreams, selected)
+			go selected.readMessages()
+			go selected.writeMessages()
+		} else {
+			// session uses existing connection if there is one
+			selected = p.leastUsed()
+			if selected == nil {
+				return connectionError
+			}
+		}
+	}
+
+	selected.sessions[s] = true
+	s.pool = p
+	s.upstream = selected
+	return nil
+}
+
+// leastUsed returns upstream connection with least sessions, nil is returned if pool
+// has no connections
+func (p *nodePool) leastUsed() *upstream {
+	var selected *upstream
+	for _, u := range p.upstreams {
+		if selected == nil || len(u.sessions) < len(selected.sessions) {
+			selected = u
+		}
+	}
+	return selected
+}
+
+// remove removes upstream connection from pool together with subscriptions it served
+func (p *nodePool) remove(u *upstream) {
+	for i, pooled := range p.upstreams {
+		if pooled == u {
+			p.upstreams = append(p.upstreams[:i], p.upstreams[i+1:]...)
+			break
+		}
+	}
+	for key, sub := range p.shared {
+		if sub.upstream == u {
+			delete(p.shared, key)
+		}
+	}
+}
+
+// forget removes session from subscriptions of pool and answers its subscribe
+// requests that are still waiting for node with error
+func (p *nodePool) forget(s *Session) []lostSubscription {
+	var lost []lostSubscription
+	for id, sub := range s.subscriptions {
+		lost = append(lost, sub.lost(id))
+		sub.leave(id)
+	}
+	for _, sub := range p.shared {
+		waiting := sub.waiting[:0]
+		for _, w := range sub.waiting {
+			if w.session != s {
+				waiting = append(waiting, w)
+			} else if w.restore != nil {
+				lost = append(lost, *w.restore)
+			} else {
+				w.deliver(errorResponse(w.id, rpc.InternalServerError, connectionLostMessage))
+			}
+		}
+		sub.waiting = waiting
+	}
+	return lost
+}
+
+// subscription is upstream subscription with clients that receive its notifications
+type subscription struct {
+	key               string
+	method            string
+	params            interface{}
+	unsubscribeMethod string
+	upstream          *upstream
+	upstreamID        json.RawMessage
+	ready             bool
+	// subscribers are sessions mapped by subscription id known to client
+	subscribers map[string]*Session
+	// waiting are subscribe requests received before node confirmed subscription
+	waiting []*pendingRequest
+	// last is result of last notification, sent to clients that join shared subscription
+	last               json.RawMessage
+	notificationMethod string
+}
+
+// lostSubscription is client subscription that was served by lost connection to node
+type lostSubscription struct {
+	id                 json.RawMessage
+	method             string
+	params             interface{}
+	notificationMethod string
+}
+
+// dropped returns notification telling client that subscription wasn't restored, watched
+// extrinsic gets dropped status as if node dropped it and other subscriptions get error
+func (l lostSubscription) dropped(message string) []byte {
+	params := notificationParams{Subscription: l.id}
+	if l.method == submitAndWatchMethod {
+		params.Result = json.RawMessage(droppedStatus)
+	} else {
+		params.Error = &rpc.RPCError{Code: rpc.InternalServerError, Message: message}
+	}
+	method := l.notificationMethod
+	if method == "" {
+		method = l.method
+	}
+	body, _ := json.Marshal(subscriptionNotification{JSONRPC: "2.0", Method: method, Params: params})
+	return body
+}
+
+type notificationParams struct {
+	Subscription json.RawMessage `json:"subscription"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        *rpc.RPCError   `json:"error,omitempty"`
+}
+
+type subscriptionNotification struct {
+	JSONRPC string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  notificationParams `json:"params"`
+}
+
+func isSubscribeMethod(method string) bool {
+	return strings.Contains(method, "_subscribe") || method == submitAndWatchMethod
+}
+
+func unsubscribeMethod(method string) string {
+	if method == submitAndWatchMethod {
+		return "author_unwatchExtrinsic"
+	}
+	return strings.Replace(method, "_subscribe", "_unsubscribe", 1)
+}
+
+// sharedSubscriptionKey returns key under which subscription is shared between clients,
+// empty key is returned for subscriptions that can't be shared
+func sharedSubscriptionKey(req rpc.RPCRequest) string {
+	if !sharedSubscriptionMethods[req.Method] {
+		return ""
+	}
+	params, err := json.Marshal(req.Params)
+	if err != nil {
+		return ""
+	}
+	return req.Method + string(params)
+}
+
+// firstParam returns id key of first request param
+func firstParam(req rpc.RPCRequest) (string, bool) {
+	params, ok := req.Params.([]interface{})
+	if !ok || len(params) == 0 {
+		return "", false
+	}
+	param, err := json.Marshal(params[0])
+	if err != nil {
+		return "", false
+	}
+	return rpc.IDKey(param), true
+}
+
+// newSubscriptionID generates random subscription id that is returned to client
+func newSubscriptionID() json.RawMessage {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	id, _ := json.Marshal(hex.EncodeToString(b))
+	return id
+}
+
+// replaceID returns rpc message with id replaced
+func replaceID(msg json.RawMessage, id json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return nil, err
+	}
+	fields["id"] = id
+	return json.Marshal(fields)
+}
+
+func resultResponse(id json.RawMessage, result json.RawMessage) json.RawMessage {
+	response, _ := json.Marshal(rpc.RPCResponse{JSONRPC: "2.0", ID: id, Result: &result})
+	return response
+}
+
+func errorResponse(id json.RawMessage, code int, message string) json.RawMessage {
+	response, _ := json.Marshal(rpc.RPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &rpc.RPCError{Code: code, Message: message},
+	})
+	return response
+}
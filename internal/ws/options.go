@@ -0,0 +1,185 @@
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxMessageSize matches default maximum rpc payload of substrate node
+	DefaultMaxMessageSize = 15 * 1024 * 1024
+	DefaultIdleTimeout    = 10 * time.Minute
+	DefaultPingInterval   = 30 * time.Second
+
+	// writeWait is time allowed to write single message to client or node
+	writeWait = 10 * time.Second
+)
+
+// connectionOptions are limits applied on client ws connections
+type connectionOptions struct {
+	maxMessageSize int64
+	idleTimeout    time.Duration
+	pingInterval   time.Duration
+	allowedOrigins []string
+	compression    bool
+}
+
+var (
+	options      connectionOptions
+	optionsMutex sync.RWMutex
+)
+
+func currentOptions() connectionOptions {
+	optionsMutex.RLock()
+	defer optionsMutex.RUnlock()
+	return options
+}
+
+// InitConnectionOptions sets limits of client ws connections. Maximum message size is also applied
+// on connections to nodes, 0 disables message size limit, idle timeout and pings. If allowed
+// origins are set, browser clients are accepted only from matching origins, where origins can be
+// glob patterns (e.g. https://*.example.com)
+func InitConnectionOptions(
+	messageSize int64,
+	idle time.Duration,
+	ping time.Duration,
+	origins []string,
+	enableCompression bool,
+) error {
+	for _, pattern := range origins {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowed origin %s: %v", pattern, err)
+		}
+	}
+
+	optionsMutex.Lock()
+	defer optionsMutex.Unlock()
+	options = connectionOptions{
+		maxMessageSize: messageSize,
+		idleTimeout:    idle,
+		pingInterval:   ping,
+		allowedOrigins: origins,
+		compression:    enableCompression,
+	}
+	return nil
+}
+
+// NewUpgrader returns upgrader for client connections that accepts only allowed origins
+func NewUpgrader() websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:    4096,
+		WriteBufferSize:   4096,
+		CheckOrigin:       CheckOrigin,
+		EnableCompression: currentOptions().compression,
+	}
+}
+
+// CheckOrigin returns true if request origin matches one of allowed origins, requests
+// without origin header are not sent by browsers and are always accepted
+func CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	allowedOrigins := currentOptions().allowedOrigins
+	if len(allowedOrigins) == 0 || origin == "" {
+		return true
+	}
+	for _, pattern := range allowedOrigins {
+		if matched, _ := path.Match(pattern, origin); matched {
+			return true
+		}
+	}
+	log.Debugf("Connection rejected because origin %s is not allowed", origin)
+	return false
+}
+
+// PrepareClientConn applies message size limit to client connection and sets read deadline
+// that is extended by every message and pong received from client
+func PrepareClientConn(conn *websocket.Conn) {
+	opts := currentOptions()
+	if opts.maxMessageSize > 0 {
+		conn.SetReadLimit(opts.maxMessageSize)
+	}
+	conn.EnableWriteCompression(opts.compression)
+	extendReadDeadline(conn)
+	conn.SetPongHandler(func(string) error {
+		extendReadDeadline(conn)
+		return nil
+	})
+}
+
+// extendReadDeadline gives client two ping intervals to send message or answer ping
+func extendReadDeadline(conn *websocket.Conn) {
+	if pingInterval := currentOptions().pingInterval; pingInterval > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	}
+}
+
+// writeMessage writes message to client or node, write fails if peer doesn't read it in time
+func writeMessage(conn *websocket.Conn, msgType int, msg []byte) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteMessage(msgType, msg)
+}
+
+// keepAliveTicker returns channel on which client connection should be pinged and checked
+// for idle timeout, nil channel is returned if both pings and idle timeout are disabled
+func keepAliveTicker() (*time.Ticker, <-chan time.Time) {
+	opts := currentOptions()
+	interval := opts.pingInterval
+	if interval == 0 || (opts.idleTimeout > 0 && opts.idleTimeout < interval) {
+		interval = opts.idleTimeout
+	}
+	if interval == 0 {
+		return nil, nil
+	}
+	ticker := time.NewTicker(interval)
+	return ticker, ticker.C
+}
+
+func stopTicker(ticker *time.Ticker) {
+	if ticker != nil {
+		ticker.Stop()
+	}
+}
+
+// activity is time of last message sent or received on client connection
+type activity struct {
+	last int64
+}
+
+func newActivity() *activity {
+	a := &activity{}
+	a.touch()
+	return a
+}
+
+func (a *activity) touch() {
+	atomic.StoreInt64(&a.last, time.Now().UnixNano())
+}
+
+func (a *activity) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&a.last)))
+}
+
+// keepAlive pings client and returns false if client connection was idle longer than
+// idle timeout or ping could not be sent
+func keepAlive(conn *websocket.Conn, a *activity) bool {
+	opts := currentOptions()
+	if opts.idleTimeout > 0 && a.idle() > opts.idleTimeout {
+		log.Debugf("Closing connection %s because it was idle for %v", conn.RemoteAddr(), a.idle())
+		return false
+	}
+	if opts.pingInterval > 0 {
+		err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+		if err != nil {
+			log.Debugf("Sending ping to client failed because of %v:", err)
+			return false
+		}
+	}
+	return true
+}
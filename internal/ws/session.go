@@ -0,0 +1,267 @@
+package ws
+
+import (
+	"sync"
+
+	"github.com/NodeFactoryIo/vedran/internal/actions"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/penalty"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// sessionBufferSize is number of messages buffered for client, client that doesn't
+// read messages fast enough is disconnected
+const sessionBufferSize = 256
+
+// Session is client connection served through upstream connection to node that is
+// shared with other clients. If connection to node is lost, session is moved to other
+// node and its subscriptions are restored under subscription ids known to client
+type Session struct {
+	conn   *websocket.Conn
+	client Client
+	repos  repositories.Repos
+	act    actions.Actions
+	// nodes returns nodes that can serve session after connection to node is lost
+	nodes func() []models.Node
+
+	// pool and upstream are changed only by goroutine serving session
+	pool     *nodePool
+	upstream *upstream
+	// subscriptions and closed are guarded by pool mutex
+	subscriptions map[string]*subscription
+	closed        bool
+
+	// lost subscriptions and reconnect are set when connection to node is lost
+	failoverMutex sync.Mutex
+	lost          []lostSubscription
+	reconnect     bool
+	failovers     chan struct{}
+
+	out       chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	activity  *activity
+}
+
+// NewSession creates session for client connection
+func NewSession(
+	conn *websocket.Conn,
+	client Client,
+	repos repositories.Repos,
+	act actions.Actions,
+	nodes func() []models.Node,
+) *Session {
+	return &Session{
+		conn:          conn,
+		client:        client,
+		repos:         repos,
+		act:           act,
+		nodes:         nodes,
+		subscriptions: make(map[string]*subscription),
+		failovers:     make(chan struct{}, 1),
+		out:           make(chan []byte, sessionBufferSize),
+		done:          make(chan struct{}),
+		activity:      newActivity(),
+	}
+}
+
+// Attach assigns session to shared upstream connection to node, new connection
+// to node is opened if needed
+func (s *Session) Attach(node models.Node) *ConnectionError {
+	return getPool(node.ID).attach(s, node, s.repos, s.act)
+}
+
+// Serve forwards client messages to node until client connection is closed, errors for
+// methods not allowed by method policy and for messages over rate limit of client are
+// sent back to client
+func (s *Session) Serve() {
+	go s.writeMessages()
+	messages := make(chan []byte)
+	go s.readMessages(messages)
+
+serve:
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				break serve
+			}
+			if !s.handle(msg) {
+				break serve
+			}
+		case <-s.failovers:
+			if !s.restore() {
+				break serve
+			}
+		}
+	}
+
+	s.close()
+	s.pool.mutex.Lock()
+	s.upstream.detach(s)
+	s.pool.mutex.Unlock()
+}
+
+func (s *Session) readMessages(messages chan []byte) {
+	defer close(messages)
+	for {
+		_, msg, err := s.conn.ReadMessage()
+		if err != nil {
+			log.Debugf("Reading request from client failed because of %v:", err)
+			return
+		}
+		extendReadDeadline(s.conn)
+		s.activity.touch()
+		select {
+		case messages <- msg:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// handle forwards client message to node, false is returned if session can't be served anymore
+func (s *Session) handle(msg []byte) bool {
+	if rejection := applyClientLimits(s.client, msg); rejection != nil {
+		s.send(rejection)
+		return true
+	}
+
+	msg, blocked, rejection := applyMethodPolicy(msg)
+	if rejection != nil {
+		s.send(rejection)
+	}
+	if msg == nil {
+		return true
+	}
+
+	for {
+		pool := s.pool
+		pool.mutex.Lock()
+		if !s.upstream.closed {
+			s.upstream.forward(s, msg, blocked)
+			pool.mutex.Unlock()
+			return true
+		}
+		pool.mutex.Unlock()
+
+		if !s.restore() {
+			return false
+		}
+	}
+}
+
+// failover is called when connection to node that served session subscriptions is lost,
+// reconnect is set if session itself used lost connection
+func (s *Session) failover(lost []lostSubscription, reconnect bool) {
+	s.failoverMutex.Lock()
+	s.lost = append(s.lost, lost...)
+	s.reconnect = s.reconnect || reconnect
+	s.failoverMutex.Unlock()
+
+	select {
+	case s.failovers <- struct{}{}:
+	default:
+	}
+}
+
+// restore moves session to other node if its connection to node was lost and restores lost
+// subscriptions, false is returned if there is no node that can serve session
+func (s *Session) restore() bool {
+	s.failoverMutex.Lock()
+	lost, reconnect := s.lost, s.reconnect
+	s.lost, s.reconnect = nil, false
+	s.failoverMutex.Unlock()
+
+	if reconnect {
+		failedNode := s.upstream.node
+		s.pool.mutex.Lock()
+		delete(s.upstream.sessions, s)
+		lost = append(lost, s.pool.forget(s)...)
+		s.pool.mutex.Unlock()
+
+		if !s.moveFrom(failedNode) {
+			log.Errorf("Failed moving client %s from node %s to other node", s.client.IP, failedNode.ID)
+			return false
+		}
+	}
+	if len(lost) == 0 {
+		return true
+	}
+
+	s.pool.mutex.Lock()
+	defer s.pool.mutex.Unlock()
+	for _, subscription := range lost {
+		s.upstream.resubscribe(s, subscription)
+	}
+	return true
+}
+
+// moveFrom attaches session to first node other than failed node that accepts connection
+func (s *Session) moveFrom(failedNode models.Node) bool {
+	for _, node := range s.nodes() {
+		if node.ID == failedNode.ID {
+			continue
+		}
+		connectionError := s.Attach(node)
+		if connectionError != nil {
+			log.Errorf("Establishing connection failed because of %v", connectionError)
+			if connectionError.IsNodeError() {
+				s.act.PenalizeNode(node, s.repos, penalty.WSConnection)
+			}
+			continue
+		}
+
+		go s.repos.NodeRepo.UpdateNodeUsed(node)
+		log.Debugf("Client %s moved from node %s to node %s", s.client.IP, failedNode.ID, node.ID)
+		return true
+	}
+	return false
+}
+
+// send queues message for client without blocking
+func (s *Session) send(msg []byte) {
+	select {
+	case <-s.done:
+	case s.out <- msg:
+	default:
+		log.Errorf("Closing connection of client %s because it doesn't read messages", s.client.IP)
+		s.close()
+	}
+}
+
+// writeMessages sends queued messages to client, client is pinged and disconnected
+// if connection is idle for too long
+func (s *Session) writeMessages() {
+	ticker, keepAliveC := keepAliveTicker()
+	defer stopTicker(ticker)
+	for {
+		select {
+		case <-s.done:
+			return
+		case msg := <-s.out:
+			if err := writeMessage(s.conn, websocket.TextMessage, msg); err != nil {
+				log.Errorf("Sending response client failed because of %v:", err)
+				s.close()
+				return
+			}
+			s.activity.touch()
+		case <-keepAliveC:
+			if !keepAlive(s.conn, s.activity) {
+				s.close()
+				return
+			}
+		}
+	}
+}
+
+// close closes client connection, session is removed from upstream connection
+// once session stops serving client messages
+func (s *Session) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		closeConn(s.conn, "error on closing ws connection towards loadbalancer")
+	})
+}
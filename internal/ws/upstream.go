@@ -0,0 +1,543 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/NodeFactoryIo/vedran/internal/actions"
+	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/record"
+	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// upstreamBufferSize is number of messages buffered for node, connection to node that
+// doesn't read messages fast enough is closed
+const upstreamBufferSize = 256
+
+// upstream is connection to node shared by multiple client sessions. Request ids are rewritten
+// to ids unique on connection so responses can be routed back to session that sent request
+type upstream struct {
+	pool   *nodePool
+	node   models.Node
+	conn   *websocket.Conn
+	repos  repositories.Repos
+	act    actions.Actions
+	nextID uint64
+	closed bool
+	// out is written to node by writeMessages so pool lock is not held while node reads messages
+	out  chan []byte
+	done chan struct{}
+
+	sessions map[*Session]bool
+	// pending are requests waiting for response mapped by upstream request id
+	pending map[string]*pendingRequest
+	// subscriptions are subscriptions served by this connection mapped by upstream subscription id
+	subscriptions map[string]*subscription
+}
+
+// pendingRequest is request forwarded to node waiting for response
+type pendingRequest struct {
+	session *Session
+	id      json.RawMessage
+	batch   *batchResponse
+	// subscription is set for subscribe requests together with id of subscription for client
+	subscription   *subscription
+	subscriptionID json.RawMessage
+	// discard is set for requests made by load balancer whose responses are not sent to clients
+	discard bool
+	// restore is set for subscriptions restored after failover, client already knows subscription id
+	restore *lostSubscription
+}
+
+// batchResponse collects responses for batch request until all responses are received
+type batchResponse struct {
+	session   *Session
+	expected  int
+	responses []json.RawMessage
+	sent      bool
+}
+
+func (b *batchResponse) add(response json.RawMessage) {
+	b.responses = append(b.responses, response)
+	b.flush()
+}
+
+func (b *batchResponse) flush() {
+	if b.sent || b.expected == 0 || len(b.responses) != b.expected {
+		return
+	}
+	b.sent = true
+	body, _ := json.Marshal(b.responses)
+	b.session.send(body)
+}
+
+type upstreamMessage struct {
+	ID     json.RawMessage     `json:"id"`
+	Method string              `json:"method"`
+	Params *notificationParams `json:"params"`
+	Result json.RawMessage     `json:"result"`
+	Error  *rpc.RPCError       `json:"error"`
+}
+
+func newUpstream(
+	pool *nodePool,
+	node models.Node,
+	conn *websocket.Conn,
+	repos repositories.Repos,
+	act actions.Actions,
+) *upstream {
+	return &upstream{
+		pool:          pool,
+		node:          node,
+		conn:          conn,
+		repos:         repos,
+		act:           act,
+		out:           make(chan []byte, upstreamBufferSize),
+		done:          make(chan struct{}),
+		sessions:      make(map[*Session]bool),
+		pending:       make(map[string]*pendingRequest),
+		subscriptions: make(map[string]*subscription),
+	}
+}
+
+// readMessages routes responses and subscription notifications from node to client sessions
+// until connection to node is closed
+func (u *upstream) readMessages() {
+	for {
+		_, msg, err := u.conn.ReadMessage()
+		if err != nil {
+			u.pool.mutex.Lock()
+			if !u.closed {
+				log.Errorf("Failed reading message from node %s because of %v:", u.node.ID, err)
+				u.close()
+			}
+			u.pool.mutex.Unlock()
+			return
+		}
+
+		u.pool.mutex.Lock()
+		kind := unknownMessage
+		if rpc.IsBatch(msg) {
+			var raws []json.RawMessage
+			if err := json.Unmarshal(msg, &raws); err != nil {
+				log.Errorf("Invalid batch response from node %s because of: %v", u.node.ID, err)
+			}
+			for _, raw := range raws {
+				if u.handleMessage(raw) == responseMessage {
+					kind = responseMessage
+				}
+			}
+		} else {
+			kind = u.handleMessage(msg)
+		}
+		u.pool.mutex.Unlock()
+
+		recordMessage(kind, u.node, u.repos)
+	}
+}
+
+// handleMessage routes message from node and returns its kind, responses to requests
+// made by load balancer are not considered responses
+func (u *upstream) handleMessage(msg json.RawMessage) messageKind {
+	var m upstreamMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		log.Errorf("Invalid message from node %s because of: %v", u.node.ID, err)
+		return unknownMessage
+	}
+	if m.ID == nil && m.Params != nil {
+		return u.handleNotification(m)
+	}
+
+	key := rpc.IDKey(m.ID)
+	p, ok := u.pending[key]
+	if !ok {
+		log.Debugf("Response with unknown id %s from node %s", key, u.node.ID)
+		return unknownMessage
+	}
+	delete(u.pending, key)
+	if p.discard {
+		return unknownMessage
+	}
+	if p.subscription != nil {
+		u.confirmSubscription(p.subscription, m, msg)
+		return responseMessage
+	}
+	if p.session.closed {
+		return unknownMessage
+	}
+
+	response, err := replaceID(msg, p.id)
+	if err != nil {
+		return unknownMessage
+	}
+	p.deliver(response)
+	return responseMessage
+}
+
+// confirmSubscription registers subscription once node confirms it and answers all clients
+// waiting for it, if node rejected subscription error is sent to clients
+func (u *upstream) confirmSubscription(sub *subscription, m upstreamMessage, msg json.RawMessage) {
+	waiting := sub.waiting
+	sub.waiting = nil
+
+	if m.Error != nil || m.Result == nil {
+		if sub.key != "" {
+			delete(u.pool.shared, sub.key)
+		}
+		for _, w := range waiting {
+			if w.restore != nil {
+				log.Errorf("Restoring subscription %s on node %s failed", string(w.subscriptionID), u.node.ID)
+				if !w.session.closed {
+					w.session.send(w.restore.dropped(subscriptionFailedMessage))
+				}
+				continue
+			}
+			if response, err := replaceID(msg, w.id); err == nil && !w.session.closed {
+				w.deliver(response)
+			}
+		}
+		return
+	}
+
+	sub.upstreamID = m.Result
+	sub.ready = true
+	u.subscriptions[rpc.IDKey(m.Result)] = sub
+	for _, w := range waiting {
+		if !w.session.closed {
+			sub.join(w)
+		}
+	}
+	if len(sub.subscribers) == 0 {
+		u.unsubscribe(sub)
+	}
+}
+
+// handleNotification sends subscription notification to every client subscribed to it,
+// notification of shared subscription is counted once regardless of number of clients
+func (u *upstream) handleNotification(m upstreamMessage) messageKind {
+	sub, ok := u.subscriptions[rpc.IDKey(m.Params.Subscription)]
+	if !ok {
+		return unknownMessage
+	}
+	sub.notificationMethod = m.Method
+	if sub.key != "" {
+		sub.last = m.Params.Result
+	}
+	for id, s := range sub.subscribers {
+		s.send(sub.notification(json.RawMessage(id), m.Method, m.Params.Result))
+	}
+	return notificationMessage
+}
+
+// forward rewrites client request and sends it to node, subscribe and unsubscribe requests
+// for shared subscriptions are answered without forwarding them when possible. Responses
+// for batch requests blocked by method policy are sent in same batch response
+func (u *upstream) forward(s *Session, msg []byte, blocked []rpc.RPCResponse) {
+	if rpc.IsBatch(msg) {
+		var raws []json.RawMessage
+		if err := json.Unmarshal(msg, &raws); err != nil || len(raws) == 0 {
+			s.send(errorResponse(nil, rpc.InvalidRequest, "Invalid Request"))
+			return
+		}
+
+		batch := &batchResponse{session: s}
+		for _, response := range blocked {
+			body, _ := json.Marshal(response)
+			batch.expected++
+			batch.responses = append(batch.responses, body)
+		}
+		var forward []json.RawMessage
+		for _, raw := range raws {
+			var req rpc.RPCRequest
+			if err := json.Unmarshal(raw, &req); err != nil || req.Validate() != nil {
+				batch.expected++
+				batch.responses = append(batch.responses, errorResponse(req.ID, rpc.InvalidRequest, "Invalid Request"))
+				continue
+			}
+			if !req.IsNotification() {
+				batch.expected++
+			}
+			if m := u.prepare(s, req, raw, batch); m != nil {
+				forward = append(forward, m)
+			}
+		}
+		if len(forward) != 0 {
+			body, _ := json.Marshal(forward)
+			u.write(body)
+		}
+		batch.flush()
+		return
+	}
+
+	var req rpc.RPCRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		s.send(errorResponse(nil, rpc.ParseError, "Parse error"))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		s.send(errorResponse(req.ID, rpc.InvalidRequest, "Invalid Request"))
+		return
+	}
+	if m := u.prepare(s, req, msg, nil); m != nil {
+		u.write(m)
+	}
+}
+
+// prepare returns message that should be sent to node for client request, nil is returned
+// if request was answered without node
+func (u *upstream) prepare(s *Session, req rpc.RPCRequest, raw json.RawMessage, batch *batchResponse) json.RawMessage {
+	if req.IsNotification() {
+		return raw
+	}
+	p := &pendingRequest{session: s, id: req.ID, batch: batch}
+
+	if subID, ok := firstParam(req); ok {
+		if sub, ok := s.subscriptions[subID]; ok && req.Method == sub.unsubscribeMethod {
+			p.deliver(resultResponse(req.ID, json.RawMessage("true")))
+			sub.leave(subID)
+			return nil
+		}
+	}
+
+	if isSubscribeMethod(req.Method) {
+		p.subscriptionID = newSubscriptionID()
+		return u.subscribe(req, raw, p)
+	}
+
+	return u.request(raw, p)
+}
+
+// subscribe joins client to shared subscription if it exists, otherwise it returns subscribe
+// request for node
+func (u *upstream) subscribe(req rpc.RPCRequest, raw json.RawMessage, p *pendingRequest) json.RawMessage {
+	key := sharedSubscriptionKey(req)
+	if sub, ok := u.pool.shared[key]; ok && key != "" {
+		if sub.ready {
+			sub.join(p)
+		} else {
+			sub.waiting = append(sub.waiting, p)
+		}
+		return nil
+	}
+
+	sub := &subscription{
+		key:               key,
+		method:            req.Method,
+		params:            req.Params,
+		unsubscribeMethod: unsubscribeMethod(req.Method),
+		upstream:          u,
+		subscribers:       make(map[string]*Session),
+		waiting:           []*pendingRequest{p},
+	}
+	if key != "" {
+		u.pool.shared[key] = sub
+	}
+	return u.request(raw, &pendingRequest{subscription: sub})
+}
+
+// resubscribe restores client subscription lost with other connection, client keeps
+// receiving notifications under same subscription id. Extrinsic watches are not restored
+// because that would submit extrinsic again, client is notified that watch was dropped
+func (u *upstream) resubscribe(s *Session, lost lostSubscription) {
+	if lost.method == submitAndWatchMethod {
+		s.send(lost.dropped(connectionLostMessage))
+		return
+	}
+	req := rpc.RPCRequest{JSONRPC: "2.0", ID: json.RawMessage("0"), Method: lost.method, Params: lost.params}
+	raw, err := json.Marshal(req)
+	if err != nil {
+		s.send(lost.dropped(subscriptionFailedMessage))
+		return
+	}
+	p := &pendingRequest{session: s, subscriptionID: lost.id, restore: &lost}
+	if m := u.subscribe(req, raw, p); m != nil {
+		u.write(m)
+	}
+}
+
+// request registers pending request and returns request with id unique on upstream connection
+func (u *upstream) request(raw json.RawMessage, p *pendingRequest) json.RawMessage {
+	u.nextID++
+	id := strconv.FormatUint(u.nextID, 10)
+	u.pending[id] = p
+	msg, _ := replaceID(raw, json.RawMessage(id))
+	return msg
+}
+
+// unsubscribe cancels subscription on node
+func (u *upstream) unsubscribe(sub *subscription) {
+	delete(u.subscriptions, rpc.IDKey(sub.upstreamID))
+	if sub.key != "" && u.pool.shared[sub.key] == sub {
+		delete(u.pool.shared, sub.key)
+	}
+	req, _ := json.Marshal(rpc.RPCRequest{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage("0"),
+		Method:  sub.unsubscribeMethod,
+		Params:  []json.RawMessage{sub.upstreamID},
+	})
+	u.write(u.request(req, &pendingRequest{discard: true}))
+}
+
+// write queues message for node without blocking, has to be called while holding pool lock
+func (u *upstream) write(msg []byte) {
+	if u.closed {
+		return
+	}
+	select {
+	case u.out <- msg:
+	default:
+		log.Errorf("Closing connection to node %s because it doesn't read messages", u.node.ID)
+		go record.FailedRequest(u.node, u.repos, u.act)
+		u.close()
+	}
+}
+
+// writeMessages sends queued messages to node until connection to node is closed
+func (u *upstream) writeMessages() {
+	for {
+		select {
+		case <-u.done:
+			return
+		case msg := <-u.out:
+			if err := writeMessage(u.conn, websocket.TextMessage, msg); err != nil {
+				u.pool.mutex.Lock()
+				if !u.closed {
+					log.Errorf("Sending request to node %s failed because of %v:", u.node.ID, err)
+					go record.FailedRequest(u.node, u.repos, u.act)
+					u.close()
+				}
+				u.pool.mutex.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// detach removes session from connection, its subscriptions are canceled if it was their last
+// client and connection is closed once it has no sessions
+func (u *upstream) detach(s *Session) {
+	s.closed = true
+	u.pool.forget(s)
+	for id, p := range u.pending {
+		if p.session == s {
+			delete(u.pending, id)
+		}
+	}
+
+	delete(u.sessions, s)
+	if len(u.sessions) == 0 && len(u.subscriptions) == 0 && !u.closed {
+		log.Debugf("Closing unused connection to node %s", u.node.ID)
+		u.close()
+	}
+}
+
+// close closes connection to node, clients waiting for responses get errors and clients
+// that used connection or its subscriptions are moved to other connection
+func (u *upstream) close() {
+	if u.closed {
+		return
+	}
+	u.closed = true
+	close(u.done)
+	u.pool.remove(u)
+	closeConn(u.conn, fmt.Sprintf("error on closing ws connection towards node %s", u.node.ID))
+
+	lost := make(map[*Session][]lostSubscription)
+	for s := range u.sessions {
+		lost[s] = nil
+	}
+	for _, p := range u.pending {
+		if p.subscription != nil {
+			for _, w := range p.subscription.waiting {
+				if w.session.closed {
+					continue
+				}
+				if w.restore != nil {
+					// subscription that was being restored is restored again on other connection
+					lost[w.session] = append(lost[w.session], *w.restore)
+				} else {
+					w.deliver(errorResponse(w.id, rpc.InternalServerError, connectionLostMessage))
+				}
+			}
+			continue
+		}
+		if !p.discard && !p.session.closed {
+			p.deliver(errorResponse(p.id, rpc.InternalServerError, connectionLostMessage))
+		}
+	}
+	for _, sub := range u.subscriptions {
+		for id, s := range sub.subscribers {
+			delete(s.subscriptions, id)
+			lost[s] = append(lost[s], sub.lost(id))
+		}
+	}
+	for s, subscriptions := range lost {
+		if !s.closed {
+			s.failover(subscriptions, u.sessions[s])
+		}
+	}
+}
+
+// leave removes client from subscription, subscription is canceled on node
+// if that was its last client
+func (sub *subscription) leave(subID string) {
+	s, ok := sub.subscribers[subID]
+	if !ok {
+		return
+	}
+	delete(sub.subscribers, subID)
+	delete(s.subscriptions, subID)
+	if len(sub.subscribers) == 0 {
+		sub.upstream.unsubscribe(sub)
+	}
+}
+
+// join adds client to confirmed subscription and answers its subscribe request, clients that
+// join shared subscription also get last notification. Restored subscriptions are not answered
+func (sub *subscription) join(p *pendingRequest) {
+	sub.subscribers[rpc.IDKey(p.subscriptionID)] = p.session
+	p.session.subscriptions[rpc.IDKey(p.subscriptionID)] = sub
+	if p.restore != nil {
+		return
+	}
+	p.deliver(resultResponse(p.id, p.subscriptionID))
+	if sub.last != nil {
+		p.session.send(sub.notification(p.subscriptionID, "", sub.last))
+	}
+}
+
+// lost returns client subscription as lost, so it can be restored on other connection
+func (sub *subscription) lost(subID string) lostSubscription {
+	return lostSubscription{
+		id:                 json.RawMessage(subID),
+		method:             sub.method,
+		params:             sub.params,
+		notificationMethod: sub.notificationMethod,
+	}
+}
+
+func (sub *subscription) notification(id json.RawMessage, method string, result json.RawMessage) []byte {
+	if method == "" {
+		method = sub.notificationMethod
+	}
+	body, _ := json.Marshal(subscriptionNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  notificationParams{Subscription: id, Result: result},
+	})
+	return body
+}
+
+// deliver sends response to client, responses for batch entries are sent once all are received
+func (p *pendingRequest) deliver(response json.RawMessage) {
+	if p.batch != nil {
+		p.batch.add(response)
+		return
+	}
+	p.session.send(response)
+}
@@ -1,17 +1,24 @@
 package ws
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/NodeFactoryIo/vedran/internal/actions"
+	"github.com/NodeFactoryIo/vedran/internal/apikey"
 	"github.com/NodeFactoryIo/vedran/internal/configuration"
+	"github.com/NodeFactoryIo/vedran/internal/iplimit"
 	"github.com/NodeFactoryIo/vedran/internal/models"
+	"github.com/NodeFactoryIo/vedran/internal/policy"
 	"github.com/NodeFactoryIo/vedran/internal/record"
 	"github.com/NodeFactoryIo/vedran/internal/repositories"
+	"github.com/NodeFactoryIo/vedran/internal/rpc"
 	"github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
 )
@@ -19,56 +26,212 @@ import (
 type Message struct {
 	msg     []byte
 	msgType int
+	// local is set for messages created by load balancer that are not node responses
+	local bool
+}
+
+// Client identifies client connected to load balancer
+type Client struct {
+	IP     string
+	APIKey string
 }
 
 var (
 	ShortHandshakeTimeout = 2 * time.Second
 )
 
+// Pipe holds state of client connection served through dedicated connection to node. Once
+// client or node connection fails, pipe stops all goroutines serving client and closes messages
+// channel after goroutines that send to it stopped
+type Pipe struct {
+	done      chan struct{}
+	stopOnce  sync.Once
+	producers sync.WaitGroup
+	activity  *activity
+}
+
+// NewPipe creates pipe for client connection
+func NewPipe() *Pipe {
+	p := &Pipe{done: make(chan struct{}), activity: newActivity()}
+	// node messages are sent to messages channel by EstablishNodeConn and
+	// local rejections by SendRequestToNode
+	p.producers.Add(2)
+	return p
+}
+
+func (p *Pipe) stop() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// send sends message to messages channel, false is returned if pipe was stopped
+func (p *Pipe) send(messages chan Message, m Message) bool {
+	select {
+	case messages <- m:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
 // SendRequestToNode reads incoming messages to load balancer and pipes
-// them to node
+// them to node, errors for methods not allowed by method policy and for
+// messages over rate limit of client are sent back to client through messages channel.
+// Ids of requests sent to node are registered in tracker
 func SendRequestToNode(
 	connToLoadbalancer *websocket.Conn,
 	connToNode *websocket.Conn,
+	messages chan Message,
 	node models.Node,
 	repos repositories.Repos,
 	act actions.Actions,
+	client Client,
+	tracker *RequestTracker,
+	pipe *Pipe,
 ) {
+	defer pipe.producers.Done()
+	defer pipe.stop()
 	for {
 		msgType, msg, err := connToLoadbalancer.ReadMessage()
 		if err != nil {
 			log.Errorf("Reading request from client failed because of %v:", err)
-			closeConnections(connToLoadbalancer, connToNode, node)
 			return
 		}
+		extendReadDeadline(connToLoadbalancer)
+		pipe.activity.touch()
+
+		if rejection := applyClientLimits(client, msg); rejection != nil {
+			if !pipe.send(messages, Message{msgType: msgType, msg: rejection, local: true}) {
+				return
+			}
+			continue
+		}
+
+		msg, blocked, rejection := applyMethodPolicy(msg)
+		if rejection == nil && blocked != nil && !tracker.Hold(msg, blocked) {
+			// node doesn't answer batch of notifications, so blocked requests are answered alone
+			rejection, _ = json.Marshal(blocked)
+		}
+		if rejection != nil {
+			if !pipe.send(messages, Message{msgType: msgType, msg: rejection, local: true}) {
+				return
+			}
+		}
+		if msg == nil {
+			continue
+		}
+
+		tracker.Track(msg)
 		err = connToNode.WriteMessage(msgType, msg)
 		if err != nil {
 			record.FailedRequest(node, repos, act)
-			closeConnections(connToLoadbalancer, connToNode, node)
 			return
 		}
 	}
 }
 
 // SendResponseToClient iterates through messages sent from node connection and sends them
-// to client
+// to client. Node is rewarded only for responses to requests registered in tracker, while
+// subscription notifications are counted separately. Client is pinged and disconnected if
+// connection is idle for too long. Once pipe is stopped, both connections are closed
 func SendResponseToClient(
 	connToLoadbalancer *websocket.Conn,
 	connToNode *websocket.Conn,
 	messages chan Message,
 	node models.Node,
 	repos repositories.Repos,
+	tracker *RequestTracker,
+	pipe *Pipe,
 ) {
-	for m := range messages {
-		if err := connToLoadbalancer.WriteMessage(m.msgType, m.msg); err != nil {
-			log.Errorf("Sending response client failed because of %v:", err)
-			closeConnections(connToLoadbalancer, connToNode, node)
+	ticker, keepAliveC := keepAliveTicker()
+	defer func() {
+		stopTicker(ticker)
+		pipe.stop()
+		closeConnections(connToLoadbalancer, connToNode, node)
+		pipe.producers.Wait()
+		close(messages)
+	}()
+
+	for {
+		select {
+		case <-pipe.done:
 			return
+		case m := <-messages:
+			if !m.local {
+				m.msg = tracker.merge(m.msg)
+			}
+			if err := writeMessage(connToLoadbalancer, m.msgType, m.msg); err != nil {
+				log.Errorf("Sending response client failed because of %v:", err)
+				return
+			}
+			pipe.activity.touch()
+			if !m.local {
+				recordMessage(tracker.classify(m.msg), node, repos)
+			}
+		case <-keepAliveC:
+			if !keepAlive(connToLoadbalancer, pipe.activity) {
+				return
+			}
 		}
-		record.SuccessfulRequest(node, repos)
 	}
 }
 
+// applyMethodPolicy returns message that should be forwarded to node and rejection message
+// for client if whole message is not allowed by method policy. If only part of batch is not
+// allowed, error responses for blocked requests are returned separately, as they have to be
+// sent in same batch response as node responses. Messages that are not valid rpc requests
+// are forwarded unchanged
+func applyMethodPolicy(msg []byte) ([]byte, []rpc.RPCResponse, []byte) {
+	if rpc.IsBatch(msg) {
+		var reqs []rpc.RPCRequest
+		if err := json.Unmarshal(msg, &reqs); err != nil {
+			return msg, nil, nil
+		}
+		allowed, blocked := policy.FilterBatch(reqs)
+		if len(blocked) == 0 {
+			return msg, nil, nil
+		}
+		if len(allowed) == 0 {
+			rejection, _ := json.Marshal(blocked)
+			return nil, nil, rejection
+		}
+		forward, _ := json.Marshal(allowed)
+		return forward, blocked, nil
+	}
+
+	var req rpc.RPCRequest
+	if err := json.Unmarshal(msg, &req); err != nil || policy.IsMethodAllowed(req.Method) {
+		return msg, nil, nil
+	}
+	log.Debugf("Request rejected because method %s is not allowed", req.Method)
+	rejection, _ := json.Marshal(policy.MethodNotAllowedError(req))
+	return nil, nil, rejection
+}
+
+// applyClientLimits counts message in usage of client api key and returns rejection message
+// for client if message exceeds rate limit of client IP or rate limit or daily quota of api key
+func applyClientLimits(client Client, msg []byte) []byte {
+	var err error
+	if !iplimit.AllowN(client.IP, rpc.BatchSize(msg)) {
+		err = errors.New("rate limit exceeded")
+	} else if err = apikey.Use(client.APIKey); err == nil {
+		return nil
+	}
+	log.Debugf("Request rejected because of: %v", err)
+
+	var reqRPCBody rpc.RPCRequest
+	var reqRPCBodies []rpc.RPCRequest
+	isBatch := rpc.IsBatch(msg)
+	if isBatch {
+		_ = json.Unmarshal(msg, &reqRPCBodies)
+	} else {
+		_ = json.Unmarshal(msg, &reqRPCBody)
+	}
+	rejection, _ := json.Marshal(rpc.CreateRPCError(isBatch, reqRPCBody, reqRPCBodies, rpc.LimitExceeded, err.Error()))
+	return rejection
+}
+
 func closeConnections(connToLoadbalancer *websocket.Conn, connToNode *websocket.Conn, node models.Node) {
 	closeConn(connToLoadbalancer, "error on closing ws connection towards loadbalancer")
 	closeConn(connToNode, fmt.Sprintf("error on closing ws connection towards node %s", node.ID))
@@ -81,17 +244,48 @@ func closeConn(conn *websocket.Conn, errorMessage string) {
 	}
 }
 
-// EstablishNodeConn dials node, pipes messages to message channel and returns connection
-// to wsConnection channel
-func EstablishNodeConn(nodeID string, wsConnection chan *websocket.Conn, messages chan Message, connErr chan *ConnectionError) {
+// EstablishNodeConn dials node, returns connection to wsConnection channel and pipes
+// messages from node to messages channel until pipe is stopped
+func EstablishNodeConn(
+	nodeID string,
+	wsConnection chan *websocket.Conn,
+	messages chan Message,
+	connErr chan *ConnectionError,
+	pipe *Pipe,
+) {
+	c, connectionError := dialNode(nodeID)
+	if connectionError != nil {
+		connErr <- connectionError
+		wsConnection <- nil
+		return
+	}
+
+	connErr <- nil
+	wsConnection <- c
+
+	defer pipe.producers.Done()
+	defer pipe.stop()
+	for {
+		msgType, m, err := c.ReadMessage()
+		if err != nil {
+			log.Errorf("Failed reading message from node %s because of %v:", nodeID, err)
+			return
+		}
+
+		if !pipe.send(messages, Message{msgType: msgType, msg: m}) {
+			return
+		}
+	}
+}
+
+// dialNode opens ws connection to node through tunnel
+func dialNode(nodeID string) (*websocket.Conn, *ConnectionError) {
 	port, err := configuration.Config.PortPool.GetWSPort(nodeID)
 	if err != nil {
-		connErr <- &ConnectionError{
+		return nil, &ConnectionError{
 			Err:  err,
 			Type: PortPoolError,
 		}
-		wsConnection <- nil
-		return
 	}
 
 	host, _ := url.Parse("ws://127.0.0.1:" + strconv.Itoa(port))
@@ -100,32 +294,18 @@ func EstablishNodeConn(nodeID string, wsConnection chan *websocket.Conn, message
 	c, _, err := dialer.Dial(host.String(), nil)
 	if err != nil {
 		if strings.Contains(err.Error(), "cancel") {
-			connErr <- &ConnectionError{
+			return nil, &ConnectionError{
 				Err:  err,
 				Type: UserCancellationError,
 			}
-		} else {
-			connErr <- &ConnectionError{
-				Err:  err,
-				Type: NodeError,
-			}
 		}
-		wsConnection <- nil
-		return
-	}
-
-	connErr <- nil
-	wsConnection <- c
-
-	defer c.Close()
-	for {
-		msgType, m, err := c.ReadMessage()
-		if err != nil {
-			log.Errorf("Failed reading message from node because of %v:", err)
-			closeConn(c, fmt.Sprintf("error on closing ws connection towards node %s", nodeID))
-			return
+		return nil, &ConnectionError{
+			Err:  err,
+			Type: NodeError,
 		}
-
-		messages <- Message{msgType: msgType, msg: m}
 	}
+	if maxMessageSize := currentOptions().maxMessageSize; maxMessageSize > 0 {
+		c.SetReadLimit(maxMessageSize)
+	}
+	return c, nil
 }
@@ -4,6 +4,7 @@ package mocks
 
 import mock "github.com/stretchr/testify/mock"
 import models "github.com/NodeFactoryIo/vedran/internal/models"
+import repositories "github.com/NodeFactoryIo/vedran/internal/repositories"
 
 // NodeRepository is an autogenerated mock type for the NodeRepository type
 type NodeRepository struct {
@@ -47,13 +48,13 @@ func (_m *NodeRepository) FindByID(ID string) (*models.Node, error) {
 	return r0, r1
 }
 
-// GetActiveNodes provides a mock function with given fields: selection
-func (_m *NodeRepository) GetActiveNodes(selection string) *[]models.Node {
-	ret := _m.Called(selection)
+// GetActiveNodes provides a mock function with given fields: strategy
+func (_m *NodeRepository) GetActiveNodes(strategy string) *[]models.Node {
+	ret := _m.Called(strategy)
 
 	var r0 *[]models.Node
 	if rf, ok := ret.Get(0).(func(string) *[]models.Node); ok {
-		r0 = rf(selection)
+		r0 = rf(strategy)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*[]models.Node)
@@ -125,13 +126,13 @@ func (_m *NodeRepository) GetPenalizedNodes() (*[]models.Node, error) {
 	return r0, r1
 }
 
-// IncreaseNodeCooldown provides a mock function with given fields: ID
-func (_m *NodeRepository) IncreaseNodeCooldown(ID string) (*models.Node, error) {
-	ret := _m.Called(ID)
+// IncreaseNodeCooldown provides a mock function with given fields: ID, growth
+func (_m *NodeRepository) IncreaseNodeCooldown(ID string, growth float64) (*models.Node, error) {
+	ret := _m.Called(ID, growth)
 
 	var r0 *models.Node
-	if rf, ok := ret.Get(0).(func(string) *models.Node); ok {
-		r0 = rf(ID)
+	if rf, ok := ret.Get(0).(func(string, float64) *models.Node); ok {
+		r0 = rf(ID, growth)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Node)
@@ -139,8 +140,8 @@ func (_m *NodeRepository) IncreaseNodeCooldown(ID string) (*models.Node, error)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(ID)
+	if rf, ok := ret.Get(1).(func(string, float64) error); ok {
+		r1 = rf(ID, growth)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -234,6 +235,31 @@ func (_m *NodeRepository) Save(node *models.Node) error {
 	return r0
 }
 
+// SubscribeToActiveNodes provides a mock function with given fields: buffer
+func (_m *NodeRepository) SubscribeToActiveNodes(buffer int) (<-chan repositories.ActiveChange, func()) {
+	ret := _m.Called(buffer)
+
+	var r0 <-chan repositories.ActiveChange
+	if rf, ok := ret.Get(0).(func(int) <-chan repositories.ActiveChange); ok {
+		r0 = rf(buffer)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan repositories.ActiveChange)
+		}
+	}
+
+	var r1 func()
+	if rf, ok := ret.Get(1).(func(int) func()); ok {
+		r1 = rf(buffer)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(func())
+		}
+	}
+
+	return r0, r1
+}
+
 // UpdateNodeUsed provides a mock function with given fields: node
 func (_m *NodeRepository) UpdateNodeUsed(node models.Node) {
 	_m.Called(node)
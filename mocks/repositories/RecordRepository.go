@@ -33,6 +33,27 @@ func (_m *RecordRepository) CountFailedRequests() (int, error) {
 	return r0, r1
 }
 
+// CountNotifications provides a mock function with given fields:
+func (_m *RecordRepository) CountNotifications() (int, error) {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CountSuccessfulRequests provides a mock function with given fields:
 func (_m *RecordRepository) CountSuccessfulRequests() (int, error) {
 	ret := _m.Called()
@@ -46,15 +46,18 @@ type ServerConfig struct {
 	PortPool Pooler
 	// AuthHandler is function validates provided auth token
 	AuthHandler func(string) bool
+	// ConnectHandler is optional function invoked with client name after tunnels of client are added
+	ConnectHandler func(string)
 	// Logger is optional logger. If nil logging is disabled.
 	Logger *log.Entry
 }
 
 type serverData struct {
-	addr        string
-	listener    net.Listener
-	logger      *log.Entry
-	authHandler func(string) bool
+	addr           string
+	listener       net.Listener
+	logger         *log.Entry
+	authHandler    func(string) bool
+	connectHandler func(string)
 }
 
 // NewServer creates a new Server based on configuration.
@@ -79,6 +82,7 @@ func NewServer(config *ServerConfig) (*Server, error) {
 		return nil, errors.New("provided auth handler is nil")
 	}
 	serverData.authHandler = config.AuthHandler
+	serverData.connectHandler = config.ConnectHandler
 
 	return newServer(serverData, config.PortPool)
 }
@@ -277,6 +281,9 @@ func (s *Server) handleClient(conn net.Conn) {
 	}
 
 	alogger.Debugf("%s connected", tunnels.IdName)
+	if s.config.connectHandler != nil {
+		go s.config.connectHandler(tunnels.IdName)
+	}
 
 	return
 
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is token bucket rate limiter that allows rate events per second
+// with bursts of at most burst events
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+}
+
+var now = time.Now
+
+// NewTokenBucket creates full token bucket, burst smaller than 1 is set to 1
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now(),
+	}
+}
+
+// Allow takes token from bucket and returns false if bucket is empty
+func (b *TokenBucket) Allow() bool {
+	return b.AllowN(1)
+}
+
+// AllowN takes n tokens from bucket and returns false if bucket doesn't have enough tokens,
+// n larger than burst is reduced to burst so it is allowed once bucket is full
+func (b *TokenBucket) AllowN(n int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	t := now()
+	b.tokens += t.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = t
+
+	tokens := math.Min(float64(n), b.burst)
+	if b.tokens < tokens {
+		return false
+	}
+	b.tokens -= tokens
+	return true
+}
+
+// LastUsed returns time when bucket was last used
+func (b *TokenBucket) LastUsed() time.Time {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.last
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket_Allow(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	b := NewTokenBucket(2, 3)
+
+	// full bucket allows burst
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	// two tokens are added each second
+	current = current.Add(500 * time.Millisecond)
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	// bucket is never filled over burst
+	current = current.Add(time.Hour)
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+	assert.Equal(t, current, b.LastUsed())
+}
+
+func TestTokenBucket_AllowN(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	b := NewTokenBucket(2, 3)
+
+	assert.True(t, b.AllowN(2))
+	assert.False(t, b.AllowN(2))
+	assert.True(t, b.AllowN(1))
+
+	// n larger than burst takes full bucket
+	current = current.Add(500 * time.Millisecond)
+	assert.False(t, b.AllowN(5))
+	current = current.Add(time.Second)
+	assert.True(t, b.AllowN(5))
+	assert.False(t, b.Allow())
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter holds separate token bucket for each key
+type Limiter struct {
+	rate    float64
+	burst   int
+	buckets map[string]*TokenBucket
+	mutex   sync.Mutex
+}
+
+// NewLimiter creates limiter that allows rate events per second with bursts of
+// at most burst events for each key
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*TokenBucket),
+	}
+}
+
+// Allow takes token from bucket for key and returns false if bucket is empty
+func (l *Limiter) Allow(key string) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN takes n tokens from bucket for key and returns false if bucket doesn't have enough tokens
+func (l *Limiter) AllowN(key string, n int) bool {
+	l.mutex.Lock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = NewTokenBucket(l.rate, l.burst)
+		l.buckets[key] = bucket
+	}
+	l.mutex.Unlock()
+	return bucket.AllowN(n)
+}
+
+// Cleanup removes buckets that were not used for idle duration, removed buckets
+// would be full on next use so removing them doesn't change limits
+func (l *Limiter) Cleanup(idle time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	t := now()
+	for key, bucket := range l.buckets {
+		if t.Sub(bucket.LastUsed()) > idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Len returns number of tracked keys
+func (l *Limiter) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.buckets)
+}
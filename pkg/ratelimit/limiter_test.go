@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	l := NewLimiter(1, 1)
+
+	// each key has separate bucket
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+	assert.True(t, l.Allow("b"))
+	assert.Equal(t, 2, l.Len())
+
+	current = current.Add(30 * time.Second)
+	assert.True(t, l.Allow("b"))
+
+	// only buckets idle for longer than duration are removed
+	current = current.Add(30 * time.Second)
+	l.Cleanup(time.Minute - time.Second)
+	assert.Equal(t, 1, l.Len())
+	assert.True(t, l.Allow("a"))
+}